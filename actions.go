@@ -0,0 +1,337 @@
+package porttools
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jakeschurch/porttools/collection"
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/output"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+var (
+	// ErrInvalidCorporateAction indicates an unknown corporate action type.
+	ErrInvalidCorporateAction = errors.New("Corporate action type must be one of split, dividend or symbol")
+
+	// ErrCorporateActionValue indicates a split ratio or dividend amount that could not be parsed.
+	ErrCorporateActionValue = errors.New("Corporate action value must be a positive number")
+
+	// ErrCorporateActionTicker indicates a symbol change without a new ticker.
+	ErrCorporateActionTicker = errors.New("Symbol changes must give a new ticker")
+
+	// ErrCorporateActionDate indicates an ex-date that could not be parsed.
+	ErrCorporateActionDate = errors.New("Corporate action ex-date must be given as yyyy-mm-dd")
+)
+
+// Corporate action types, as given in the type column of a corporate actions file.
+const (
+	// ActionSplit multiplies the shares of a security by a ratio, dividing its prices by the same.
+	ActionSplit = "split"
+	// ActionDividend pays an amount of cash for each share of a security held.
+	ActionDividend = "dividend"
+	// ActionSymbolChange renames a security.
+	ActionSymbolChange = "symbol"
+)
+
+// exDateLayout is the layout of ex-dates in a corporate actions file.
+const exDateLayout = "2006-01-02"
+
+// CorporateAction is a change to a security's shares or symbol, or a payment of cash
+// to its holders, that takes effect at the start of its ex-date.
+type CorporateAction struct {
+	Ticker string
+	ExDate time.Time
+	Type   string
+
+	// Ratio is the number of new shares given for each old share by a split,
+	// e.g. 2 for a 2-for-1 split, or 0.1 for a 1-for-10 reverse split.
+	Ratio float64
+	// Amount is the cash paid for each share by a dividend.
+	Amount utils.Amount
+	// NewTicker is the symbol a security is renamed to by a symbol change.
+	NewTicker string
+}
+
+// LoadCorporateActions reads a file of corporate actions, with headers of
+// ticker, exDate, type, value and newTicker, e.g.
+//
+//	ticker,exDate,type,value,newTicker
+//	AAPL,2014-06-09,split,7,
+//	AAPL,2017-08-10,dividend,0.63,
+//	FB,2022-06-09,symbol,,META
+//
+// value is a split's ratio or a dividend's amount per share.
+// Ex-dates are given in loc. Actions are returned in ex-date order.
+func LoadCorporateActions(name string, loc *time.Location) ([]CorporateAction, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	var cols struct{ ticker, exDate, typ, value, newTicker int }
+	for _, col := range []struct {
+		name string
+		idx  *int
+	}{
+		{"ticker", &cols.ticker}, {"exDate", &cols.exDate}, {"type", &cols.typ},
+		{"value", &cols.value}, {"newTicker", &cols.newTicker},
+	} {
+		if *col.idx, err = (config.Column{Name: col.name}).Resolve(headers); err != nil {
+			return nil, err
+		}
+	}
+
+	var actions []CorporateAction
+	for {
+		fields, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		line, _ := reader.FieldPos(0)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < len(headers) {
+			return nil, &RecordError{File: name, Line: line, Reason: ErrMissingFields}
+		}
+
+		action, err := parseCorporateAction(fields[cols.ticker], fields[cols.exDate], fields[cols.typ],
+			fields[cols.value], fields[cols.newTicker], loc)
+		if err != nil {
+			return nil, &RecordError{File: name, Line: line, Reason: err}
+		}
+		actions = append(actions, action)
+	}
+
+	sort.SliceStable(actions, func(i, j int) bool {
+		return actions[i].ExDate.Before(actions[j].ExDate)
+	})
+	return actions, nil
+}
+
+func parseCorporateAction(ticker, exDate, typ, value, newTicker string, loc *time.Location) (CorporateAction, error) {
+	var err error
+
+	action := CorporateAction{Ticker: ticker, Type: typ}
+	if action.ExDate, err = time.ParseInLocation(exDateLayout, exDate, loc); err != nil {
+		return action, ErrCorporateActionDate
+	}
+
+	switch typ {
+	case ActionSplit, ActionDividend:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v <= 0 {
+			return action, ErrCorporateActionValue
+		}
+		if typ == ActionSplit {
+			action.Ratio = v
+		} else {
+			action.Amount = utils.FloatAmount(v)
+		}
+	case ActionSymbolChange:
+		if newTicker == "" {
+			return action, ErrCorporateActionTicker
+		}
+		action.NewTicker = newTicker
+	default:
+		return action, ErrInvalidCorporateAction
+	}
+	return action, nil
+}
+
+// ------------------------------------------------------------------
+
+// applyAction applies a corporate action to the open positions of the simulation's OMS and portfolio,
+// the portfolio's cash, and the holdings of the benchmark index.
+// Actions applied to open positions are recorded in the position log.
+func (sim *Simulation) applyAction(a CorporateAction) error {
+	oms, port := sim.oms, sim.port
+
+	adjustment := output.Adjustment{Ticker: a.Ticker, Date: a.ExDate, Type: a.Type}
+
+	var lists []*collection.LinkedList
+	for _, holdings := range []*collection.HoldingList{port.Holdings(), oms.open, sim.index.Holdings} {
+		list, err := holdings.Get(a.Ticker)
+		if err == collection.ErrNoListExists {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		lists = append(lists, list)
+	}
+
+	portList, err := port.GetList(a.Ticker)
+	if err != nil && err != collection.ErrNoListExists {
+		return err
+	}
+	if portList != nil {
+		portList.Adjust(func(f instrument.Financial) instrument.Financial {
+			adjustment.Volume += f.Volume(0)
+			return f
+		})
+	}
+	pending := oms.pending[a.Ticker]
+	// orders submitted but not yet queued as pending are adjusted along with them.
+	submitted := pending[:len(pending):len(pending)]
+	sim.events.Each(func(data interface{}) {
+		if event, ok := data.(*orderSubmitted); ok && event.order.Ticker() == a.Ticker {
+			submitted = append(submitted, event.order)
+		}
+	})
+
+	// venue quotes from before a split or symbol change are stale, and are rebuilt as venues quote again.
+	if venues := oms.venues; venues != nil && a.Type != ActionDividend {
+		venues.Reset(a.Ticker)
+	}
+
+	switch a.Type {
+	case ActionSplit:
+		adjustment.Ratio = a.Ratio
+		for _, list := range lists {
+			list.Asset.Split(a.Ratio)
+			list.Adjust(func(f instrument.Financial) instrument.Financial {
+				return splitFinancial(f, a.Ratio)
+			})
+		}
+		for _, o := range submitted {
+			o.Split(a.Ratio)
+		}
+		if metrics, ok := oms.trades[a.Ticker]; ok {
+			metrics.Split(a.Ratio)
+		}
+		if selling, ok := oms.selling[a.Ticker]; ok {
+			oms.selling[a.Ticker] = utils.Amount(math.Round(float64(selling) * a.Ratio))
+		}
+		sim.index.Split(a.Ticker, a.Ratio)
+
+	case ActionDividend:
+		// cash is credited in the units the OMS fills orders in, price times volume.
+		adjustment.Cash = a.Amount * adjustment.Volume
+		port.UpdateCash(adjustment.Cash)
+		if err = sim.index.Dividend(a.Ticker, a.Amount); err != nil && err != collection.ErrNoListExists {
+			return err
+		}
+
+	case ActionSymbolChange:
+		adjustment.NewTicker = a.NewTicker
+		for _, list := range lists {
+			if list.Quote != nil {
+				list.SetTicker(a.NewTicker)
+			}
+			list.Adjust(func(f instrument.Financial) instrument.Financial {
+				return renameFinancial(f, a.NewTicker)
+			})
+		}
+		// lists are merged into any already held under the new ticker.
+		for _, holdings := range []*collection.HoldingList{port.Holdings(), oms.open, sim.index.Holdings} {
+			if err = holdings.Rename(a.Ticker, a.NewTicker); err != nil && err != collection.ErrNoListExists {
+				return err
+			}
+		}
+		sim.index.Rename(a.Ticker, a.NewTicker)
+		if sim.universe != nil {
+			sim.universe.rename(a.Ticker, a.NewTicker)
+		}
+		for _, o := range submitted {
+			o.SetTicker(a.NewTicker)
+		}
+		if pending != nil {
+			delete(oms.pending, a.Ticker)
+			oms.pending[a.NewTicker] = append(oms.pending[a.NewTicker], pending...)
+		}
+		if selling, ok := oms.selling[a.Ticker]; ok {
+			delete(oms.selling, a.Ticker)
+			oms.selling[a.NewTicker] += selling
+		}
+		if metrics, ok := oms.trades[a.Ticker]; ok {
+			delete(oms.trades, a.Ticker)
+			if _, exists := oms.trades[a.NewTicker]; !exists {
+				oms.trades[a.NewTicker] = metrics
+			}
+		}
+	}
+
+	if portList != nil {
+		sim.positions.Adjust(adjustment)
+	}
+	return nil
+}
+
+// applyActions applies the simulation's corporate actions that have taken effect by t.
+func (sim *Simulation) applyActions(t time.Time) error {
+	for len(sim.actions) > 0 && !t.Before(sim.actions[0].ExDate) {
+		if err := sim.applyAction(sim.actions[0]); err != nil {
+			return err
+		}
+		sim.actions = sim.actions[1:]
+	}
+	return nil
+}
+
+// splitFinancial adjusts a holding list element for a stock split.
+// Elements held by value are adjusted and returned as copies.
+func splitFinancial(f instrument.Financial, ratio float64) instrument.Financial {
+	if s, ok := f.(interface{ Split(float64) }); ok {
+		s.Split(ratio)
+		return f
+	}
+	switch f := f.(type) {
+	case instrument.Quote:
+		f.Split(ratio)
+		return f
+	case instrument.Holding:
+		f.Split(ratio)
+		return f
+	case instrument.Security:
+		f.Split(ratio)
+		return f
+	case order.Order:
+		f.Split(ratio)
+		return f
+	}
+	return f
+}
+
+// renameFinancial renames a holding list element on a change of symbol.
+// Elements held by value are renamed and returned as copies.
+func renameFinancial(f instrument.Financial, ticker string) instrument.Financial {
+	if r, ok := f.(interface{ SetTicker(string) }); ok {
+		r.SetTicker(ticker)
+		return f
+	}
+	switch f := f.(type) {
+	case instrument.Quote:
+		f.SetTicker(ticker)
+		return f
+	case instrument.Holding:
+		f.SetTicker(ticker)
+		return f
+	case instrument.Security:
+		if f.Quote != nil {
+			f.SetTicker(ticker)
+		}
+		return f
+	case order.Order:
+		f.SetTicker(ticker)
+		return f
+	}
+	return f
+}
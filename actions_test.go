@@ -0,0 +1,261 @@
+package porttools
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/output"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func TestLoadCorporateActions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []CorporateAction
+		wantErr error
+	}{
+		{"Actions in ex-date order",
+			"ticker,exDate,type,value,newTicker\n" +
+				"AAPL,2017-08-10,dividend,0.63,\n" +
+				"AAPL,2014-06-09,split,7,\n" +
+				"FB,2022-06-09,symbol,,META\n",
+			[]CorporateAction{
+				{Ticker: "AAPL", ExDate: time.Date(2014, 6, 9, 0, 0, 0, 0, time.UTC), Type: ActionSplit, Ratio: 7},
+				{Ticker: "AAPL", ExDate: time.Date(2017, 8, 10, 0, 0, 0, 0, time.UTC), Type: ActionDividend, Amount: utils.FloatAmount(0.63)},
+				{Ticker: "FB", ExDate: time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC), Type: ActionSymbolChange, NewTicker: "META"},
+			}, nil},
+		{"Unknown type", "ticker,exDate,type,value,newTicker\nAAPL,2014-06-09,merger,,\n", nil, ErrInvalidCorporateAction},
+		{"Bad split ratio", "ticker,exDate,type,value,newTicker\nAAPL,2014-06-09,split,-7,\n", nil, ErrCorporateActionValue},
+		{"Missing new ticker", "ticker,exDate,type,value,newTicker\nFB,2022-06-09,symbol,,\n", nil, ErrCorporateActionTicker},
+		{"Bad ex-date", "ticker,exDate,type,value,newTicker\nAAPL,06/09/2014,split,7,\n", nil, ErrCorporateActionDate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := filepath.Join(dir, "actions.csv")
+			if err := ioutil.WriteFile(name, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := LoadCorporateActions(name, time.UTC)
+			if tt.wantErr != nil {
+				if recordErr, ok := err.(*RecordError); !ok || recordErr.Reason != tt.wantErr || recordErr.Line != 2 {
+					t.Errorf("LoadCorporateActions() error = %v, want %v on line 2", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadCorporateActions() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("LoadCorporateActions() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("LoadCorporateActions()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_applyAction(t *testing.T) {
+	sim := mockSimulation(t)
+	port, index, positionLog := sim.Portfolio(), sim.Benchmark(), sim.PositionLog()
+
+	exDate := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+	quote := instrument.NewQuote(utils.FloatAmount(99), utils.FloatAmount(100), exDate.Add(-time.Hour), *instrument.NewInstrument("AAPL", 0))
+	holding := instrument.NewHolding(*instrument.NewInstrument("AAPL", 100), &utils.DatedMetric{Amount: utils.FloatAmount(100), Date: quote.Timestamp})
+
+	if err := port.Insert(holding, *quote); err != nil {
+		t.Fatal(err)
+	}
+	index.Update(*quote)
+
+	// a 2-for-1 split
+	if err := sim.applyAction(CorporateAction{Ticker: "AAPL", ExDate: exDate, Type: ActionSplit, Ratio: 2}); err != nil {
+		t.Fatalf("applyAction() split error = %v", err)
+	}
+	if holding.Volume(0) != 200 || holding.BuyPrice.Amount != utils.FloatAmount(50) {
+		t.Errorf("applyAction() split holding = %d @ %d, want 200 @ %d", holding.Volume(0), holding.BuyPrice.Amount, utils.FloatAmount(50))
+	}
+	indexList, err := index.Holdings.Get("AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if indexList.LastAsk.Amount != utils.FloatAmount(50) {
+		t.Errorf("applyAction() split index ask = %d, want %d", indexList.LastAsk.Amount, utils.FloatAmount(50))
+	}
+	// the index's level of AAPL is carried over the split, and each later action, unchanged.
+	if level, _ := index.Level("AAPL"); level != utils.FloatAmount(99) {
+		t.Errorf("applyAction() split index level = %d, want %d", level, utils.FloatAmount(99))
+	}
+
+	// a dividend of $0.50 a share
+	if err = sim.applyAction(CorporateAction{Ticker: "AAPL", ExDate: exDate, Type: ActionDividend, Amount: utils.FloatAmount(0.5)}); err != nil {
+		t.Fatalf("applyAction() dividend error = %v", err)
+	}
+	if want := utils.FloatAmount(0.5) * 200; port.Cash() != want {
+		t.Errorf("applyAction() dividend cash = %d, want %d", port.Cash(), want)
+	}
+	if sim.oms.Cash() != port.Cash() {
+		t.Errorf("applyAction() dividend strategy cash = %d, want %d", sim.oms.Cash(), port.Cash())
+	}
+	// index prices are back-adjusted by the dividend's share of the last bid, $0.50 of $49.50.
+	if want := utils.FloatAmount(50 * 49.0 / 49.5); indexList.LastAsk.Amount != want {
+		t.Errorf("applyAction() dividend index ask = %d, want %d", indexList.LastAsk.Amount, want)
+	}
+	if level, _ := index.Level("AAPL"); level != utils.FloatAmount(99) {
+		t.Errorf("applyAction() dividend index level = %d, want %d", level, utils.FloatAmount(99))
+	}
+
+	// a change of symbol
+	if err = sim.applyAction(CorporateAction{Ticker: "AAPL", ExDate: exDate, Type: ActionSymbolChange, NewTicker: "APPL"}); err != nil {
+		t.Fatalf("applyAction() symbol change error = %v", err)
+	}
+	if _, err = port.GetList("AAPL"); err == nil {
+		t.Errorf("applyAction() symbol change left holdings under AAPL")
+	}
+	if _, err = port.GetList("APPL"); err != nil || holding.Ticker() != "APPL" {
+		t.Errorf("applyAction() symbol change holding = %s, want APPL", holding.Ticker())
+	}
+	if _, err = index.Holdings.Get("APPL"); err != nil {
+		t.Errorf("applyAction() symbol change index error = %v", err)
+	}
+	if level, ok := index.Level("APPL"); !ok || level != utils.FloatAmount(99) {
+		t.Errorf("applyAction() symbol change index level = %d, %v, want %d, true", level, ok, utils.FloatAmount(99))
+	}
+
+	want := []output.Adjustment{
+		{Ticker: "AAPL", Date: exDate, Type: ActionSplit, Volume: 100, Ratio: 2},
+		{Ticker: "AAPL", Date: exDate, Type: ActionDividend, Volume: 200, Cash: utils.FloatAmount(0.5) * 200},
+		{Ticker: "AAPL", Date: exDate, Type: ActionSymbolChange, Volume: 200, NewTicker: "APPL"},
+	}
+	if len(positionLog.Adjustments) != len(want) {
+		t.Fatalf("positionLog.Adjustments = %+v, want %+v", positionLog.Adjustments, want)
+	}
+	for i := range want {
+		if positionLog.Adjustments[i] != want[i] {
+			t.Errorf("positionLog.Adjustments[%d] = %+v, want %+v", i, positionLog.Adjustments[i], want[i])
+		}
+	}
+
+	// actions on securities that are not held are not recorded.
+	if err = sim.applyAction(CorporateAction{Ticker: "GOOGL", ExDate: exDate, Type: ActionSplit, Ratio: 2}); err != nil {
+		t.Fatalf("applyAction() error = %v", err)
+	}
+	if len(positionLog.Adjustments) != len(want) {
+		t.Errorf("positionLog.Adjustments = %d, want %d", len(positionLog.Adjustments), len(want))
+	}
+}
+
+func Test_applyAction_symbolChange(t *testing.T) {
+	cfg := config.Config{}
+	cfg.Backtest.Securities = []string{"FB"}
+
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sim.universe, err = newUniverse(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	port := sim.Portfolio()
+
+	exDate := time.Date(2022, 6, 9, 0, 0, 0, 0, time.UTC)
+	for _, ticker := range []string{"FB", "META"} {
+		quote := instrument.NewQuote(utils.FloatAmount(99), utils.FloatAmount(100), exDate.Add(-time.Hour), *instrument.NewInstrument(ticker, 0))
+		holding := instrument.NewHolding(*instrument.NewInstrument(ticker, 100), &utils.DatedMetric{Amount: utils.FloatAmount(100), Date: quote.Timestamp})
+		if err = port.Insert(holding, *quote); err != nil {
+			t.Fatal(err)
+		}
+		sim.index.Update(*quote)
+	}
+
+	// an order submitted before the ex-date is still queued when the change is applied.
+	submitted := mockOrder(true, "FB", 99, 100, 10)
+	submitted.Timestamp = exDate.Add(time.Minute)
+	if err = sim.oms.Submit(submitted); err != nil {
+		t.Fatal(err)
+	}
+
+	// holdings are merged into those already held under the new ticker.
+	if err = sim.applyAction(CorporateAction{Ticker: "FB", ExDate: exDate, Type: ActionSymbolChange, NewTicker: "META"}); err != nil {
+		t.Fatalf("applyAction() symbol change error = %v", err)
+	}
+	if _, err = port.GetList("FB"); err == nil {
+		t.Errorf("applyAction() symbol change left holdings under FB")
+	}
+	holdings := sim.oms.ctx.Position("META")
+	if len(holdings) != 2 {
+		t.Fatalf("applyAction() symbol change holdings = %d, want 2", len(holdings))
+	}
+	for i := range holdings {
+		if holdings[i].Ticker() != "META" {
+			t.Errorf("applyAction() symbol change holding %d = %s, want META", i, holdings[i].Ticker())
+		}
+	}
+	if _, err = sim.index.Holdings.Get("FB"); err == nil {
+		t.Errorf("applyAction() symbol change left the index under FB")
+	}
+
+	// the queued order is pending under the new ticker once it has been handled.
+	if err = sim.handleQueued(context.Background(), NewSimClock(), submitted.Timestamp); err != nil {
+		t.Fatal(err)
+	}
+	if pending := sim.oms.pending["META"]; len(pending) != 1 || pending[0] != submitted {
+		t.Errorf("applyAction() symbol change pending orders = %v, want the submitted order under META", sim.oms.pending)
+	}
+
+	// ticks of the new ticker are replayed.
+	if !sim.universe.contains("META") {
+		t.Errorf("applyAction() symbol change universe does not contain META")
+	}
+}
+
+func TestNewSimulationFromConfig_actions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calendarFile := filepath.Join(dir, "calendar.json")
+	if err = ioutil.WriteFile(calendarFile, []byte(`{"XNYS": {"timeZone": "America/New_York", "sessions": {"regular": {"open": "09:30", "close": "16:00"}}}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	actionsFile := filepath.Join(dir, "actions.csv")
+	if err = ioutil.WriteFile(actionsFile, []byte("ticker,exDate,type,value,newTicker\nAAPL,2014-06-09,split,7,\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// ex-dates start at midnight of the exchange's days, whatever time zone the data files are in.
+	cfg := config.Config{}
+	cfg.File.TimeZone = "UTC"
+	cfg.Calendar.File, cfg.Calendar.Exchange = calendarFile, "XNYS"
+	cfg.Backtest.CorporateActions = actionsFile
+
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2014, 6, 9, 0, 0, 0, 0, loc); len(sim.actions) != 1 || !sim.actions[0].ExDate.Equal(want) {
+		t.Errorf("NewSimulationFromConfig() actions = %+v, want ex-date %v", sim.actions, want)
+	}
+}
@@ -0,0 +1,66 @@
+package porttools
+
+import (
+	"sort"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+// barAggregator rolls ticks up into bars of each ticker over a fixed interval.
+type barAggregator struct {
+	rate time.Duration
+	end  time.Time
+	open map[string]*instrument.Bar
+}
+
+// newBarAggregator returns a barAggregator of bars that are rate long.
+func newBarAggregator(rate time.Duration) *barAggregator {
+	return &barAggregator{
+		rate: rate,
+		open: make(map[string]*instrument.Bar),
+	}
+}
+
+// Add adds a tick to the bar of its ticker.
+// If the tick starts a new interval, every bar of the last interval is completed and returned.
+func (agg *barAggregator) Add(t instrument.Tick) (completed []*instrument.Bar) {
+	if !t.Timestamp.Before(agg.end) {
+		completed = agg.Flush()
+		start := agg.intervalStart(t.Timestamp)
+		agg.end = start.Add(agg.rate)
+	}
+
+	if bar, ok := agg.open[t.Ticker()]; ok {
+		bar.Add(t)
+	} else {
+		agg.open[t.Ticker()] = instrument.NewBar(agg.end.Add(-agg.rate), agg.end, t)
+	}
+	return completed
+}
+
+// Flush completes and returns every open bar, ordered by ticker.
+func (agg *barAggregator) Flush() []*instrument.Bar {
+	if len(agg.open) == 0 {
+		return nil
+	}
+	bars := make([]*instrument.Bar, 0, len(agg.open))
+	for ticker, bar := range agg.open {
+		bars = append(bars, bar)
+		delete(agg.open, ticker)
+	}
+	sort.Slice(bars, func(i, j int) bool {
+		return bars[i].Ticker() < bars[j].Ticker()
+	})
+	return bars
+}
+
+// intervalStart returns the start of the interval that ts falls in.
+// Intervals are aligned to midnight of ts's day, in ts's location.
+func (agg *barAggregator) intervalStart(ts time.Time) time.Time {
+	year, month, day := ts.Date()
+	midnight := time.Date(year, month, day, 0, 0, 0, 0, ts.Location())
+
+	elapsed := ts.Sub(midnight)
+	return midnight.Add(elapsed - elapsed%agg.rate)
+}
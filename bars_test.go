@@ -0,0 +1,62 @@
+package porttools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func mockQuoteTick(ticker string, bid, ask float64, ts time.Time) instrument.Tick {
+	tick := instrument.NewTick(10, 20, instrument.NewQuote(utils.FloatAmount(bid), utils.FloatAmount(ask), ts, instrument.Instrument{}))
+	tick.SetTicker(ticker)
+	return *tick
+}
+
+func Test_barAggregator_Add(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	agg := newBarAggregator(time.Minute)
+
+	ticks := []instrument.Tick{
+		mockQuoteTick("GOOGL", 10.00, 10.10, open.Add(5*time.Second)),
+		mockQuoteTick("AAPL", 50.00, 50.10, open.Add(10*time.Second)),
+		mockQuoteTick("AAPL", 50.20, 50.30, open.Add(20*time.Second)),
+		mockQuoteTick("AAPL", 49.90, 50.00, open.Add(50*time.Second)),
+	}
+	for i := range ticks {
+		if completed := agg.Add(ticks[i]); len(completed) != 0 {
+			t.Fatalf("Add() completed %d bars within the first interval", len(completed))
+		}
+	}
+
+	completed := agg.Add(mockQuoteTick("AAPL", 50.00, 50.10, open.Add(65*time.Second)))
+	if len(completed) != 2 {
+		t.Fatalf("Add() completed %d bars, want 2", len(completed))
+	}
+
+	bar := completed[0]
+	if bar.Ticker() != "AAPL" || !bar.Start.Equal(open) || !bar.End.Equal(open.Add(time.Minute)) {
+		t.Errorf("Add() bar = %s [%v, %v), want AAPL [%v, %v)", bar.Ticker(), bar.Start, bar.End, open, open.Add(time.Minute))
+	}
+	wantBid := instrument.OHLC{
+		Open: utils.FloatAmount(50.00), High: utils.FloatAmount(50.20),
+		Low: utils.FloatAmount(49.90), Close: utils.FloatAmount(49.90),
+	}
+	if bar.Bid != wantBid {
+		t.Errorf("Add() bar.Bid = %+v, want %+v", bar.Bid, wantBid)
+	}
+	if bar.Mid.High != utils.FloatAmount(50.25) {
+		t.Errorf("Add() bar.Mid.High = %d, want %d", bar.Mid.High, utils.FloatAmount(50.25))
+	}
+	if bar.BidSize != 30 || bar.AskSize != 60 || bar.Nticks != 3 {
+		t.Errorf("Add() bar sizes = %d/%d over %d ticks, want 30/60 over 3 ticks", bar.BidSize, bar.AskSize, bar.Nticks)
+	}
+	if completed[1].Ticker() != "GOOGL" {
+		t.Errorf("Add() second bar = %s, want GOOGL", completed[1].Ticker())
+	}
+
+	if flushed := agg.Flush(); len(flushed) != 1 || !flushed[0].Start.Equal(open.Add(time.Minute)) {
+		t.Errorf("Flush() = %v, want the open AAPL bar of the second interval", flushed)
+	}
+}
@@ -0,0 +1,664 @@
+package porttools
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+// A tick cache is a binary file of ticks and trades, laid out in column blocks:
+//
+//	header   magic "PTTC", version uint32,
+//	         config hash and data files hash [32]byte (SHA-256, see tickCacheHeader)
+//	blocks   count uint32, then count of each column in turn:
+//	         timestamp int64 (Unix nanoseconds), kind uint8 (cacheQuote or cacheTrade),
+//	         ticker uint32 (dictionary id), bid, bid size, ask, ask size int64 (utils.Amount)
+//	         trades hold their price and size in the bid and bid size columns,
+//	         and the dictionary id of their conditions in the ask column.
+//	footer   dictionary: count uint32, then length uint16 and bytes of each ticker or condition
+//	         time zone: length uint16 and bytes of the zone name
+//	         time index: count uint32, then offset int64, count uint32,
+//	         first and last timestamp int64 of each block
+//	trailer  footer offset int64, magic "PTTC"
+//
+// All integers are little-endian.
+
+var (
+	// ErrInvalidTickCache indicates that a file is not a tick cache.
+	ErrInvalidTickCache = errors.New("File is not a tick cache")
+
+	// ErrTickCacheVersion indicates a tick cache written by an unsupported version of porttools.
+	ErrTickCacheVersion = errors.New("Tick cache version is not supported")
+
+	// ErrTickCacheKind indicates that a tick cache is configured for data files that do not hold quotes or trades.
+	ErrTickCacheKind = errors.New("Tick caches can only be built from files of quotes, trades or ITCH captures")
+
+	// ErrTickCacheEvent indicates an event that cannot be held in a tick cache, such as a bar.
+	ErrTickCacheEvent = errors.New("Tick caches can only hold ticks and trades")
+
+	// ErrTickCacheVenue indicates that a tick cache is configured for data files with a venue column,
+	// as tick caches do not hold the venue of each quote.
+	ErrTickCacheVenue = errors.New("Tick caches cannot be built from files with a venue column")
+
+	// ErrTickCacheStdin indicates that a tick cache is configured for data read from standard input,
+	// which cannot be checked for changes since the cache was built.
+	ErrTickCacheStdin = errors.New("Tick caches cannot be built from standard input")
+)
+
+const (
+	cacheMagic   = "PTTC"
+	cacheVersion = 3
+
+	// cacheBlockSize is the number of ticks held in each block of a tick cache.
+	cacheBlockSize = 4096
+
+	// cacheTickSize is the number of bytes each tick or trade takes up in a block.
+	cacheTickSize = 5*8 + 4 + 1
+)
+
+// Kinds of the events held in a tick cache.
+const (
+	cacheQuote byte = iota
+	cacheTrade
+)
+
+var byteOrder = binary.LittleEndian
+
+// cacheHeader holds the hashes a tick cache is checked against before it is replayed.
+type cacheHeader struct {
+	// config is a hash of the configuration the cache was built with.
+	config [sha256.Size]byte
+	// files is a hash of the names and modification times of the files the cache was built from.
+	files [sha256.Size]byte
+}
+
+// cacheBlock is an entry in the time index of a tick cache.
+type cacheBlock struct {
+	offset      int64
+	count       uint32
+	first, last int64
+}
+
+// ------------------------------------------------------------------
+
+// CacheWriter writes a stream of ticks and trades as a tick cache.
+type CacheWriter struct {
+	w       *bufio.Writer
+	offset  int64
+	block   []instrument.Event
+	buf     []byte
+	dict    map[string]uint32
+	tickers []string
+	zone    string
+	index   []cacheBlock
+	err     error
+}
+
+// NewCacheWriter returns a CacheWriter that writes a tick cache to w.
+// Close must be called to complete the cache.
+func NewCacheWriter(w io.Writer) *CacheWriter {
+	return newCacheWriter(w, cacheHeader{})
+}
+
+// newCacheWriter returns a CacheWriter that writes a tick cache with the given header hashes to w.
+func newCacheWriter(w io.Writer, header cacheHeader) *CacheWriter {
+	cw := &CacheWriter{
+		w:     bufio.NewWriter(w),
+		block: make([]instrument.Event, 0, cacheBlockSize),
+		dict:  make(map[string]uint32),
+	}
+	cw.write([]byte(cacheMagic))
+	cw.write(byteOrder.AppendUint32(nil, cacheVersion))
+	cw.write(header.config[:])
+	cw.write(header.files[:])
+	return cw
+}
+
+// Write adds a tick to the cache.
+// Ticks are expected to be written in the order they are to be replayed in.
+func (cw *CacheWriter) Write(t *instrument.Tick) error {
+	return cw.WriteEvent(t)
+}
+
+// WriteEvent adds a tick or trade to the cache,
+// returning ErrTickCacheEvent for any other event.
+// Events are expected to be written in the order they are to be replayed in.
+func (cw *CacheWriter) WriteEvent(e instrument.Event) error {
+	switch e.(type) {
+	case *instrument.Tick, *instrument.Trade:
+	default:
+		return ErrTickCacheEvent
+	}
+	if cw.zone == "" {
+		cw.zone = e.Time().Location().String()
+	}
+	cw.block = append(cw.block, e)
+	if len(cw.block) == cacheBlockSize {
+		cw.flush()
+	}
+	return cw.err
+}
+
+// Close writes the cache's remaining ticks, ticker dictionary and time index.
+// It does not close the underlying writer.
+func (cw *CacheWriter) Close() error {
+	if len(cw.block) > 0 {
+		cw.flush()
+	}
+	footer := cw.offset
+
+	buf := byteOrder.AppendUint32(nil, uint32(len(cw.tickers)))
+	for i := range cw.tickers {
+		buf = appendString(buf, cw.tickers[i])
+	}
+	buf = appendString(buf, cw.zone)
+
+	buf = byteOrder.AppendUint32(buf, uint32(len(cw.index)))
+	for _, block := range cw.index {
+		buf = byteOrder.AppendUint64(buf, uint64(block.offset))
+		buf = byteOrder.AppendUint32(buf, block.count)
+		buf = byteOrder.AppendUint64(buf, uint64(block.first))
+		buf = byteOrder.AppendUint64(buf, uint64(block.last))
+	}
+	buf = byteOrder.AppendUint64(buf, uint64(footer))
+	buf = append(buf, cacheMagic...)
+	cw.write(buf)
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.err
+}
+
+// flush writes the cache's pending ticks and trades as a block.
+func (cw *CacheWriter) flush() {
+	n := len(cw.block)
+	block := cacheBlock{
+		offset: cw.offset,
+		count:  uint32(n),
+		first:  cw.block[0].Time().UnixNano(),
+		last:   cw.block[n-1].Time().UnixNano(),
+	}
+
+	// each event is laid out as a row of its kind, ticker and four amounts.
+	kinds := make([]byte, n)
+	tickers := make([]uint32, n)
+	amounts := make([][4]utils.Amount, n)
+	for i, e := range cw.block {
+		tickers[i] = cw.tickerID(e.Ticker())
+		switch e := e.(type) {
+		case *instrument.Tick:
+			kinds[i] = cacheQuote
+			amounts[i] = [4]utils.Amount{e.Bid, e.BidSize, e.Ask, e.AskSize}
+		case *instrument.Trade:
+			kinds[i] = cacheTrade
+			amounts[i] = [4]utils.Amount{e.Price, e.Size, utils.Amount(cw.tickerID(e.Conditions)), 0}
+		}
+	}
+
+	buf := byteOrder.AppendUint32(cw.buf[:0], block.count)
+	for _, e := range cw.block {
+		buf = byteOrder.AppendUint64(buf, uint64(e.Time().UnixNano()))
+	}
+	buf = append(buf, kinds...)
+	for i := range tickers {
+		buf = byteOrder.AppendUint32(buf, tickers[i])
+	}
+	for col := 0; col < 4; col++ {
+		for i := range amounts {
+			buf = byteOrder.AppendUint64(buf, uint64(amounts[i][col]))
+		}
+	}
+	cw.write(buf)
+
+	cw.buf = buf
+	cw.block = cw.block[:0]
+	cw.index = append(cw.index, block)
+}
+
+// tickerID interns a ticker, or trade conditions, in the cache's dictionary.
+func (cw *CacheWriter) tickerID(ticker string) uint32 {
+	id, ok := cw.dict[ticker]
+	if !ok {
+		id = uint32(len(cw.tickers))
+		cw.dict[ticker] = id
+		cw.tickers = append(cw.tickers, ticker)
+	}
+	return id
+}
+
+func (cw *CacheWriter) write(p []byte) {
+	if cw.err != nil {
+		return
+	}
+	var n int
+	n, cw.err = cw.w.Write(p)
+	cw.offset += int64(n)
+}
+
+func appendString(buf []byte, s string) []byte {
+	buf = byteOrder.AppendUint16(buf, uint16(len(s)))
+	return append(buf, s...)
+}
+
+// WriteTickCache writes the ticks and trades of src to a tick cache named name,
+// returning the number of events written.
+// The cache is only created once all events have been written,
+// and is not created if ctx is done first.
+func WriteTickCache(ctx context.Context, name string, src TickSource) (n int, err error) {
+	return writeTickCache(ctx, name, src, cacheHeader{})
+}
+
+// writeTickCache writes the ticks and trades of src to a tick cache named name, with the given header hashes.
+func writeTickCache(ctx context.Context, name string, src TickSource, header cacheHeader) (n int, err error) {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name)+".tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	cw := newCacheWriter(tmp, header)
+	events := withContext(ctx, eventSource(src))
+	defer events.Close()
+	for {
+		event, err := events.NextEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if err = cw.WriteEvent(event); err != nil {
+			return n, err
+		}
+		n++
+	}
+	if err = cw.Close(); err != nil {
+		return n, err
+	}
+	if err = tmp.Close(); err != nil {
+		return n, err
+	}
+	return n, os.Rename(tmp.Name(), name)
+}
+
+// ------------------------------------------------------------------
+
+// CacheSource is a TickSource that replays the ticks and trades of a tick cache.
+type CacheSource struct {
+	file        *os.File
+	header      cacheHeader
+	instruments []instrument.Instrument
+	loc         *time.Location
+	index       []cacheBlock
+
+	next   int // index of the next block to be read
+	events []instrument.Event
+	pos    int
+	buf    []byte
+}
+
+// NewCacheSource opens the tick cache named name.
+func NewCacheSource(name string) (*CacheSource, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	src := &CacheSource{file: file}
+	if err = src.readFooter(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return src, nil
+}
+
+// Next returns the next tick of the cache, skipping any trades.
+// The cache is closed once all of its events have been replayed.
+func (src *CacheSource) Next() (*instrument.Tick, error) {
+	for {
+		event, err := src.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if tick, ok := event.(*instrument.Tick); ok {
+			return tick, nil
+		}
+	}
+}
+
+// NextEvent returns the next tick or trade of the cache.
+// The cache is closed once all of its events have been replayed.
+func (src *CacheSource) NextEvent() (instrument.Event, error) {
+	for src.pos == len(src.events) {
+		if src.next == len(src.index) {
+			src.Close()
+			return nil, io.EOF
+		}
+		if err := src.readBlock(src.next); err != nil {
+			return nil, err
+		}
+		src.next++
+	}
+	event := src.events[src.pos]
+	src.pos++
+	return event, nil
+}
+
+// SeekTime uses the cache's time index to skip ahead to the first event at or after t.
+func (src *CacheSource) SeekTime(t time.Time) error {
+	ts := t.UnixNano()
+	i := sort.Search(len(src.index), func(i int) bool {
+		return src.index[i].last >= ts
+	})
+	src.next, src.events, src.pos = i, nil, 0
+	if i == len(src.index) {
+		return nil
+	}
+
+	if err := src.readBlock(i); err != nil {
+		return err
+	}
+	src.next++
+	src.pos = sort.Search(len(src.events), func(j int) bool {
+		return !src.events[j].Time().Before(t)
+	})
+	return nil
+}
+
+// Close closes the cache's file.
+func (src *CacheSource) Close() error {
+	if src.file == nil {
+		return nil
+	}
+	err := src.file.Close()
+	src.file = nil
+	return err
+}
+
+// readBlock decodes the ticks and trades of block i of the cache.
+func (src *CacheSource) readBlock(i int) error {
+	block := src.index[i]
+	n := int(block.count)
+
+	size := 4 + n*cacheTickSize
+	if cap(src.buf) < size {
+		src.buf = make([]byte, size)
+	}
+	buf := src.buf[:size]
+	if _, err := src.file.ReadAt(buf, block.offset); err != nil {
+		return err
+	}
+	if byteOrder.Uint32(buf) != block.count {
+		return ErrInvalidTickCache
+	}
+
+	// events are handed out by reference, so each block is decoded into new memory.
+	events := make([]instrument.Event, n)
+	ticks := make([]instrument.Tick, n)
+	quotes := make([]instrument.Quote, n)
+	trades := make([]instrument.Trade, n)
+
+	cols := buf[4:]
+	timestamps, cols := cols[:8*n], cols[8*n:]
+	kinds, cols := cols[:n], cols[n:]
+	tickers, cols := cols[:4*n], cols[4*n:]
+	bids, cols := cols[:8*n], cols[8*n:]
+	bidSizes, cols := cols[:8*n], cols[8*n:]
+	asks, askSizes := cols[:8*n], cols[8*n:]
+
+	for j := 0; j < n; j++ {
+		id := byteOrder.Uint32(tickers[4*j:])
+		if int(id) >= len(src.instruments) {
+			return ErrInvalidTickCache
+		}
+		ts := time.Unix(0, int64(byteOrder.Uint64(timestamps[8*j:]))).In(src.loc)
+		bid := utils.Amount(byteOrder.Uint64(bids[8*j:]))
+		bidSize := utils.Amount(byteOrder.Uint64(bidSizes[8*j:]))
+		ask := utils.Amount(byteOrder.Uint64(asks[8*j:]))
+
+		switch kinds[j] {
+		case cacheQuote:
+			quotes[j] = instrument.Quote{Instrument: src.instruments[id], Bid: bid, Ask: ask, Timestamp: ts}
+			ticks[j] = instrument.Tick{
+				Quote:   &quotes[j],
+				BidSize: bidSize,
+				AskSize: utils.Amount(byteOrder.Uint64(askSizes[8*j:])),
+			}
+			events[j] = &ticks[j]
+		case cacheTrade:
+			if ask < 0 || int(ask) >= len(src.instruments) {
+				return ErrInvalidTickCache
+			}
+			trades[j] = instrument.Trade{
+				Instrument: src.instruments[id],
+				Price:      bid, Size: bidSize,
+				Conditions: src.instruments[ask].Ticker(), Timestamp: ts,
+			}
+			events[j] = &trades[j]
+		default:
+			return ErrInvalidTickCache
+		}
+	}
+	src.events, src.pos = events, 0
+	return nil
+}
+
+// readFooter reads the ticker dictionary and time index of the cache.
+func (src *CacheSource) readFooter() error {
+	info, err := src.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	header := make([]byte, 8+2*sha256.Size)
+	trailer := make([]byte, 12)
+	if size < int64(len(header)+len(trailer)) {
+		return ErrInvalidTickCache
+	}
+	if _, err = src.file.ReadAt(header, 0); err != nil {
+		return err
+	}
+	if _, err = src.file.ReadAt(trailer, size-int64(len(trailer))); err != nil {
+		return err
+	}
+	if string(header[:4]) != cacheMagic || string(trailer[8:]) != cacheMagic {
+		return ErrInvalidTickCache
+	}
+	if byteOrder.Uint32(header[4:]) != cacheVersion {
+		return ErrTickCacheVersion
+	}
+	copy(src.header.config[:], header[8:])
+	copy(src.header.files[:], header[8+sha256.Size:])
+
+	offset := int64(byteOrder.Uint64(trailer))
+	if offset < int64(len(header)) || offset > size-int64(len(trailer)) {
+		return ErrInvalidTickCache
+	}
+	footer := &footerReader{buf: make([]byte, size-int64(len(trailer))-offset)}
+	if _, err = src.file.ReadAt(footer.buf, offset); err != nil {
+		return err
+	}
+
+	src.instruments = make([]instrument.Instrument, footer.uint32())
+	for i := range src.instruments {
+		src.instruments[i] = *instrument.NewInstrument(footer.string(), 0)
+	}
+	zone := footer.string()
+
+	src.index = make([]cacheBlock, footer.uint32())
+	for i := range src.index {
+		src.index[i] = cacheBlock{
+			offset: int64(footer.uint64()),
+			count:  footer.uint32(),
+			first:  int64(footer.uint64()),
+			last:   int64(footer.uint64()),
+		}
+	}
+	if footer.err != nil {
+		return footer.err
+	}
+
+	if src.loc, err = time.LoadLocation(zone); err != nil {
+		return err
+	}
+	return nil
+}
+
+// footerReader decodes the fields of a tick cache's footer.
+type footerReader struct {
+	buf []byte
+	err error
+}
+
+func (r *footerReader) next(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = ErrInvalidTickCache
+		return make([]byte, n)
+	}
+	p := r.buf[:n]
+	r.buf = r.buf[n:]
+	return p
+}
+
+func (r *footerReader) uint32() uint32 { return byteOrder.Uint32(r.next(4)) }
+func (r *footerReader) uint64() uint64 { return byteOrder.Uint64(r.next(8)) }
+func (r *footerReader) string() string {
+	return string(r.next(int(byteOrder.Uint16(r.next(2)))))
+}
+
+// ------------------------------------------------------------------
+
+// loadTickCache returns a source replaying the tick cache configured by cfg.
+// The cache is built from the config's data files, unless ctx is done first,
+// if it does not exist, was written by an earlier version of porttools,
+// or is stale: built with a different configuration, or from data files that have since changed.
+// A cache is replayed without its data files if they are no longer found.
+func loadTickCache(ctx context.Context, cfg *config.Config) (*CacheSource, error) {
+	switch cfg.File.Kind {
+	case "", config.KindQuotes, config.KindTrades, config.KindITCH:
+	default:
+		return nil, ErrTickCacheKind
+	}
+	if cfg.File.Columns.Venue != nil {
+		return nil, ErrTickCacheVenue
+	}
+	if cfg.File.Glob == stdinGlob {
+		return nil, ErrTickCacheStdin
+	}
+
+	header, found, err := tickCacheHeader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := NewCacheSource(cfg.File.Cache)
+	switch {
+	case err == nil:
+		if cache.header.config == header.config && (!found || cache.header.files == header.files) {
+			return cache, nil
+		}
+		cache.Close()
+		log.Println("tick cache", cfg.File.Cache, "is stale")
+	case err == ErrTickCacheVersion:
+		log.Println("tick cache", cfg.File.Cache, "was written by an earlier version")
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	if !found {
+		return nil, ErrInvalidFileGlob
+	}
+
+	src, err := newDataSource(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := src.(io.Closer); ok {
+		defer c.Close()
+	}
+	log.Println("building tick cache", cfg.File.Cache)
+
+	n, err := writeTickCache(ctx, cfg.File.Cache, src, header)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("cached", n, "ticks")
+	if s, ok := src.(summarizer); ok {
+		log.Println("ingested", s.Summary())
+	}
+	return NewCacheSource(cfg.File.Cache)
+}
+
+// tickCacheHeader returns the hashes a tick cache configured by cfg is checked against.
+// The config hash covers the settings that change which events are cached: the file settings,
+// date range and calendar, along with the universe of ITCH captures, which is applied as they are read.
+// The files hash covers the names and modification times of the data files to be replayed,
+// and of the calendar and universe files. found is false if no data files are found.
+func tickCacheHeader(cfg *config.Config) (header cacheHeader, found bool, err error) {
+	file := cfg.File
+	file.Cache, file.RejectsFile, file.Parsers = "", "", 0
+	settings := struct {
+		File               config.File
+		StartDate, EndDate string
+		BarRate            config.BarDuration
+		Calendar           interface{}
+		Universe           interface{}
+	}{File: file, StartDate: cfg.Simulation.StartDate, EndDate: cfg.Simulation.EndDate,
+		BarRate: cfg.Simulation.BarRate, Calendar: cfg.Calendar}
+
+	names := []string{cfg.Calendar.File}
+	if cfg.File.Kind == config.KindITCH {
+		settings.Universe = []interface{}{cfg.Backtest.Securities, cfg.Backtest.SecuritiesFile,
+			cfg.Backtest.IgnoreSecurities, cfg.Backtest.IgnoreSecuritiesFile}
+		names = append(names, cfg.Backtest.SecuritiesFile, cfg.Backtest.IgnoreSecuritiesFile)
+	}
+	b, err := json.Marshal(settings)
+	if err != nil {
+		return header, false, err
+	}
+	header.config = sha256.Sum256(b)
+
+	files, err := dataFiles(cfg)
+	if err == ErrInvalidFileGlob {
+		return header, false, nil
+	} else if err != nil {
+		return header, false, err
+	}
+	for _, f := range files {
+		names = append(names, f.name)
+	}
+
+	h := sha256.New()
+	for _, name := range names {
+		if name == "" {
+			fmt.Fprintf(h, "%q\n", name)
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return header, false, err
+		}
+		fmt.Fprintf(h, "%q %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+	}
+	h.Sum(header.files[:0])
+	return header, true, nil
+}
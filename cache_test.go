@@ -0,0 +1,462 @@
+package porttools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func mockCacheTicks(n int, loc *time.Location) []*instrument.Tick {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, loc)
+	tickers := []string{"AAPL", "GOOGL", "MSFT"}
+
+	ticks := make([]*instrument.Tick, n)
+	for i := range ticks {
+		quote := instrument.NewQuote(utils.Amount(5000+i), utils.Amount(5010+i), open.Add(time.Duration(i)*time.Millisecond),
+			*instrument.NewInstrument(tickers[i%len(tickers)], 0))
+		ticks[i] = instrument.NewTick(utils.Amount(i%7), utils.Amount(i%5), quote)
+	}
+	return ticks
+}
+
+func mockTickCache(t testing.TB, ticks []*instrument.Tick) string {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := filepath.Join(dir, "mock.ptc")
+
+	n, err := WriteTickCache(context.Background(), name, NewSliceSource(ticks))
+	if err != nil {
+		t.Fatalf("WriteTickCache() error = %v", err)
+	}
+	if n != len(ticks) {
+		t.Fatalf("WriteTickCache() = %d, want %d", n, len(ticks))
+	}
+	return name
+}
+
+func TestCacheSource_Next(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name   string
+		nTicks int
+	}{
+		{"Empty", 0},
+		{"Single block", 3},
+		{"Many blocks", cacheBlockSize*2 + 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticks := mockCacheTicks(tt.nTicks, loc)
+			name := mockTickCache(t, ticks)
+			defer os.RemoveAll(filepath.Dir(name))
+
+			src, err := NewCacheSource(name)
+			if err != nil {
+				t.Fatalf("NewCacheSource() error = %v", err)
+			}
+			for i := range ticks {
+				got, err := src.Next()
+				if err != nil {
+					t.Fatalf("CacheSource.Next() error = %v", err)
+				}
+				want := ticks[i]
+				if got.Ticker() != want.Ticker() || !got.Timestamp.Equal(want.Timestamp) ||
+					got.Bid != want.Bid || got.Ask != want.Ask || got.BidSize != want.BidSize || got.AskSize != want.AskSize {
+					t.Fatalf("CacheSource.Next() %d = %+v, want %+v", i, got, want)
+				}
+				if got.Timestamp.Location().String() != loc.String() {
+					t.Fatalf("CacheSource.Next() location = %v, want %v", got.Timestamp.Location(), loc)
+				}
+			}
+			if _, err = src.Next(); err != io.EOF {
+				t.Errorf("CacheSource.Next() error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func TestCacheSource_SeekTime(t *testing.T) {
+	ticks := mockCacheTicks(cacheBlockSize*3, time.UTC)
+	name := mockTickCache(t, ticks)
+	defer os.RemoveAll(filepath.Dir(name))
+
+	tests := []struct {
+		name  string
+		t     time.Time
+		want  int
+		isEOF bool
+	}{
+		{"Before first tick", ticks[0].Timestamp.Add(-time.Hour), 0, false},
+		{"Within a block", ticks[cacheBlockSize+10].Timestamp, cacheBlockSize + 10, false},
+		{"Between ticks", ticks[cacheBlockSize-1].Timestamp.Add(time.Microsecond), cacheBlockSize, false},
+		{"After last tick", ticks[len(ticks)-1].Timestamp.Add(time.Second), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := NewCacheSource(name)
+			if err != nil {
+				t.Fatalf("NewCacheSource() error = %v", err)
+			}
+			defer src.Close()
+
+			if err = src.SeekTime(tt.t); err != nil {
+				t.Fatalf("CacheSource.SeekTime() error = %v", err)
+			}
+			got, err := src.Next()
+			if tt.isEOF {
+				if err != io.EOF {
+					t.Errorf("CacheSource.Next() error = %v, want %v", err, io.EOF)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CacheSource.Next() error = %v", err)
+			}
+			if !got.Timestamp.Equal(ticks[tt.want].Timestamp) {
+				t.Errorf("CacheSource.Next() at %v, want %v", got.Timestamp, ticks[tt.want].Timestamp)
+			}
+		})
+	}
+}
+
+func TestWriteTickCache_cancel(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "mock.ptc")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// the channel is never closed, so the source blocks once its tick is received.
+	tickChan := make(chan *instrument.Tick, 1)
+	tickChan <- mockCacheTicks(1, time.UTC)[0]
+
+	errChan := make(chan error, 1)
+	go func() {
+		_, err := WriteTickCache(ctx, name, NewChanSource(tickChan))
+		errChan <- err
+	}()
+	select {
+	case err = <-errChan:
+		if err != context.DeadlineExceeded {
+			t.Errorf("WriteTickCache() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("WriteTickCache() did not return once its context was done")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("WriteTickCache() left files %v", files)
+	}
+}
+
+func TestNewCacheSource_invalid(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "mock_20170814")
+	if err = ioutil.WriteFile(name, []byte("1,AAPL,50.00,10,50.10,10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = NewCacheSource(name); err != ErrInvalidTickCache {
+		t.Errorf("NewCacheSource() error = %v, want %v", err, ErrInvalidTickCache)
+	}
+}
+
+func Test_loadTickCache(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+
+	data := "1,AAPL,50.00,10,50.10,10,Q,,\n2,GOOGL,10.00,5,10.10,5,Q,,\n3,AAPL,,,,,T,50.05,300\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, "mock_20170814"), []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := new(config.Config)
+	cfg.File.Glob = filepath.Join(dir, "mock_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+	cfg.File.Columns.Type = &config.Column{Index: 6}
+	cfg.File.TradeType = "T"
+	cfg.File.Columns.Price = config.Column{Index: 7}
+	cfg.File.Columns.Size = config.Column{Index: 8}
+	cfg.File.Cache = filepath.Join(dir, "mock.ptc")
+
+	// the cache is built on the first load, and replayed without its data files after.
+	// trades read from files holding both quotes and trades are kept in the cache.
+	for _, run := range []string{"build", "replay"} {
+		src, err := loadTickCache(context.Background(), cfg)
+		if err != nil {
+			t.Fatalf("loadTickCache() %s error = %v", run, err)
+		}
+		var got []string
+		for {
+			event, err := src.NextEvent()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("CacheSource.NextEvent() %s error = %v", run, err)
+			}
+			got = append(got, event.Ticker())
+			if trade, ok := event.(*instrument.Trade); ok {
+				got[len(got)-1] += fmt.Sprintf("@%v/%d", trade.Price, trade.Size)
+			}
+		}
+		if want := "[AAPL GOOGL AAPL@$50.05/300]"; fmt.Sprint(got) != want {
+			t.Errorf("loadTickCache() %s events = %v, want %v", run, got, want)
+		}
+		if err = os.Remove(filepath.Join(dir, "mock_20170814")); err != nil && !os.IsNotExist(err) {
+			t.Fatal(err)
+		}
+	}
+
+	cfg.File.Kind = config.KindBars
+	if _, err := loadTickCache(context.Background(), cfg); err != ErrTickCacheKind {
+		t.Errorf("loadTickCache() error = %v, want %v", err, ErrTickCacheKind)
+	}
+
+	// a cache of standard input would be replayed in place of whatever is piped in later.
+	cfg.File.Kind = ""
+	cfg.File.Glob = stdinGlob
+	if _, err := loadTickCache(context.Background(), cfg); err != ErrTickCacheStdin {
+		t.Errorf("loadTickCache() error = %v, want %v", err, ErrTickCacheStdin)
+	}
+}
+
+func Test_loadTickCache_stale(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "mock_20170814")
+	modTime := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+
+	writeData := func(data string) {
+		if err := ioutil.WriteFile(name, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// each write is given its own modification time, as file systems may not tell apart quick writes.
+		modTime = modTime.Add(time.Second)
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeData("1,AAPL,50.00,10,50.10,10\n")
+
+	cfg := new(config.Config)
+	cfg.File.Glob = filepath.Join(dir, "mock_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+	cfg.File.Cache = filepath.Join(dir, "mock.ptc")
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	tests := []struct {
+		name   string
+		change func()
+		want   string
+	}{
+		{"Built", func() {}, "[AAPL@$50.00]"},
+		{"Replayed", func() {}, "[AAPL@$50.00]"},
+		{"Data file changed", func() { writeData("1,GOOGL,10.00,5,10.10,5\n") }, "[GOOGL@$10.00]"},
+		{"Column mapping changed", func() {
+			cfg.File.Columns.Bid, cfg.File.Columns.Ask = cfg.File.Columns.Ask, cfg.File.Columns.Bid
+		}, "[GOOGL@$10.10]"},
+		{"Data file added", func() {
+			if err := ioutil.WriteFile(filepath.Join(dir, "mock_20170815"), []byte("2,MSFT,70.00,5,70.50,5\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, "[GOOGL@$10.10 MSFT@$70.50]"},
+		{"Date range changed", func() { cfg.Simulation.StartDate = "20170815" }, "[MSFT@$70.50]"},
+		{"Earlier version", func() {
+			f, err := os.OpenFile(cfg.File.Cache, os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.WriteAt(byteOrder.AppendUint32(nil, cacheVersion-1), 4)
+			f.Close()
+		}, "[MSFT@$70.50]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			src, err := loadTickCache(context.Background(), cfg)
+			if err != nil {
+				t.Fatalf("loadTickCache() error = %v", err)
+			}
+			var got []string
+			for {
+				tick, err := src.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("CacheSource.Next() error = %v", err)
+				}
+				got = append(got, fmt.Sprintf("%s@%v", tick.Ticker(), tick.Bid))
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("loadTickCache() ticks = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Benchmark_replay compares replays of a day of ticks from a data file and from a tick cache.
+func Benchmark_replay(b *testing.B) {
+	nTicks := 100000
+
+	dir := mockDataFiles(b)
+	defer os.RemoveAll(dir)
+
+	f, err := os.Create(filepath.Join(dir, "mock_20170814"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	for i := 0; i < nTicks; i++ {
+		fmt.Fprintf(f, "%d,AAPL,%.2f,%d,%.2f,%d\n", i, 50+float64(i%100)/100, 100+i%7, 50.01+float64(i%100)/100, 200+i%5)
+	}
+	f.Close()
+
+	cfg := new(config.Config)
+	cfg.File.Glob = filepath.Join(dir, "mock_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	cacheCfg := *cfg
+	cacheCfg.File.Cache = filepath.Join(dir, "mock.ptc")
+	if _, err = loadTickCache(context.Background(), &cacheCfg); err != nil {
+		b.Fatal(err)
+	}
+
+	sources := []struct {
+		name string
+		open func() (TickSource, error)
+	}{
+		{"FileSource", func() (TickSource, error) { return NewFileSource(cfg) }},
+		{"CacheSource", func() (TickSource, error) { return NewCacheSource(cacheCfg.File.Cache) }},
+	}
+	for _, s := range sources {
+		b.Run(s.name, func(b *testing.B) {
+			b.ResetTimer()
+			start := time.Now()
+
+			for i := 0; i < b.N; i++ {
+				src, err := s.open()
+				if err != nil {
+					b.Fatal(err)
+				}
+				for {
+					if _, err = src.Next(); err != nil {
+						break
+					}
+				}
+				if err != io.EOF {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(nTicks*b.N)/time.Since(start).Seconds(), "ticks/s")
+		})
+	}
+}
+
+func TestCacheWriter_WriteEvent(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	quote := instrument.NewQuote(utils.FloatAmount(50.00), utils.FloatAmount(50.10), open, *instrument.NewInstrument("AAPL", 0))
+
+	tests := []struct {
+		name    string
+		event   instrument.Event
+		wantErr error
+	}{
+		{"Tick", instrument.NewTick(10, 20, quote), nil},
+		{"Trade", instrument.NewTrade("AAPL", utils.FloatAmount(50.05), 300, "@F", open.Add(time.Second)), nil},
+		{"Bar", instrument.NewBar(open, open.Add(time.Minute), *instrument.NewTick(10, 20, quote)), ErrTickCacheEvent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "porttools")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			f, err := os.Create(filepath.Join(dir, "mock.ptc"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+
+			cw := NewCacheWriter(f)
+			if err = cw.WriteEvent(tt.event); err != tt.wantErr {
+				t.Fatalf("CacheWriter.WriteEvent() error = %v, want %v", err, tt.wantErr)
+			}
+			if err = cw.Close(); err != nil {
+				t.Fatalf("CacheWriter.Close() error = %v", err)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			src, err := NewCacheSource(f.Name())
+			if err != nil {
+				t.Fatalf("NewCacheSource() error = %v", err)
+			}
+			got, err := src.NextEvent()
+			if err != nil {
+				t.Fatalf("CacheSource.NextEvent() error = %v", err)
+			}
+			if describeEvent(got) != describeEvent(tt.event) {
+				t.Errorf("CacheSource.NextEvent() = %s, want %s", describeEvent(got), describeEvent(tt.event))
+			}
+		})
+	}
+}
+
+// describeEvent formats the fields of a tick or trade held in a tick cache.
+func describeEvent(e instrument.Event) string {
+	switch e := e.(type) {
+	case *instrument.Tick:
+		return fmt.Sprintf("tick %s %v %d/%d %d/%d", e.Ticker(), e.Time(), e.Bid, e.BidSize, e.Ask, e.AskSize)
+	case *instrument.Trade:
+		return fmt.Sprintf("trade %s %v %d/%d %q", e.Ticker(), e.Time(), e.Price, e.Size, e.Conditions)
+	}
+	return fmt.Sprintf("%T", e)
+}
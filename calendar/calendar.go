@@ -0,0 +1,315 @@
+// Package calendar describes the trading days and sessions of exchanges.
+package calendar
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownExchange indicates an exchange missing from a calendar.
+	ErrUnknownExchange = errors.New("Exchange could not be found in calendar")
+
+	// ErrUnknownSession indicates a session missing from an exchange's calendar.
+	ErrUnknownSession = errors.New("Session could not be found in exchange calendar")
+
+	// ErrInvalidClock indicates a time of day that could not be parsed.
+	ErrInvalidClock = errors.New("Time of day must be given as hh:mm or hh:mm:ss")
+)
+
+// Session names that are conventionally defined for an exchange.
+// Custom sessions may be given any other name.
+const (
+	// Regular is an exchange's core trading hours.
+	Regular = "regular"
+	// Extended is an exchange's trading hours including pre-market and after-hours trading.
+	Extended = "extended"
+)
+
+// dateLayout is the layout of holiday and half-day dates in a calendar file.
+const dateLayout = "2006-01-02"
+
+// Load reads a calendar from a JSON file, e.g.
+//
+//	{
+//	    "XNYS": {
+//	        "timeZone": "America/New_York",
+//	        "sessions": {
+//	            "regular": {"open": "09:30", "close": "16:00"},
+//	            "extended": {"open": "04:00", "close": "20:00"}
+//	        },
+//	        "holidays": ["2017-07-04", "2017-09-04"],
+//	        "halfDays": {"2017-07-03": "13:00"}
+//	    }
+//	}
+func Load(filename string) (Calendar, error) {
+	var calendar Calendar
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	if err = json.NewDecoder(file).Decode(&calendar); err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// Calendar holds the trading calendars of exchanges, by exchange name.
+type Calendar map[string]*Exchange
+
+// Exchange returns the calendar of the named exchange.
+func (c Calendar) Exchange(name string) (*Exchange, error) {
+	exchange, ok := c[name]
+	if !ok {
+		return nil, ErrUnknownExchange
+	}
+	return exchange, nil
+}
+
+// ------------------------------------------------------------------
+
+// Exchange holds the trading calendar of an exchange.
+type Exchange struct {
+	loc      *time.Location
+	sessions map[string]Session
+	holidays map[date]bool
+	halfDays map[date]Clock
+	weekend  map[time.Weekday]bool
+}
+
+// exchangeJSON is the layout of an exchange in a calendar file.
+type exchangeJSON struct {
+	TimeZone string             `json:"timeZone"`
+	Sessions map[string]Session `json:"sessions"`
+	Holidays []string           `json:"holidays"`
+	// HalfDays maps dates to the early time sessions close at.
+	HalfDays map[string]Clock `json:"halfDays"`
+	// Weekend lists the days of the week the exchange is closed, Saturday and Sunday if not given.
+	Weekend []string `json:"weekend"`
+}
+
+// UnmarshalJSON reads an exchange's calendar, as laid out in a calendar file.
+func (e *Exchange) UnmarshalJSON(data []byte) error {
+	var v exchangeJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	exchange, err := NewExchange(v.TimeZone, v.Sessions)
+	if err != nil {
+		return err
+	}
+
+	for _, day := range v.Holidays {
+		d, err := parseDate(day)
+		if err != nil {
+			return err
+		}
+		exchange.holidays[d] = true
+	}
+	for day, close := range v.HalfDays {
+		d, err := parseDate(day)
+		if err != nil {
+			return err
+		}
+		exchange.halfDays[d] = close
+	}
+	if v.Weekend != nil {
+		exchange.weekend = make(map[time.Weekday]bool)
+	}
+	for _, day := range v.Weekend {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return err
+		}
+		exchange.weekend[weekday] = true
+	}
+
+	*e = *exchange
+	return nil
+}
+
+// NewExchange instantiates the calendar of an exchange trading the given sessions
+// in time zone tz, e.g. "America/New_York". Exchanges are closed on weekends.
+func NewExchange(tz string, sessions map[string]Session) (*Exchange, error) {
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, err
+	}
+	exchange := &Exchange{
+		loc:      loc,
+		sessions: make(map[string]Session, len(sessions)),
+		holidays: make(map[date]bool),
+		halfDays: make(map[date]Clock),
+		weekend:  map[time.Weekday]bool{time.Saturday: true, time.Sunday: true},
+	}
+	for name, session := range sessions {
+		exchange.sessions[name] = session
+	}
+	return exchange, nil
+}
+
+// AddHoliday closes the exchange on the date of t.
+func (e *Exchange) AddHoliday(t time.Time) {
+	e.holidays[dateOf(t)] = true
+}
+
+// AddHalfDay closes the exchange's sessions early, at close, on the date of t.
+func (e *Exchange) AddHalfDay(t time.Time, close Clock) {
+	e.halfDays[dateOf(t)] = close
+}
+
+// Location returns the time zone of the exchange.
+func (e *Exchange) Location() *time.Location {
+	return e.loc
+}
+
+// Session returns the named session of the exchange.
+func (e *Exchange) Session(name string) (Session, error) {
+	session, ok := e.sessions[name]
+	if !ok {
+		return Session{}, ErrUnknownSession
+	}
+	return session, nil
+}
+
+// IsTradingDay reports whether the exchange is open on the calendar date of t,
+// as given in t's location.
+func (e *Exchange) IsTradingDay(t time.Time) bool {
+	return !e.weekend[t.Weekday()] && !e.holidays[dateOf(t)]
+}
+
+// Hours returns the open and close times of a session on the date of t,
+// as given in t's location. ok is false if the exchange is not open that day.
+func (e *Exchange) Hours(session Session, t time.Time) (open, close time.Time, ok bool) {
+	if !e.IsTradingDay(t) {
+		return open, close, false
+	}
+	d := dateOf(t)
+
+	closeAt := session.Close
+	if early, ok := e.halfDays[d]; ok && early < closeAt {
+		closeAt = early
+	}
+	if closeAt <= session.Open {
+		return open, close, false
+	}
+	return session.Open.on(d, e.loc), closeAt.on(d, e.loc), true
+}
+
+// InSession reports whether t falls within the exchange's session,
+// from its open up to but not including its close.
+func (e *Exchange) InSession(session Session, t time.Time) bool {
+	local := t.In(e.loc)
+	open, close, ok := e.Hours(session, local)
+	return ok && !local.Before(open) && local.Before(close)
+}
+
+// Overlaps reports whether the interval [start, end) overlaps the exchange's session
+// on the date of start, e.g. whether a bar covers any of a trading day.
+func (e *Exchange) Overlaps(session Session, start, end time.Time) bool {
+	open, close, ok := e.Hours(session, start.In(e.loc))
+	return ok && start.Before(close) && end.After(open)
+}
+
+// TradingDays returns the exchange's trading days from the dates of start to end, inclusive,
+// at midnight in the exchange's time zone.
+func (e *Exchange) TradingDays(start, end time.Time) []time.Time {
+	var days []time.Time
+
+	s, last := dateOf(start), dateOf(end)
+	for day := time.Date(s.year, s.month, s.day, 0, 0, 0, 0, e.loc); !last.before(dateOf(day)); day = day.AddDate(0, 0, 1) {
+		if e.IsTradingDay(day) {
+			days = append(days, day)
+		}
+	}
+	return days
+}
+
+// ------------------------------------------------------------------
+
+// Session is a period of the trading day.
+type Session struct {
+	Open  Clock `json:"open"`
+	Close Clock `json:"close"`
+}
+
+// Clock is a time of day, as a duration since midnight.
+type Clock time.Duration
+
+// ParseClock parses a time of day given as hh:mm or hh:mm:ss, e.g. "09:30".
+func ParseClock(s string) (Clock, error) {
+	layout := "15:04"
+	if strings.Count(s, ":") == 2 {
+		layout = "15:04:05"
+	}
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return 0, ErrInvalidClock
+	}
+	hour, min, sec := t.Clock()
+	return Clock(time.Duration(hour)*time.Hour + time.Duration(min)*time.Minute + time.Duration(sec)*time.Second), nil
+}
+
+// UnmarshalJSON reads a time of day given as hh:mm or hh:mm:ss.
+func (c *Clock) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	clock, err := ParseClock(s)
+	if err != nil {
+		return err
+	}
+	*c = clock
+	return nil
+}
+
+// on returns the time of day c on date d, as the wall clock reads in loc.
+func (c Clock) on(d date, loc *time.Location) time.Time {
+	return time.Date(d.year, d.month, d.day, 0, 0, 0, int(c), loc)
+}
+
+// date is a calendar date, independent of time zone.
+type date struct {
+	year  int
+	month time.Month
+	day   int
+}
+
+func dateOf(t time.Time) date {
+	year, month, day := t.Date()
+	return date{year, month, day}
+}
+
+func (d date) before(other date) bool {
+	if d.year != other.year {
+		return d.year < other.year
+	}
+	if d.month != other.month {
+		return d.month < other.month
+	}
+	return d.day < other.day
+}
+
+func parseDate(s string) (date, error) {
+	t, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return date{}, err
+	}
+	return dateOf(t), nil
+}
+
+func parseWeekday(s string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(day.String(), s) {
+			return day, nil
+		}
+	}
+	return 0, errors.New("unknown weekday " + s)
+}
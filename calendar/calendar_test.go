@@ -0,0 +1,161 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const mockCalendar = `{
+	"XNYS": {
+		"timeZone": "America/New_York",
+		"sessions": {
+			"regular": {"open": "09:30", "close": "16:00"},
+			"extended": {"open": "04:00", "close": "20:00"},
+			"closingAuction": {"open": "15:50", "close": "16:00"}
+		},
+		"holidays": ["2017-07-04"],
+		"halfDays": {"2017-07-03": "13:00"}
+	}
+}`
+
+func mockExchange(t *testing.T) *Exchange {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "calendar.json")
+	if err = ioutil.WriteFile(name, []byte(mockCalendar), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cal, err := Load(name)
+	if err != nil {
+		t.Skipf("Load() error = %v", err)
+	}
+	if _, err = cal.Exchange("XLON"); err != ErrUnknownExchange {
+		t.Errorf("Calendar.Exchange() error = %v, want %v", err, ErrUnknownExchange)
+	}
+	exchange, err := cal.Exchange("XNYS")
+	if err != nil {
+		t.Fatalf("Calendar.Exchange() error = %v", err)
+	}
+	return exchange
+}
+
+func TestExchange_InSession(t *testing.T) {
+	exchange := mockExchange(t)
+	ny := exchange.Location()
+
+	tests := []struct {
+		name    string
+		session string
+		t       time.Time
+		want    bool
+	}{
+		{"Regular open", Regular, time.Date(2017, 8, 14, 9, 30, 0, 0, ny), true},
+		{"Pre-market", Regular, time.Date(2017, 8, 14, 8, 0, 0, 0, ny), false},
+		{"Regular close", Regular, time.Date(2017, 8, 14, 16, 0, 0, 0, ny), false},
+		{"After-hours extended", Extended, time.Date(2017, 8, 14, 18, 0, 0, 0, ny), true},
+		{"Custom session", "closingAuction", time.Date(2017, 8, 14, 15, 55, 0, 0, ny), true},
+		{"Other time zone", Regular, time.Date(2017, 8, 14, 14, 0, 0, 0, time.UTC), true},
+		{"Weekend", Regular, time.Date(2017, 8, 12, 12, 0, 0, 0, ny), false},
+		{"Holiday", Regular, time.Date(2017, 7, 4, 12, 0, 0, 0, ny), false},
+		{"Before early close", Regular, time.Date(2017, 7, 3, 12, 59, 0, 0, ny), true},
+		{"After early close", Regular, time.Date(2017, 7, 3, 13, 0, 0, 0, ny), false},
+		{"Daylight saving change", Regular, time.Date(2017, 3, 13, 9, 30, 0, 0, ny), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := exchange.Session(tt.session)
+			if err != nil {
+				t.Fatalf("Exchange.Session() error = %v", err)
+			}
+			if got := exchange.InSession(session, tt.t); got != tt.want {
+				t.Errorf("Exchange.InSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := exchange.Session("overnight"); err != ErrUnknownSession {
+		t.Errorf("Exchange.Session() error = %v, want %v", err, ErrUnknownSession)
+	}
+}
+
+func TestExchange_Overlaps(t *testing.T) {
+	exchange := mockExchange(t)
+	ny := exchange.Location()
+	session, err := exchange.Session(Regular)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2017, 8, 14, 0, 0, 0, 0, ny)
+
+	tests := []struct {
+		name       string
+		start, end time.Time
+		want       bool
+	}{
+		{"Daily bar", day, day.AddDate(0, 0, 1), true},
+		{"Minute bar at open", day.Add(9*time.Hour + 30*time.Minute), day.Add(9*time.Hour + 31*time.Minute), true},
+		{"Bar spanning open", day.Add(9*time.Hour + 25*time.Minute), day.Add(9*time.Hour + 35*time.Minute), true},
+		{"Bar ending at open", day.Add(9*time.Hour + 25*time.Minute), day.Add(9*time.Hour + 30*time.Minute), false},
+		{"Bar starting at close", day.Add(16 * time.Hour), day.Add(16*time.Hour + 5*time.Minute), false},
+		{"Daily bar on weekend", day.AddDate(0, 0, -2), day.AddDate(0, 0, -1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exchange.Overlaps(session, tt.start, tt.end); got != tt.want {
+				t.Errorf("Exchange.Overlaps() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExchange_TradingDays(t *testing.T) {
+	exchange := mockExchange(t)
+
+	start := time.Date(2017, 6, 30, 0, 0, 0, 0, time.UTC)
+	end := time.Date(2017, 7, 6, 0, 0, 0, 0, time.UTC)
+
+	var got []string
+	for _, day := range exchange.TradingDays(start, end) {
+		got = append(got, day.Format("2006-01-02"))
+	}
+	want := []string{"2017-06-30", "2017-07-03", "2017-07-05", "2017-07-06"}
+	if len(got) != len(want) {
+		t.Fatalf("Exchange.TradingDays() = %v, want %v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("Exchange.TradingDays() = %v, want %v", got, want)
+		}
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Clock
+		wantErr error
+	}{
+		{"Hours and minutes", "09:30", Clock(9*time.Hour + 30*time.Minute), nil},
+		{"Hours, minutes and seconds", "15:59:30", Clock(15*time.Hour + 59*time.Minute + 30*time.Second), nil},
+		{"Invalid", "9.30am", 0, ErrInvalidClock},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseClock(tt.s)
+			if err != tt.wantErr {
+				t.Fatalf("ParseClock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseClock() = %v, want %v", time.Duration(got), time.Duration(tt.want))
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package porttools
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+)
+
+var (
+	// ErrInvalidReplaySpeed indicates a replay speed that is not a positive multiple of wall-clock time.
+	ErrInvalidReplaySpeed = errors.New("replaySpeed must be a positive multiple of wall-clock speed")
+)
+
+// Clock is the source of the current time of a simulation.
+// The OMS and algorithms read the time from the simulation's clock rather than the system clock,
+// so that a replay behaves the same whatever speed it is run at.
+type Clock interface {
+	// Now returns the current time of the simulation.
+	Now() time.Time
+	// Advance moves the clock on to the time of the next event to be replayed.
+	// Times before the clock's current time leave it unchanged.
+	// The context's error is returned if it is done before the clock could be advanced.
+	Advance(ctx context.Context, t time.Time) error
+}
+
+// ------------------------------------------------------------------
+
+// SimClock is a Clock that jumps straight to the time of each event,
+// replaying events as fast as they can be processed.
+type SimClock struct {
+	mu  sync.RWMutex
+	now time.Time
+}
+
+// NewSimClock returns a SimClock, set to the zero time until it is first advanced.
+func NewSimClock() *SimClock {
+	return &SimClock{}
+}
+
+// Now returns the time of the latest event the clock was advanced to.
+func (c *SimClock) Now() time.Time {
+	c.mu.RLock()
+	now := c.now
+	c.mu.RUnlock()
+	return now
+}
+
+// Advance sets the clock to t.
+func (c *SimClock) Advance(ctx context.Context, t time.Time) error {
+	c.set(t)
+	return nil
+}
+
+func (c *SimClock) set(t time.Time) {
+	c.mu.Lock()
+	if t.After(c.now) {
+		c.now = t
+	}
+	c.mu.Unlock()
+}
+
+// ------------------------------------------------------------------
+
+// PacedClock is a Clock that holds up the replay of each event until its gap from
+// the first event has passed in wall-clock time, divided by the clock's speed.
+type PacedClock struct {
+	SimClock
+	speed  float64
+	maxGap time.Duration
+
+	// start is the time of the first event, and wallStart the wall-clock time it was replayed at.
+	start, wallStart time.Time
+
+	// wall and sleep are the system clock, replaced in tests.
+	wall  func() time.Time
+	sleep func(context.Context, time.Duration) error
+}
+
+// NewPacedClock returns a PacedClock replaying events at speed times wall-clock speed,
+// e.g. 1 for real time, or 60 for a minute of market data a second.
+// If maxGap is positive, no more than maxGap of market time is waited out between events,
+// so that replays are not held up across market closes.
+func NewPacedClock(speed float64, maxGap time.Duration) (*PacedClock, error) {
+	if speed <= 0 {
+		return nil, ErrInvalidReplaySpeed
+	}
+	return &PacedClock{
+		speed:  speed,
+		maxGap: maxGap,
+		wall:   time.Now,
+		sleep:  sleep,
+	}, nil
+}
+
+// Advance waits until the event at t is due to be replayed, then sets the clock to t.
+func (c *PacedClock) Advance(ctx context.Context, t time.Time) error {
+	now := c.Now()
+	if !t.After(now) {
+		return nil
+	}
+	if c.start.IsZero() {
+		c.start, c.wallStart = t, c.wall()
+		c.set(t)
+		return nil
+	}
+
+	if gap := t.Sub(now); c.maxGap > 0 && gap > c.maxGap {
+		// skip over the time beyond maxGap, as if the events had been replayed that much earlier.
+		c.start = c.start.Add(gap - c.maxGap)
+	}
+	due := c.wallStart.Add(time.Duration(float64(t.Sub(c.start)) / c.speed))
+	if wait := due.Sub(c.wall()); wait > 0 {
+		if err := c.sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+	c.set(t)
+	return nil
+}
+
+// sleep waits for d to pass, or for ctx to be done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// newClock returns the clock configured for a simulation:
+// a PacedClock if a replay speed is given, otherwise a SimClock.
+func newClock(cfg *config.Config) (Clock, error) {
+	if cfg.Simulation.ReplaySpeed == 0 {
+		return NewSimClock(), nil
+	}
+	return NewPacedClock(cfg.Simulation.ReplaySpeed, time.Duration(cfg.Simulation.ReplayMaxGap))
+}
@@ -0,0 +1,122 @@
+package porttools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+)
+
+func TestPacedClock_Advance(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		speed   float64
+		maxGap  time.Duration
+		offsets []time.Duration
+		want    []time.Duration
+	}{
+		{"Real time", 1, 0,
+			[]time.Duration{0, time.Second, 3 * time.Second, 3 * time.Second},
+			[]time.Duration{time.Second, 2 * time.Second}},
+		{"Accelerated", 10, 0,
+			[]time.Duration{0, time.Second, time.Minute},
+			[]time.Duration{100 * time.Millisecond, 5900 * time.Millisecond}},
+		{"Out of order", 1, 0,
+			[]time.Duration{0, 2 * time.Second, time.Second, 3 * time.Second},
+			[]time.Duration{2 * time.Second, time.Second}},
+		{"Capped gaps", 60, time.Minute,
+			[]time.Duration{0, time.Minute, 17 * time.Hour, 17*time.Hour + time.Minute},
+			[]time.Duration{time.Second, time.Second, time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewPacedClock(tt.speed, tt.maxGap)
+			if err != nil {
+				t.Fatal(err)
+			}
+			wall := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+			var got []time.Duration
+			c.wall = func() time.Time { return wall }
+			c.sleep = func(ctx context.Context, d time.Duration) error {
+				got = append(got, d)
+				wall = wall.Add(d)
+				return nil
+			}
+
+			var latest time.Time
+			for _, offset := range tt.offsets {
+				ts := open.Add(offset)
+				if err := c.Advance(context.Background(), ts); err != nil {
+					t.Fatalf("PacedClock.Advance() error = %v", err)
+				}
+				if ts.After(latest) {
+					latest = ts
+				}
+				if !c.Now().Equal(latest) {
+					t.Errorf("PacedClock.Now() = %v, want %v", c.Now(), latest)
+				}
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("PacedClock.Advance() slept %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("PacedClock.Advance() sleep %d = %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+
+	if _, err := NewPacedClock(0, 0); err != ErrInvalidReplaySpeed {
+		t.Errorf("NewPacedClock() error = %v, want %v", err, ErrInvalidReplaySpeed)
+	}
+}
+
+// mockClockAlgorithm records the time of the simulation's clock as it is passed quotes.
+type mockClockAlgorithm struct {
+	oms  *OMS
+	seen []time.Time
+}
+
+func (a *mockClockAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	a.seen = append(a.seen, a.oms.Now())
+	return nil, nil
+}
+
+func (a *mockClockAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+func TestSimulation_replay_clock(t *testing.T) {
+	sim := mockSimulation(t)
+	algo := &mockClockAlgorithm{oms: sim.OMS()}
+	sim.SetStrategy(NewStrategy(algo))
+
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	ticks := mockTicks("AAPL", "GOOGL", "AAPL")
+	events := make(chan instrument.Event, len(ticks))
+	for i := range ticks {
+		ticks[i].Timestamp = open.Add(time.Duration(i) * time.Second)
+		events <- ticks[i]
+	}
+	close(events)
+
+	clock := NewSimClock()
+	sim.SetClock(clock)
+	if err := sim.replay(context.Background(), mockEventSource(events), clock); err != nil {
+		t.Fatalf("Simulation.replay() error = %v", err)
+	}
+
+	if len(algo.seen) != len(ticks) {
+		t.Fatalf("Algorithm saw %d quotes, want %d", len(algo.seen), len(ticks))
+	}
+	for i := range ticks {
+		if !algo.seen[i].Equal(ticks[i].Timestamp) {
+			t.Errorf("Now() at quote %d = %v, want %v", i, algo.seen[i], ticks[i].Timestamp)
+		}
+	}
+}
@@ -2,10 +2,12 @@ package benchmark
 
 import (
 	"errors"
+	"math"
 	"sync"
 
 	"github.com/jakeschurch/porttools/collection"
 	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
 )
 
 var (
@@ -20,6 +22,7 @@ var (
 func NewIndex() *Index {
 	index := Index{
 		Holdings: collection.NewHoldingList(),
+		levels:   make(map[string]*level),
 	}
 	return &index
 }
@@ -29,6 +32,21 @@ func NewIndex() *Index {
 type Index struct {
 	mu       sync.RWMutex
 	Holdings *collection.HoldingList
+
+	// levels holds the latest level of each ticker, rather than its price history,
+	// so the index's memory does not grow with the data replayed.
+	levels map[string]*level
+}
+
+// level is the total return level of a ticker: its last bid, adjusted by factor
+// for the splits and dividends since it was first quoted.
+type level struct {
+	price  utils.Amount
+	factor float64
+}
+
+func (l *level) amount() utils.Amount {
+	return utils.Amount(math.Round(float64(l.price) * l.factor))
 }
 
 // Insert adds a new holding to an Index's holdings list.
@@ -42,4 +60,72 @@ func (index *Index) Update(q instrument.Quote) {
 	if err := index.Holdings.Update(q); err != nil {
 		index.Holdings.Insert(q)
 	}
+
+	index.mu.Lock()
+	if l, ok := index.levels[q.Ticker()]; ok {
+		l.price = q.Bid
+	} else {
+		index.levels[q.Ticker()] = &level{price: q.Bid, factor: 1}
+	}
+	index.mu.Unlock()
+}
+
+// Level returns the level of ticker in the index: its last bid, carried forward over splits and
+// dividends so that the change between two levels is the ticker's total return between them.
+// False is returned if ticker has not been quoted.
+func (index *Index) Level(ticker string) (utils.Amount, bool) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	l, ok := index.levels[ticker]
+	if !ok {
+		return 0, false
+	}
+	return l.amount(), true
+}
+
+// Split carries the level of ticker over a stock split of ratio new shares for each old share.
+// The index's holdings are adjusted along with other holding lists.
+func (index *Index) Split(ticker string, ratio float64) {
+	index.mu.Lock()
+	if l, ok := index.levels[ticker]; ok && ratio > 0 {
+		l.price = utils.Amount(math.Round(float64(l.price) / ratio))
+		l.factor *= ratio
+	}
+	index.mu.Unlock()
+}
+
+// Rename carries the level of ticker over to newTicker on a change of symbol.
+func (index *Index) Rename(ticker, newTicker string) {
+	index.mu.Lock()
+	if l, ok := index.levels[ticker]; ok {
+		delete(index.levels, ticker)
+		index.levels[newTicker] = l
+	}
+	index.mu.Unlock()
+}
+
+// UpdateTrade will use trade t to bring holding metrics up to date.
+// Trades of securities not yet held in the index are ignored.
+func (index *Index) UpdateTrade(t instrument.Trade) {
+	index.Holdings.UpdateTrade(t)
+}
+
+// Dividend back-adjusts the index's price metrics for ticker for a cash dividend of amount a share,
+// so the benchmark's returns include the dividends paid on its holdings.
+func (index *Index) Dividend(ticker string, amount utils.Amount) error {
+	list, err := index.Holdings.Get(ticker)
+	if err != nil {
+		return err
+	}
+	list.Asset.Dividend(amount)
+
+	// the dividend is reinvested in the level, which is left unchanged as the price falls by the dividend.
+	index.mu.Lock()
+	if l, ok := index.levels[ticker]; ok && l.price > amount && amount > 0 {
+		l.factor *= float64(l.price) / float64(l.price-amount)
+		l.price -= amount
+	}
+	index.mu.Unlock()
+	return nil
 }
@@ -0,0 +1,56 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func TestIndex_Level(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	quote := func(index *Index, bid float64) {
+		index.Update(*instrument.NewQuote(utils.FloatAmount(bid), utils.FloatAmount(bid+0.10),
+			open, *instrument.NewInstrument("AAPL", 0)))
+	}
+
+	tests := []struct {
+		name   string
+		adjust func(*Index)
+		ticker string
+		want   utils.Amount
+		wantOk bool
+	}{
+		{"Not quoted", nil, "GOOGL", 0, false},
+		{"Last bid", func(index *Index) { quote(index, 110.00) }, "AAPL", utils.FloatAmount(110.00), true},
+		// levels are carried over a split, and follow the split-adjusted bids quoted after it.
+		{"Split", func(index *Index) { index.Split("AAPL", 2) }, "AAPL", utils.FloatAmount(100.00), true},
+		{"Quoted after split", func(index *Index) {
+			index.Split("AAPL", 2)
+			quote(index, 55.00)
+		}, "AAPL", utils.FloatAmount(110.00), true},
+		// dividends are reinvested, so the level rises with the bid quoted ex-dividend.
+		{"Dividend", func(index *Index) { index.Dividend("AAPL", utils.FloatAmount(20.00)) }, "AAPL", utils.FloatAmount(100.00), true},
+		{"Quoted after dividend", func(index *Index) {
+			index.Dividend("AAPL", utils.FloatAmount(20.00))
+			quote(index, 88.00)
+		}, "AAPL", utils.FloatAmount(110.00), true},
+		{"Symbol change", func(index *Index) { index.Rename("AAPL", "APPL") }, "APPL", utils.FloatAmount(100.00), true},
+		{"Old symbol", func(index *Index) { index.Rename("AAPL", "APPL") }, "AAPL", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index := NewIndex()
+			quote(index, 100.00)
+			if tt.adjust != nil {
+				tt.adjust(index)
+			}
+			got, ok := index.Level(tt.ticker)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("Index.Level() = %d, %v, want %d, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -49,10 +49,12 @@ func NewLookupCache() *LookupCache {
 // Delete removes cached key-value pair, allocates index to openSlots.
 func Delete(l *LookupCache, key string) int16 {
 	l.mu.Lock()
-	value := Get(l, key)
+	value, ok := l.items[key]
 	delete(l.items, key)
 
-	if value != -1 {
+	if !ok {
+		value = -1
+	} else {
 		l.openSlots = append(l.openSlots, value)
 	}
 	l.mu.Unlock()
@@ -124,6 +126,15 @@ func (node *LinkedNode) GetUnderlying() instrument.Financial {
 	case order.Order:
 		return order.Order(node.Financial.(order.Order))
 
+	case *order.Order:
+		return *node.Financial.(*order.Order)
+
+	case *instrument.Holding:
+		return *node.Financial.(*instrument.Holding)
+
+	case *instrument.Security:
+		return *node.Financial.(*instrument.Security)
+
 	default:
 		return nil
 	}
@@ -154,6 +165,21 @@ func NewLinkedList(f instrument.Financial) *LinkedList {
 
 	case instrument.Security:
 		asset = f.(instrument.Security).GetUnderlying().(instrument.Asset)
+
+	case instrument.Quote:
+		quote := f.(instrument.Quote)
+		asset = *instrument.NewAsset(&quote)
+
+	case *order.Order:
+		quote := f.(*order.Order).Quote
+		asset = *instrument.NewAsset(&quote)
+
+	case *instrument.Security:
+		asset = f.(*instrument.Security).Asset
+		if asset.Quote != nil {
+			quote := *asset.Quote
+			asset.Quote = &quote
+		}
 	}
 
 	l := &LinkedList{
@@ -167,6 +193,15 @@ func NewLinkedList(f instrument.Financial) *LinkedList {
 	return l
 }
 
+// Volume can be used as a get/set method of a list's aggregate volume if 0 is delta.
+// Lists without a quote hold no volume.
+func (l *LinkedList) Volume(delta utils.Amount) utils.Amount {
+	if l.Asset == nil || l.Quote == nil {
+		return 0
+	}
+	return l.Quote.AdjustVolume(delta)
+}
+
 // Push inserts a new element
 func (l *LinkedList) Push(f instrument.Financial) {
 	var last *LinkedNode
@@ -182,7 +217,38 @@ func (l *LinkedList) Push(f instrument.Financial) {
 	}
 	last.next = node
 	node.prev = last
-	l.tail = last
+	l.tail = node
+}
+
+// splice moves the elements of other to the end of a list, along with their volume.
+// The nodes themselves are moved, so that references to them stay valid.
+func (l *LinkedList) splice(other *LinkedList) {
+	first := other.head.next
+	if first == nil {
+		return
+	}
+	l.Volume(other.Volume(0))
+
+	last := l.tail
+	if l.head.next == nil {
+		last = l.head
+	}
+	last.next = first
+	first.prev = last
+	l.tail = other.tail
+
+	other.head.next = nil
+	other.tail = other.head
+	other.Volume(-other.Volume(0))
+}
+
+// Adjust replaces each element of a list with the result of fn,
+// e.g. to adjust holdings for a corporate action.
+// Elements held by pointer may be adjusted in place.
+func (l *LinkedList) Adjust(fn func(instrument.Financial) instrument.Financial) {
+	for node := l.head.next; node != nil; node = node.next {
+		node.Financial = fn(node.Financial)
+	}
 }
 
 func (l *LinkedList) PopToSecurity(c utils.CostMethod) *instrument.Security {
@@ -328,6 +394,17 @@ func (l *HoldingList) Update(q instrument.Quote) error {
 	return l.list[index].Update(q)
 }
 
+// UpdateTrade brings the metrics of a ticker's list up to date with trade t.
+func (l *HoldingList) UpdateTrade(t instrument.Trade) error {
+	var index int16
+
+	if index = Get(l.cache, t.Ticker()); index == -1 {
+		return ErrNoListExists
+	}
+	l.list[index].AddTrade(t)
+	return nil
+}
+
 // Get method for type HoldingList returns a LinkedList and error types.
 func (l *HoldingList) Get(key string) (*LinkedList, error) {
 	var index int16
@@ -380,14 +457,7 @@ func (l *HoldingList) Insert(f instrument.Financial) (err error) {
 
 	// see if we can place new holding in open slot
 	// ... or if we have to allocate new space.
-	l.mu.Lock()
-	if index > l.len {
-		l.list = append(make([]*LinkedList, (index+1)*2), l.list...)
-		l.len = (index + 1) * 2
-	} else {
-		l.len++
-	}
-	l.mu.Unlock()
+	l.grow(index)
 
 	// Check to see if we need to allocate a new Linked list
 	// ... or if we can just push new node.
@@ -395,7 +465,7 @@ func (l *HoldingList) Insert(f instrument.Financial) (err error) {
 	case true:
 		l.list[index] = NewLinkedList(f)
 	case false:
-		l.list[index].Push(NewLinkedNode(f))
+		l.list[index].Push(f)
 	}
 	return nil
 }
@@ -410,27 +480,59 @@ func (l *HoldingList) InsertUpdate(f instrument.Financial, q instrument.Quote) (
 
 	// see if we can place new holding in open slot
 	// ... or if we have to allocate new space.
-	l.mu.Lock()
-	if index > l.len {
-		l.list = append(make([]*LinkedList, (index+1)*2), l.list...)
-		l.len = (index + 1) * 2
-	} else {
-		l.len++
-	}
-	l.mu.Unlock()
+	l.grow(index)
 
 	// Check to see if we need to allocate a new Linked list
 	// ... or if we can just push new node.
 	switch new {
 	case true:
 		l.list[index] = NewLinkedList(f)
+		if l.list[index].Quote == nil {
+			l.list[index].Asset = instrument.NewAsset(&q)
+		}
 	case false:
-		l.list[index].Push(NewLinkedNode(f))
+		l.list[index].Push(f)
 	}
 	l.GetByIndex(index).Update(q)
 	return nil
 }
 
+// grow allocates space in a HoldingList for a linked list at index.
+func (l *HoldingList) grow(index int16) {
+	l.mu.Lock()
+	if int(index) >= len(l.list) {
+		l.list = append(l.list, make([]*LinkedList, int(index)+1-len(l.list))...)
+	}
+	l.len = int16(len(l.list))
+	l.mu.Unlock()
+}
+
+// Rename moves the linked list of ticker old to ticker new, e.g. on a change of symbol.
+// If ticker new already has a list, the elements of old are moved to the end of it.
+// The elements of the list are not renamed.
+func (l *HoldingList) Rename(old, new string) error {
+	index := Get(l.cache, old)
+	if index == -1 {
+		return ErrNoListExists
+	}
+	if newIndex := Get(l.cache, new); newIndex != -1 {
+		l.mu.Lock()
+		l.list[newIndex].splice(l.list[index])
+		l.list[index] = nil
+		l.mu.Unlock()
+
+		Delete(l.cache, old)
+		return nil
+	}
+
+	l.cache.mu.Lock()
+	delete(l.cache.items, old)
+	l.cache.items[new] = index
+	l.cache.mu.Unlock()
+
+	return nil
+}
+
 // Delete ... TODO
 func (l *HoldingList) Delete(key string) error {
 	var index int16
@@ -441,7 +543,8 @@ func (l *HoldingList) Delete(key string) error {
 	}
 
 	l.mu.Lock()
-	if linkedHoldings = l.list[index]; linkedHoldings.tail != nil {
+	if linkedHoldings = l.list[index]; linkedHoldings.PeekFront() != nil {
+		l.mu.Unlock()
 		return ErrListNotEmpty
 	}
 	l.list[index] = nil
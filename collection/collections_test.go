@@ -58,10 +58,11 @@ func TestPut(t *testing.T) {
 		wantErr   bool
 	}{
 		{"Base case", args{mockLookupCache([]string{}, []int16{}), "AAPL"}, 0, false},
+		// existing keys keep their slot, which HoldingList.Insert pushes onto.
 		{"Check for existing ticker",
 			args{
 				mockLookupCache([]string{"AAPL"}, []int16{}),
-				"AAPL"}, 1, true},
+				"AAPL"}, 0, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -82,7 +83,7 @@ func mockHolding() *instrument.Holding {
 	askDatedMetric := &utils.DatedMetric{Amount: ask, Date: time.Time{}}
 
 	i := &instrument.Holding{
-		Instrument: instrument.NewInstrument("GOOGL", 10),
+		Instrument: *instrument.NewInstrument("GOOGL", 10),
 		BuyPrice:   askDatedMetric,
 	}
 	return i
@@ -94,11 +95,8 @@ func mockTick() instrument.Tick {
 	bidSz := utils.Amount(10)
 	askSz := utils.Amount(10)
 
-	return instrument.Tick{
-		Ticker: "GOOGL",
-		Bid:    bid, Ask: ask,
-		BidSize: bidSz, AskSize: askSz,
-		Timestamp: time.Time{}}
+	tick := instrument.NewTick(bidSz, askSz, instrument.NewQuote(bid, ask, time.Time{}, *instrument.NewInstrument("GOOGL", 0)))
+	return *tick
 }
 
 func mockLinkedList() *LinkedList {
@@ -109,7 +107,7 @@ func mockLinkedList() *LinkedList {
 
 	return NewLinkedList(
 		instrument.Asset{
-			Quote:   mockHolding().Instrument,
+			Quote:   &instrument.Quote{Instrument: mockHolding().Instrument},
 			LastAsk: askDatedMetric, LastBid: bidDatedMetric,
 			MaxBid: bidDatedMetric, MaxAsk: askDatedMetric,
 			MinAsk: askDatedMetric, MinBid: bidDatedMetric})
@@ -158,3 +156,128 @@ func TestLinkedList_Push(t *testing.T) {
 		})
 	}
 }
+
+func TestHoldingList_UpdateTrade(t *testing.T) {
+	ts := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		trade      *instrument.Trade
+		wantErr    error
+		wantVolume utils.Amount
+	}{
+		{"Held ticker", instrument.NewTrade("GOOGL", utils.FloatAmount(50.25), 100, "", ts), nil, 100},
+		{"Ticker not held", instrument.NewTrade("AAPL", utils.FloatAmount(150.00), 100, "", ts), ErrNoListExists, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewHoldingList()
+			if err := l.Insert(mockHolding()); err != nil {
+				t.Fatalf("HoldingList.Insert() error = %v", err)
+			}
+
+			if err := l.UpdateTrade(*tt.trade); err != tt.wantErr {
+				t.Fatalf("HoldingList.UpdateTrade() error = %v, want %v", err, tt.wantErr)
+			}
+			list, err := l.Get("GOOGL")
+			if err != nil {
+				t.Fatalf("HoldingList.Get() error = %v", err)
+			}
+			if list.TradedVolume != tt.wantVolume {
+				t.Errorf("LinkedList.TradedVolume = %d, want %d", list.TradedVolume, tt.wantVolume)
+			}
+			if tt.wantErr == nil && (list.LastTrade == nil || list.LastTrade.Amount != tt.trade.Price) {
+				t.Errorf("LinkedList.LastTrade = %+v, want %d", list.LastTrade, tt.trade.Price)
+			}
+		})
+	}
+}
+
+func TestHoldingList_Delete(t *testing.T) {
+	tests := []struct {
+		name    string
+		empty   bool
+		wantErr error
+	}{
+		{"Populated list", false, ErrListNotEmpty},
+		{"Empty list", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewHoldingList()
+			if err := l.Insert(mockHolding()); err != nil {
+				t.Fatalf("HoldingList.Insert() error = %v", err)
+			}
+			if tt.empty {
+				list, _ := l.Get("GOOGL")
+				list.remove(list.PeekFront())
+			}
+
+			if err := l.Delete("GOOGL"); err != tt.wantErr {
+				t.Fatalf("HoldingList.Delete() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := l.Get("GOOGL"); (err == ErrNoListExists) != tt.empty {
+				t.Errorf("HoldingList.Get() error = %v after delete", err)
+			}
+
+			// deleted tickers can be held again.
+			if err := l.Insert(mockHolding()); err != nil {
+				t.Fatalf("HoldingList.Insert() error = %v", err)
+			}
+			if _, err := l.Get("GOOGL"); err != nil {
+				t.Errorf("HoldingList.Get() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestHoldingList_Rename(t *testing.T) {
+	tests := []struct {
+		name       string
+		new        string
+		wantNodes  int
+		wantVolume utils.Amount
+	}{
+		{"New ticker", "GOOGX", 1, 10},
+		{"Held ticker", "GOOGL", 2, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewHoldingList()
+			for _, q := range []instrument.Quote{
+				{Instrument: *instrument.NewInstrument("GOOGL", 5)},
+				{Instrument: *instrument.NewInstrument("GOOG", 10)},
+			} {
+				if err := l.Insert(q); err != nil {
+					t.Fatalf("HoldingList.Insert() error = %v", err)
+				}
+			}
+			old, _ := l.Get("GOOG")
+			node := old.PeekFront()
+
+			if err := l.Rename("GOOG", tt.new); err != nil {
+				t.Fatalf("HoldingList.Rename() error = %v", err)
+			}
+			if _, err := l.Get("GOOG"); err != ErrNoListExists {
+				t.Errorf("HoldingList.Get() old ticker error = %v, want %v", err, ErrNoListExists)
+			}
+			list, err := l.Get(tt.new)
+			if err != nil {
+				t.Fatalf("HoldingList.Get() error = %v", err)
+			}
+
+			var nodes int
+			var found bool
+			for n := list.PeekFront(); n != nil; n = n.Next() {
+				nodes++
+				found = found || n == node
+			}
+			if nodes != tt.wantNodes || !found {
+				t.Errorf("HoldingList.Rename() list holds %d nodes, found renamed node %v, want %d nodes", nodes, found, tt.wantNodes)
+			}
+			if got := list.Volume(0); got != tt.wantVolume {
+				t.Errorf("HoldingList.Rename() volume = %d, want %d", got, tt.wantVolume)
+			}
+		})
+	}
+}
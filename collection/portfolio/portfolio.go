@@ -29,6 +29,11 @@ func New() *Portfolio {
 	return &port
 }
 
+// Holdings returns the portfolio's active holdings.
+func (port *Portfolio) Holdings() *collection.HoldingList {
+	return port.active
+}
+
 func (port *Portfolio) Insert(h *instrument.Holding, q instrument.Quote) error {
 	return port.active.InsertUpdate(h, q)
 }
@@ -41,6 +46,11 @@ func (port *Portfolio) Update(q instrument.Quote) error {
 	return port.active.Update(q)
 }
 
+// UpdateTrade brings the metrics of an active position up to date with trade t.
+func (port *Portfolio) UpdateTrade(t instrument.Trade) error {
+	return port.active.UpdateTrade(t)
+}
+
 // Pop returns a LinkedNode struct, will return element at head.next or tail
 // position depending on the CostMethod specified.
 func (port *Portfolio) Pop(key string, c utils.CostMethod) (*collection.LinkedNode, error) {
@@ -68,7 +78,15 @@ func (port *Portfolio) Peek(key string, c utils.CostMethod) *collection.LinkedNo
 func (port *Portfolio) UpdateCash(delta utils.Amount) {
 	port.mu.Lock()
 	port.cash += delta
-	port.mu.Lock()
+	port.mu.Unlock()
+}
+
+// Cash returns the portfolio's cash balance.
+func (port *Portfolio) Cash() utils.Amount {
+	port.mu.RLock()
+	cash := port.cash
+	port.mu.RUnlock()
+	return cash
 }
 
 func (port *Portfolio) GetList(key string) (*collection.LinkedList, error) {
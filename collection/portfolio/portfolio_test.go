@@ -4,61 +4,74 @@ import (
 	"testing"
 	"time"
 
-	"github.com/jakeschurch/porttools/collection/portfolio"
-	"github.com/jakeschurch/porttools/instrument/holding"
+	"github.com/jakeschurch/porttools/instrument"
 	"github.com/jakeschurch/porttools/utils"
 )
 
-var (
-	startingCash = utils.FloatAmount(10000.00)
-	port         = portfolio.NewPortfolio(startingCash)
-	newHolding   = new(holding.Holding)
-	txAmount     Amount
-)
-
-func remock() {
-	startingCash = utils.FloatAmount(10000.00)
-	port = NewPortfolio(startingCash)
-
-	// Setup new holding
+func mockHolding(ticker string, volume utils.Amount) (*instrument.Holding, instrument.Quote) {
 	ask := utils.FloatAmount(50.00)
 	bid := utils.FloatAmount(49.50)
-	bidDatedMetric := &datedMetric{Amount: bid, Date: time.Time{}}
-	askDatedMetric := &datedMetric{Amount: ask, Date: time.Time{}}
+	askDatedMetric := &utils.DatedMetric{Amount: ask, Date: time.Time{}}
 
-	newHolding = &holding.Holding{
-		Ticker:   "GOOGL",
-		Volume:   10.00,
-		NumTicks: 1,
-		BuyPrice: askDatedMetric,
-		LastAsk:  askDatedMetric, MaxAsk: askDatedMetric, MinAsk: askDatedMetric,
-		LastBid: bidDatedMetric, MaxBid: bidDatedMetric, MinBid: bidDatedMetric,
-	}
-	txAmount = newHolding.BuyPrice.Amount * newHolding.Volume
+	i := *instrument.NewInstrument(ticker, volume)
+	return instrument.NewHolding(i, askDatedMetric), *instrument.NewQuote(bid, ask, time.Time{}, i)
 }
 
-func TestPortfolio_applyDelta(t *testing.T) {
-	remock()
-
-	endCash := DivideAmt(startingCash, utils.FloatAmount(2.00))
-	port.applyDelta(-endCash)
-
-	if port.cash != endCash {
-		t.Errorf("Expected %d, got %d", endCash, port.cash)
+func TestPortfolio_UpdateCash(t *testing.T) {
+	tests := []struct {
+		name   string
+		deltas []utils.Amount
+		want   utils.Amount
+	}{
+		{"Credit", []utils.Amount{utils.FloatAmount(10000.00)}, utils.FloatAmount(10000.00)},
+		{"Debit to half", []utils.Amount{utils.FloatAmount(10000.00), -utils.FloatAmount(5000.00)}, utils.FloatAmount(5000.00)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := New()
+			for _, delta := range tt.deltas {
+				port.UpdateCash(delta)
+			}
+			if got := port.Cash(); got != tt.want {
+				t.Errorf("Portfolio.Cash() = %d, want %d", got, tt.want)
+			}
+		})
 	}
 }
 
-func TestPortfolio_AddHolding(t *testing.T) {
-	remock()
-
-	err := port.AddHolding(newHolding, -txAmount)
-	if err != nil {
-		t.Errorf("Expected nil, got %s", err)
+func TestPortfolio_Insert(t *testing.T) {
+	tests := []struct {
+		name       string
+		volumes    []utils.Amount
+		wantVolume utils.Amount
+	}{
+		{"Single holding", []utils.Amount{10}, 10},
+		{"Two holdings", []utils.Amount{10, 5}, 15},
 	}
-	if port.active["GOOGL"].len != 1 {
-		t.Errorf("Expected slice of len 1, got %d", port.active["GOOGL"].len)
-	}
-	if port.active["GOOGL"].totalVolume != newHolding.Volume {
-		t.Errorf("Expected total volume of %d, got %d", newHolding.Volume, port.active["GOOGL"].totalVolume)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := New()
+			for _, volume := range tt.volumes {
+				h, q := mockHolding("GOOGL", volume)
+				if err := port.Insert(h, q); err != nil {
+					t.Fatalf("Portfolio.Insert() error = %v", err)
+				}
+			}
+
+			list, err := port.GetList("GOOGL")
+			if err != nil {
+				t.Fatalf("Portfolio.GetList() error = %v", err)
+			}
+			if got := list.Volume(0); got != tt.wantVolume {
+				t.Errorf("Portfolio volume = %d, want %d", got, tt.wantVolume)
+			}
+			var n int
+			for node := list.PeekFront(); node != nil; node = node.Next() {
+				n++
+			}
+			if n != len(tt.volumes) {
+				t.Errorf("Portfolio holds %d holdings, want %d", n, len(tt.volumes))
+			}
+		})
 	}
 }
@@ -2,16 +2,24 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jakeschurch/porttools/calendar"
 	"github.com/jakeschurch/porttools/output"
 	"github.com/jakeschurch/porttools/utils"
 )
 
 // NOTE: In a contemporary electronic market (circa 2009), low latency trade processing time was qualified as under 10 milliseconds, and ultra-low latency as under 1 millisecond
 
+// ErrEmptyConfig is returned by Load if the config file holds null or an empty config.
+var ErrEmptyConfig = errors.New("Config file is empty")
+
 // Load returns a config item.
 func Load(filename string) (*Config, error) {
 	var config *Config
@@ -23,55 +31,346 @@ func Load(filename string) (*Config, error) {
 	}
 	decoder := json.NewDecoder(file)
 	if decodeErr := decoder.Decode(&config); decodeErr != nil {
-		log.Fatal("Could not read config file")
+		log.Println("Could not read config file")
 		return nil, decodeErr
 	}
+	if config == nil || reflect.ValueOf(*config).IsZero() {
+		return nil, ErrEmptyConfig
+	}
+	config.LoadCalendar()
 	return config, nil
 }
 
 // Config is used as a struct store store configuration data in.
 type Config struct {
-	File struct {
-		Glob          string `json:"glob"`
-		Headers       bool   `json:"headers"`
-		Delim         string `json:"delim"`
-		ExampleDate   string `json:"exampleDate"`
-		TimestampUnit string `json:"timestampUnit"`
-
-		Columns struct {
-			Ticker    uint8 `json:"ticker"`
-			Timestamp uint8 `json:"timestamp"`
-			Bid       uint8 `json:"bid"`
-			BidSize   uint8 `json:"bidSize"`
-			Ask       uint8 `json:"ask"`
-			AskSize   uint8 `json:"askSize"`
-		} `json:"columns"`
-	} `json:"file"`
+	File File `json:"file"`
+	// Trades is an optional set of data files holding trades,
+	// replayed alongside File.
+	Trades *File `json:"trades"`
 
 	Backtest struct {
 		StartCashAmt     float64  `json:"startCashAmt"`
 		IgnoreSecurities []string `json:"ignoreSecurities"`
 		Slippage         float64  `json:"slippage"`
 		Commission       float64  `json:"commission"`
+
+		// IgnoreSecuritiesFile lists further securities to ignore, one ticker per line.
+		IgnoreSecuritiesFile string `json:"ignoreSecuritiesFile"`
+		// Securities, if given, limits the backtest to a universe of tickers.
+		Securities []string `json:"securities"`
+		// SecuritiesFile lists further securities in the universe, one ticker per line.
+		SecuritiesFile string `json:"securitiesFile"`
+		// CorporateActions is the path of a file of splits, dividends and symbol changes
+		// applied to positions as of their ex-dates.
+		CorporateActions string `json:"corporateActions"`
 	} `json:"backtest"`
 
 	Simulation struct {
 		StartDate  string           `json:"startDate"`
 		EndDate    string           `json:"endDate"`
-		BarRate    time.Duration    `json:"barRate"`
+		BarRate    BarDuration      `json:"barRate"`
 		Costmethod utils.CostMethod `json:"costmethod"`
+		// BarFill is one of the BarFill constants, used when backtesting on bar files.
+		BarFill string `json:"barFill"`
 		// TODO: REVIEW good idea to use go generate for output format and other consts?
 		OutFmt output.Format `json:"outFmt"`
+		// OutputDir is the directory results are written to,
+		// along with quarantined records of data files without a RejectsFile.
+		OutputDir string `json:"outputDir"`
+		// ReplaySpeed paces a replay at a multiple of wall-clock speed, e.g. 1 for real time,
+		// or 60 for a minute of market data a second.
+		// Ticks are replayed as fast as they can be processed if not given.
+		ReplaySpeed float64 `json:"replaySpeed"`
+		// ReplayMaxGap caps the market time a paced replay waits out between ticks,
+		// e.g. across market closes.
+		ReplayMaxGap BarDuration `json:"replayMaxGap"`
 		//  IngestRate measures how many bars to skip
 		// IngestRate BarDuration `json:"ingestRate"`
 	} `json:"simulation"`
 
+	Strategy struct {
+		// Params are the parameters of the strategy's algorithm, read through its StrategyContext.
+		Params map[string]interface{} `json:"params"`
+	} `json:"strategy"`
+
+	// Calendar restricts a simulation to the trading days and a session of an exchange.
+	Calendar struct {
+		// File is the path of a calendar file, as read by calendar.Load.
+		File     string `json:"file"`
+		Exchange string `json:"exchange"`
+		// Session is the name of the session ticks are routed to the strategy in,
+		// calendar.Regular if not given.
+		Session string `json:"session"`
+	} `json:"calendar"`
+
+	// Validation configures the checks of a data-quality validation pass.
+	Validation struct {
+		// JumpSigma is the number of standard deviations a change in mid price
+		// must move by to be flagged as a price jump.
+		JumpSigma float64 `json:"jumpSigma"`
+		// StaleAfter is how long a quote may be left unchanged before it is flagged as stale.
+		StaleAfter BarDuration `json:"staleAfter"`
+	} `json:"validation"`
+
 	Benchmark struct {
 		Use    bool `json:"use"`
 		Update bool `json:"update"`
 	} `json:"benchmark"`
+
+	// calendar is the exchange calendar loaded for the config, shared by its copies.
+	calendar *loadedCalendar
+}
+
+// loadedCalendar is the result of loading a config's calendar,
+// along with the calendar settings it was loaded for.
+type loadedCalendar struct {
+	file, exchangeName, sessionName string
+
+	exchange *calendar.Exchange
+	session  calendar.Session
+	err      error
+}
+
+// LoadCalendar loads the exchange calendar of the config, which Exchange and Location
+// return from then on, rather than reading the calendar file on each call.
+// The calendar is read only if it has not been loaded since it was last set.
+// Load calls LoadCalendar, as does the first call to Exchange or Location of configs built otherwise,
+// which should therefore be loaded before they are shared between goroutines.
+// Any error loading the calendar is returned by Exchange and Location.
+func (c *Config) LoadCalendar() {
+	if c.calendarLoaded() {
+		return
+	}
+	loaded := &loadedCalendar{file: c.Calendar.File, exchangeName: c.Calendar.Exchange, sessionName: c.Calendar.Session}
+	loaded.exchange, loaded.session, loaded.err = c.loadExchange()
+	c.calendar = loaded
+}
+
+// File is used to store configuration data of the data files replayed by a simulation.
+type File struct {
+	// Glob matches the data files to replay. Files compressed with gzip, bzip2 or zstd are
+	// decompressed as they are read. Decompressors of other formats can be registered
+	// with porttools.RegisterDecompressor.
+	// A Glob of "-" reads from standard input, dated at Simulation.StartDate, which must be set.
+	Glob          string `json:"glob"`
+	Headers       bool   `json:"headers"`
+	Delim         string `json:"delim"`
+	ExampleDate   string `json:"exampleDate"`
+	TimestampUnit string `json:"timestampUnit"`
+
+	// Kind is one of the file Kind constants.
+	Kind string `json:"kind"`
+	// TimestampFormat is one of the Timestamp format constants,
+	// or else a reference time layout as used by time.Parse.
+	TimestampFormat string `json:"timestampFormat"`
+	// TimeZone is the IANA name of the exchange time zone, e.g. "America/New_York".
+	// Defaults to the time zone of the calendar's exchange, or else UTC.
+	TimeZone string `json:"timeZone"`
+
+	// OnBadRecord is one of the OnBadRecord policy constants.
+	OnBadRecord string `json:"onBadRecord"`
+	// RejectsFile is where quarantined records are written, if not given
+	// rejects.csv, or trades_rejects.csv for trade files, in the simulation's OutputDir.
+	RejectsFile string `json:"rejectsFile"`
+	// Cache is the path of a binary tick cache to replay in place of the data files.
+	// The cache is built from the data files if it does not exist,
+	// and rebuilt whenever the data files or their configuration change.
+	// Caches cannot be used with a Glob of "-", standard input.
+	Cache string `json:"cache"`
+	// Parsers is the number of goroutines records are parsed across.
+	// Defaults to the number of CPUs that can be used.
+	Parsers int `json:"parsers"`
+	// TradeType is the value of the Type column that trade records are marked with.
+	TradeType string `json:"tradeType"`
+
+	Columns struct {
+		Ticker    Column `json:"ticker"`
+		Timestamp Column `json:"timestamp"`
+		Bid       Column `json:"bid"`
+		BidSize   Column `json:"bidSize"`
+		Ask       Column `json:"ask"`
+		AskSize   Column `json:"askSize"`
+
+		Open   Column `json:"open"`
+		High   Column `json:"high"`
+		Low    Column `json:"low"`
+		Close  Column `json:"close"`
+		Volume Column `json:"volume"`
+
+		Price Column `json:"price"`
+		Size  Column `json:"size"`
+		// Conditions is an optional column of trade conditions.
+		Conditions *Column `json:"conditions"`
+		// Venue is an optional column of the exchange each quote was made on.
+		// Quotes of each venue are consolidated into a national best bid and offer.
+		Venue *Column `json:"venue"`
+		// Type is an optional column used to tell trade records from quote records,
+		// in files holding both. Records are trades if their type is TradeType.
+		Type *Column `json:"type"`
+	} `json:"columns"`
+}
+
+// Location returns the exchange time zone that file dates and timestamps are given in.
+// If no time zone is configured, the time zone of the calendar's exchange is returned,
+// or UTC if no calendar is configured either.
+func (c *Config) Location() (*time.Location, error) {
+	if c.File.TimeZone != "" {
+		return time.LoadLocation(c.File.TimeZone)
+	}
+	exchange, _, err := c.Exchange()
+	if err != nil || exchange == nil {
+		return time.UTC, err
+	}
+	return exchange.Location(), nil
+}
+
+// Exchange returns the calendar of the exchange the simulation trades on,
+// along with the session ticks are routed to the strategy in.
+// A nil exchange is returned if no calendar is configured.
+// The calendar is loaded by LoadCalendar unless it already has been.
+func (c *Config) Exchange() (*calendar.Exchange, calendar.Session, error) {
+	c.LoadCalendar()
+	return c.calendar.exchange, c.calendar.session, c.calendar.err
+}
+
+// calendarLoaded reports whether the calendar loaded by LoadCalendar is the one configured.
+func (c *Config) calendarLoaded() bool {
+	l := c.calendar
+	return l != nil && l.file == c.Calendar.File &&
+		l.exchangeName == c.Calendar.Exchange && l.sessionName == c.Calendar.Session
+}
+
+// loadExchange reads the calendar of the exchange the simulation trades on from the calendar file.
+func (c *Config) loadExchange() (*calendar.Exchange, calendar.Session, error) {
+	if c.Calendar.File == "" {
+		return nil, calendar.Session{}, nil
+	}
+	cal, err := calendar.Load(c.Calendar.File)
+	if err != nil {
+		return nil, calendar.Session{}, err
+	}
+	exchange, err := cal.Exchange(c.Calendar.Exchange)
+	if err != nil {
+		return nil, calendar.Session{}, err
+	}
+
+	name := c.Calendar.Session
+	if name == "" {
+		name = calendar.Regular
+	}
+	session, err := exchange.Session(name)
+	if err != nil {
+		return nil, calendar.Session{}, err
+	}
+	return exchange, session, nil
+}
+
+// File kinds specify the type of records held in data files.
+const (
+	// KindQuotes files hold bid and ask quotes, with sizes.
+	KindQuotes = "quotes"
+	// KindBars files hold open, high, low and close prices, with volume.
+	KindBars = "bars"
+	// KindTrades files hold trade prices and sizes, with optional trade conditions.
+	KindTrades = "trades"
+	// KindITCH files are NASDAQ TotalView-ITCH 5.0 binary captures,
+	// from which quotes and trades are built.
+	KindITCH = "itch"
+)
+
+// BarFill rules specify the price that market orders are filled at when backtesting on bars.
+const (
+	// BarFillNextOpen fills orders at the open of the ticker's next bar.
+	BarFillNextOpen = "nextOpen"
+	// BarFillClose fills orders at the close of the bar they were placed on.
+	BarFillClose = "close"
+)
+
+// Timestamp formats specify how the timestamp column of a data file is parsed.
+const (
+	// TimestampDuration timestamps are durations in TimestampUnit since the start of the file date.
+	TimestampDuration = "duration"
+	// TimestampEpoch timestamps are integers in TimestampUnit since the Unix epoch.
+	TimestampEpoch = "epoch"
+	// TimestampRFC3339 timestamps are absolute times, e.g. "2017-08-14T09:30:00.000001-04:00".
+	TimestampRFC3339 = "rfc3339"
+	// TimestampClock timestamps are times of day on the file date, e.g. "09:30:00.000001".
+	TimestampClock = "clock"
+)
+
+// OnBadRecord policies specify what is done with records that cannot be loaded.
+const (
+	// OnBadRecordSkip drops bad records, counting them by reason.
+	OnBadRecordSkip = "skip"
+	// OnBadRecordFail stops the simulation at the first bad record.
+	OnBadRecordFail = "fail"
+	// OnBadRecordQuarantine drops bad records, writing them to RejectsFile.
+	OnBadRecordQuarantine = "quarantine"
+)
+
+// Column refers to a field of a data file record,
+// either by its position or by the name given in the file's headers.
+type Column struct {
+	Index int
+	Name  string
 }
 
-// BarDuration is used to register tick intake.
-// REVIEW: needed?
+// UnmarshalJSON allows a column to be configured as either an index, e.g. `16`,
+// or a header name, e.g. `"BID_PRICE"`.
+func (c *Column) UnmarshalJSON(data []byte) error {
+	*c = Column{}
+	if err := json.Unmarshal(data, &c.Name); err == nil {
+		return nil
+	}
+	return json.Unmarshal(data, &c.Index)
+}
+
+// Resolve returns the position of a column in a record.
+// Named columns are looked up in headers, and index columns must not be negative.
+// Records must be checked to hold the position returned before it is read.
+func (c Column) Resolve(headers []string) (int, error) {
+	if c.Name == "" {
+		if c.Index < 0 {
+			return -1, &ColumnError{Index: c.Index}
+		}
+		return c.Index, nil
+	}
+	for i := range headers {
+		if strings.TrimSpace(strings.TrimPrefix(headers[i], "\uFEFF")) == c.Name {
+			return i, nil
+		}
+	}
+	return -1, &ColumnError{Name: c.Name}
+}
+
+// ColumnError indicates that a named column could not be found in a file's headers,
+// or that an index column is negative.
+type ColumnError struct {
+	Name  string
+	Index int
+}
+
+func (e *ColumnError) Error() string {
+	if e.Name == "" {
+		return "column index " + strconv.Itoa(e.Index) + " is negative"
+	}
+	return "column " + strconv.Quote(e.Name) + " not found in file headers"
+}
+
+// BarDuration is the interval ticks are aggregated into bars over.
 type BarDuration time.Duration
+
+// UnmarshalJSON allows a bar duration to be configured as either a duration string,
+// e.g. `"5m"`, or an integer number of nanoseconds.
+func (d *BarDuration) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err != nil {
+		return json.Unmarshal(data, (*int64)(d))
+	}
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		return err
+	}
+	*d = BarDuration(duration)
+	return nil
+}
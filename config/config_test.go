@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestColumn_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Column
+		wantErr bool
+	}{
+		{"Index column", `16`, Column{Index: 16}, false},
+		{"Named column", `"BID_PRICE"`, Column{Name: "BID_PRICE"}, false},
+		{"Invalid column", `true`, Column{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Column
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Column.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Column.UnmarshalJSON() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestColumn_Resolve(t *testing.T) {
+	headers := []string{"TIME", "SYM", "BID_PRICE"}
+
+	tests := []struct {
+		name    string
+		col     Column
+		want    int
+		wantErr bool
+	}{
+		{"Index column", Column{Index: 2}, 2, false},
+		{"Named column", Column{Name: "SYM"}, 1, false},
+		{"Missing named column", Column{Name: "BID"}, -1, true},
+		{"Negative index column", Column{Index: -1}, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.col.Resolve(headers)
+			if _, ok := err.(*ColumnError); ok != tt.wantErr {
+				t.Fatalf("Column.Resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Column.Resolve() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBarDuration_UnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    BarDuration
+		wantErr bool
+	}{
+		{"Duration string", `"5m"`, BarDuration(5 * time.Minute), false},
+		{"Nanoseconds", `60000000000`, BarDuration(time.Minute), false},
+		{"Invalid duration", `"5 minutes"`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got BarDuration
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BarDuration.UnmarshalJSON() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("BarDuration.UnmarshalJSON() = %v, want %v", time.Duration(got), time.Duration(tt.want))
+			}
+		})
+	}
+}
+
+func TestConfig_Location(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calendarFile := filepath.Join(dir, "calendar.json")
+	data := `{"XNYS": {"timeZone": "America/New_York", "sessions": {"regular": {"open": "09:30", "close": "16:00"}}}}`
+	if err = ioutil.WriteFile(calendarFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = time.LoadLocation("America/New_York"); err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		name     string
+		timeZone string
+		calendar string
+		want     string
+	}{
+		{"Default", "", "", "UTC"},
+		{"Configured time zone", "Europe/London", calendarFile, "Europe/London"},
+		{"Exchange time zone", "", calendarFile, "America/New_York"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(Config)
+			cfg.File.TimeZone = tt.timeZone
+			cfg.Calendar.File, cfg.Calendar.Exchange = tt.calendar, "XNYS"
+
+			got, err := cfg.Location()
+			if err != nil {
+				t.Fatalf("Config.Location() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Config.Location() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfig_LoadCalendar(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	calendarFile := filepath.Join(dir, "calendar.json")
+	data := `{"XNYS": {"timeZone": "UTC", "sessions": {"regular": {"open": "09:30", "close": "16:00"}}}}`
+	if err = ioutil.WriteFile(calendarFile, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := new(Config)
+	cfg.Calendar.File, cfg.Calendar.Exchange = calendarFile, "XNYS"
+	cfg.LoadCalendar()
+
+	missing := *cfg
+	missing.Calendar.Exchange = "XLON"
+	missing.LoadCalendar()
+
+	// the loaded calendar is returned once its file is gone, along with any error loading it.
+	if err = os.Remove(calendarFile); err != nil {
+		t.Fatal(err)
+	}
+	if exchange, _, err := cfg.Exchange(); exchange == nil || err != nil {
+		t.Errorf("Config.Exchange() = %v, %v, want the loaded exchange", exchange, err)
+	}
+	// loading the calendar again does not re-read it.
+	cfg.LoadCalendar()
+	if exchange, _, err := cfg.Exchange(); exchange == nil || err != nil {
+		t.Errorf("Config.Exchange() after LoadCalendar() = %v, %v, want the loaded exchange", exchange, err)
+	}
+	if _, _, err := missing.Exchange(); err == nil || os.IsNotExist(err) {
+		t.Errorf("Config.Exchange() error = %v, want the error loading XLON", err)
+	}
+
+	// copies configured with another calendar do not use the loaded one.
+	other := *cfg
+	other.Calendar.Session = "extended"
+	if _, _, err := other.Exchange(); !os.IsNotExist(err) {
+		t.Errorf("Config.Exchange() of a changed copy error = %v, want the calendar file to be read", err)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name    string
+		data    string
+		wantErr error
+	}{
+		{"Config", `{"file": {"glob": "*.csv"}}`, nil},
+		{"Null config", `null`, ErrEmptyConfig},
+		{"Empty config", `{}`, ErrEmptyConfig},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(dir, strconv.Itoa(i)+".json")
+			if err := ioutil.WriteFile(filename, []byte(tt.data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := Load(filename)
+			if err != tt.wantErr {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got.File.Glob != "*.csv" {
+				t.Errorf("Load() = %+v, want the config file's glob", got)
+			}
+		})
+	}
+}
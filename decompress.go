@@ -0,0 +1,123 @@
+package porttools
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	// ErrUnsupportedCompression indicates that a file's compression was detected,
+	// but no decompressor has been registered for it.
+	ErrUnsupportedCompression = errors.New("No decompressor registered for file compression")
+)
+
+// Decompressor returns a reader of the decoded contents of a compressed stream.
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+// compression identifies a compressed file format by its extension and magic bytes.
+type compression struct {
+	ext        string
+	magic      []byte
+	decompress Decompressor
+}
+
+var (
+	compressionsMu sync.RWMutex
+	compressions   = []*compression{
+		{ext: ".gz", magic: []byte{0x1f, 0x8b}, decompress: gunzip},
+		{ext: ".bz2", magic: []byte("BZh"), decompress: bunzip2},
+		{ext: ".zst", magic: []byte{0x28, 0xb5, 0x2f, 0xfd}, decompress: unzstd},
+	}
+)
+
+// RegisterDecompressor sets the decompressor used for files with the given extension
+// or starting with the given magic bytes, replacing any that is already registered.
+// For example, xz support can be added with a third-party package:
+//
+//	porttools.RegisterDecompressor(".xz", []byte{0xfd, '7', 'z', 'X', 'Z', 0x00},
+//		func(r io.Reader) (io.ReadCloser, error) {
+//			d, err := xz.NewReader(r)
+//			if err != nil {
+//				return nil, err
+//			}
+//			return ioutil.NopCloser(d), nil
+//		})
+func RegisterDecompressor(ext string, magic []byte, d Decompressor) {
+	compressionsMu.Lock()
+	defer compressionsMu.Unlock()
+
+	for _, c := range compressions {
+		if c.ext == ext || bytes.Equal(c.magic, magic) {
+			c.ext, c.magic, c.decompress = ext, magic, d
+			return
+		}
+	}
+	compressions = append(compressions, &compression{ext: ext, magic: magic, decompress: d})
+}
+
+// decompress wraps r with a decompressor if the compression of the named file
+// can be detected from its extension or magic bytes.
+// Uncompressed files are returned as they are read.
+func decompress(name string, r io.Reader) (io.ReadCloser, error) {
+	// the registered compressions are copied, so that peeking a reader that blocks,
+	// such as an idle pipe, does not hold up RegisterDecompressor.
+	compressionsMu.RLock()
+	registered := make([]compression, len(compressions))
+	for i := range compressions {
+		registered[i] = *compressions[i]
+	}
+	compressionsMu.RUnlock()
+
+	var c *compression
+	ext := strings.ToLower(filepath.Ext(name))
+	for i := range registered {
+		if registered[i].ext == ext {
+			c = &registered[i]
+			break
+		}
+	}
+
+	buffered := bufio.NewReader(r)
+	for i := 0; c == nil && i < len(registered); i++ {
+		magic, _ := buffered.Peek(len(registered[i].magic))
+		if len(magic) > 0 && bytes.Equal(magic, registered[i].magic) {
+			c = &registered[i]
+		}
+	}
+
+	switch {
+	case c == nil:
+		return ioutil.NopCloser(buffered), nil
+	case c.decompress == nil:
+		return nil, ErrUnsupportedCompression
+	}
+	return c.decompress(buffered)
+}
+
+func gunzip(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func bunzip2(r io.Reader) (io.ReadCloser, error) {
+	return ioutil.NopCloser(bzip2.NewReader(r)), nil
+}
+
+// unzstd decodes a zstd stream on the reading goroutine, so that memory stays flat as it is read.
+// Closing the returned reader releases the decoder.
+func unzstd(r io.Reader) (io.ReadCloser, error) {
+	d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return d.IOReadCloser(), nil
+}
@@ -0,0 +1,162 @@
+package porttools
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const mockRecord = "1,AAPL,50.00,10,50.10,10\n"
+
+func mockGzip() []byte {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(mockRecord))
+	w.Close()
+	return buf.Bytes()
+}
+
+func mockZstd() []byte {
+	var buf bytes.Buffer
+
+	w, _ := zstd.NewWriter(&buf)
+	w.Write([]byte(mockRecord))
+	w.Close()
+	return buf.Bytes()
+}
+
+// mockBzip2 is mockRecord compressed by the bzip2 command-line utility.
+var mockBzip2 = []byte{
+	0x42, 0x5a, 0x68, 0x39, 0x31, 0x41, 0x59, 0x26, 0x53, 0x59, 0x3b, 0x4f,
+	0xb1, 0xed, 0x00, 0x00, 0x07, 0xde, 0x00, 0x00, 0x10, 0x00, 0x05, 0x62,
+	0x00, 0x20, 0x04, 0x40, 0x00, 0x20, 0x00, 0x31, 0x06, 0x4c, 0x40, 0x94,
+	0xf5, 0x23, 0x69, 0x1c, 0xad, 0x71, 0x11, 0x61, 0x32, 0x5a, 0x1b, 0xe7,
+	0xe2, 0xee, 0x48, 0xa7, 0x0a, 0x12, 0x07, 0x69, 0xf6, 0x3d, 0xa0,
+}
+
+func Test_decompress(t *testing.T) {
+	tests := []struct {
+		name     string
+		fileName string
+		data     []byte
+		wantErr  error
+	}{
+		{"Uncompressed", "mock_20170814", []byte(mockRecord), nil},
+		{"Gzip by extension", "mock_20170814.csv.gz", mockGzip(), nil},
+		{"Gzip by magic bytes", "mock_20170814", mockGzip(), nil},
+		{"Bzip2 by magic bytes", "mock_20170814", mockBzip2, nil},
+		{"Zstd by extension", "mock_20170814.csv.zst", mockZstd(), nil},
+		{"Zstd by magic bytes", "mock_20170814", mockZstd(), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := decompress(tt.fileName, bytes.NewReader(tt.data))
+			if err != tt.wantErr {
+				t.Fatalf("decompress() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer r.Close()
+
+			got, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("decompress() read error = %v", err)
+			}
+			if string(got) != mockRecord {
+				t.Errorf("decompress() = %q, want %q", got, mockRecord)
+			}
+		})
+	}
+}
+
+func TestRegisterDecompressor(t *testing.T) {
+	// upper decodes ".upper" files by upper-casing them, so that its use can be seen.
+	upper := func(r io.Reader) (io.ReadCloser, error) {
+		b, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return ioutil.NopCloser(strings.NewReader(strings.ToUpper(string(b)))), nil
+	}
+	magic := []byte("UPPER")
+
+	// decoders are registered while files are being decompressed.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			RegisterDecompressor(".upper", magic, upper)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if r, err := decompress("mock_20170814.upper", strings.NewReader(mockRecord)); err == nil {
+			r.Close()
+		}
+	}
+	wg.Wait()
+
+	r, err := decompress("mock_20170814.upper", strings.NewReader(mockRecord))
+	if err != nil {
+		t.Fatalf("decompress() error = %v", err)
+	}
+	defer r.Close()
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decompress() read error = %v", err)
+	}
+	if want := strings.ToUpper(mockRecord); string(got) != want {
+		t.Errorf("decompress() = %q, want %q", got, want)
+	}
+}
+
+// readSignaller signals the first read of its reader, before reading from it.
+type readSignaller struct {
+	io.Reader
+	once    sync.Once
+	reading chan struct{}
+}
+
+func (r *readSignaller) Read(p []byte) (int, error) {
+	r.once.Do(func() { close(r.reading) })
+	return r.Reader.Read(p)
+}
+
+func Test_decompress_blockedRead(t *testing.T) {
+	// a read from an idle pipe blocks until it is closed.
+	pr, pw := io.Pipe()
+	r := &readSignaller{Reader: pr, reading: make(chan struct{})}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if rc, err := decompress("-", r); err == nil {
+			rc.Close()
+		}
+	}()
+	defer func() {
+		pw.Close()
+		<-done
+	}()
+	<-r.reading
+
+	// decompressors can be registered while the pipe is being peeked for magic bytes.
+	registered := make(chan struct{})
+	go func() {
+		RegisterDecompressor(".blocked", []byte("BLOCKED"), nil)
+		close(registered)
+	}()
+	select {
+	case <-registered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RegisterDecompressor() blocked on a decompress of an idle pipe")
+	}
+}
@@ -0,0 +1,56 @@
+package porttools
+
+import (
+	"time"
+
+	"github.com/jakeschurch/porttools/collection"
+	"github.com/jakeschurch/porttools/order"
+)
+
+// Besides market data, which is read from a simulation's source, a simulation's event loop
+// handles the events queued by the simulation itself. Queued events are handled in time order,
+// before any market data of the same time, so that the events caused by market data
+// are handled before the market moves on.
+//
+// Orders are submitted to the OMS, then filled against the next quote of their ticker,
+// rather than the quote that led to them being placed. Fills are applied as that quote is
+// processed, before it is passed to the strategy, so the strategy sees the filled orders.
+
+// orderSubmitted is an order submitted to the OMS, to be filled against the next quote of its ticker.
+type orderSubmitted struct {
+	order *order.Order
+	// closes is the node of the open order an exit order closes, nil for entry orders.
+	closes *collection.LinkedNode
+}
+
+// orderCancelled cancels an order that has not yet been filled.
+type orderCancelled struct {
+	order *order.Order
+}
+
+// timerFired runs a function at the time it was scheduled for.
+type timerFired struct {
+	fn func()
+}
+
+// schedule queues fn to be run at t.
+func schedule(events *Queue, t time.Time, fn func()) {
+	events.Enqueue(NewNode(&timerFired{fn: fn}, t))
+}
+
+// scheduleEvery queues fn to be run at from, then every interval after it.
+func scheduleEvery(events *Queue, from time.Time, interval time.Duration, fn func()) {
+	if interval <= 0 {
+		schedule(events, from, fn)
+		return
+	}
+
+	next := from
+	var timer func()
+	timer = func() {
+		fn()
+		next = next.Add(interval)
+		schedule(events, next, timer)
+	}
+	schedule(events, from, timer)
+}
@@ -0,0 +1,221 @@
+package porttools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func TestQueue(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		times []time.Duration
+		want  []int
+	}{
+		{"Empty", nil, nil},
+		{"In order", []time.Duration{0, time.Second, 2 * time.Second}, []int{0, 1, 2}},
+		{"Out of order", []time.Duration{2 * time.Second, 0, time.Second}, []int{1, 2, 0}},
+		{"Ties in enqueued order", []time.Duration{time.Second, 0, time.Second, 0}, []int{1, 3, 0, 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := NewQueue()
+			for i := range tt.times {
+				queue.Enqueue(NewNode(i, open.Add(tt.times[i])))
+			}
+			if queue.Len() != len(tt.want) {
+				t.Errorf("Queue.Len() = %d, want %d", queue.Len(), len(tt.want))
+			}
+
+			var got []int
+			for queue.Peek() != nil {
+				peeked := queue.Peek()
+				if node := queue.Dequeue(); node != peeked {
+					t.Errorf("Queue.Dequeue() = %v, want peeked %v", node, peeked)
+				}
+				got = append(got, peeked.data.(int))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Queue dequeued %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Queue dequeued %v, want %v", got, tt.want)
+					break
+				}
+			}
+			if queue.Dequeue() != nil {
+				t.Error("Queue.Dequeue() of an empty queue != nil")
+			}
+		})
+	}
+}
+
+// mockRoundTripAlgorithm buys a share of each quote it is passed,
+// exiting each open order at the quote after it was filled if exit is set.
+type mockRoundTripAlgorithm struct {
+	exit   bool
+	orders []*order.Order
+}
+
+func (a *mockRoundTripAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+	o := order.New(true, q)
+	a.orders = append(a.orders, o)
+	return o, nil
+}
+
+func (a *mockRoundTripAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	if !a.exit {
+		return nil, ErrOrderNotValid
+	}
+	q := *t.Quote
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+	return order.New(false, q), nil
+}
+
+func TestSimulation_replay_orders(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		exit     bool
+		cancel   bool
+		wantCash utils.Amount
+		wantAsks []float64
+	}{
+		// orders placed on each quote fill at the next quote's ask, the last is left unfilled.
+		{"Fills at next quote", false, false, -utils.FloatAmount(11 + 12 + 13), []float64{11, 12, 13, 13}},
+		// the first order is cancelled before the second quote, so is never filled.
+		{"Cancelled", false, true, -utils.FloatAmount(12 + 13), []float64{10, 12, 13, 13}},
+		// orders are open by the time the quote they fill at is checked for exits,
+		// so the first two orders' exits fill at the bids of the third and fourth quotes.
+		{"Exits at next quote", true, false, -utils.FloatAmount(11 + 12 + 13 - 10 - 11), []float64{11, 12, 13, 13}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mockSimulation(t)
+			algo := &mockRoundTripAlgorithm{exit: tt.exit}
+			sim.SetStrategy(NewStrategy(algo))
+
+			ticks := mockTicks("AAPL", "AAPL", "AAPL", "AAPL")
+			events := make(chan instrument.Event, len(ticks))
+			for i := range ticks {
+				ticks[i].Bid = utils.FloatAmount(float64(8 + i))
+				ticks[i].Ask = utils.FloatAmount(float64(10 + i))
+				ticks[i].Timestamp = open.Add(time.Duration(i) * time.Second)
+				events <- ticks[i]
+			}
+			close(events)
+
+			if tt.cancel {
+				sim.Schedule(open.Add(500*time.Millisecond), func() {
+					sim.OMS().Cancel(algo.orders[0])
+				})
+			}
+			if err := sim.replay(context.Background(), mockEventSource(events), NewSimClock()); err != nil {
+				t.Fatalf("Simulation.replay() error = %v", err)
+			}
+
+			if sim.OMS().Cash() != tt.wantCash {
+				t.Errorf("OMS.Cash() = %d, want %d", sim.OMS().Cash(), tt.wantCash)
+			}
+			for i := range algo.orders {
+				if want := utils.FloatAmount(tt.wantAsks[i]); algo.orders[i].Ask != want {
+					t.Errorf("Order %d filled at %d, want %d", i, algo.orders[i].Ask, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSimulation_schedule(t *testing.T) {
+	sim := mockSimulation(t)
+	sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	ticks := mockTicks("AAPL", "AAPL")
+	events := make(chan instrument.Event, len(ticks))
+	for i := range ticks {
+		ticks[i].Timestamp = open.Add(time.Duration(i) * time.Minute)
+		events <- ticks[i]
+	}
+	close(events)
+
+	var fired []time.Time
+	for _, d := range []time.Duration{time.Minute, 30 * time.Second, 0, 2 * time.Minute, 24 * time.Hour} {
+		sim.Schedule(open.Add(d), func() {
+			fired = append(fired, sim.OMS().Now())
+		})
+	}
+
+	clock := NewSimClock()
+	sim.SetClock(clock)
+	if err := sim.replay(context.Background(), mockEventSource(events), clock); err != nil {
+		t.Fatalf("Simulation.replay() error = %v", err)
+	}
+
+	// timers after the last quote are fired up to the end of its day.
+	want := []time.Time{open, open.Add(30 * time.Second), open.Add(time.Minute), open.Add(2 * time.Minute)}
+	if len(fired) != len(want) {
+		t.Fatalf("Timers fired at %v, want %v", fired, want)
+	}
+	for i := range want {
+		if !fired[i].Equal(want[i]) {
+			t.Errorf("Timer %d fired at %v, want %v", i, fired[i], want[i])
+		}
+	}
+}
+
+func TestSimulation_ScheduleEvery(t *testing.T) {
+	late := time.Date(2017, 8, 14, 23, 59, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		want     int
+	}{
+		// timers are fired up to the end of the last quote's day.
+		{"Every 20s", 20 * time.Second, 3},
+		{"Non-positive interval", 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mockSimulation(t)
+			sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+
+			ticks := mockTicks("AAPL", "AAPL")
+			events := make(chan instrument.Event, len(ticks))
+			for i := range ticks {
+				ticks[i].Timestamp = late.Add(time.Duration(i) * 30 * time.Second)
+				events <- ticks[i]
+			}
+			close(events)
+
+			var fired []time.Time
+			sim.ScheduleEvery(late, tt.interval, func() {
+				fired = append(fired, sim.OMS().Now())
+			})
+			clock := NewSimClock()
+			sim.SetClock(clock)
+			if err := sim.replay(context.Background(), mockEventSource(events), clock); err != nil {
+				t.Fatalf("Simulation.replay() error = %v", err)
+			}
+
+			if len(fired) != tt.want {
+				t.Fatalf("Timers fired at %v, want %d", fired, tt.want)
+			}
+			for i := range fired {
+				if want := late.Add(time.Duration(i) * tt.interval); !fired[i].Equal(want) {
+					t.Errorf("Timer %d fired at %v, want %v", i, fired[i], want)
+				}
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
 	"os"
@@ -13,26 +14,21 @@ import (
 	"github.com/jakeschurch/porttools/utils"
 )
 
-func newAlgo() *algo {
-	return &algo{}
-}
-
 type algo struct{}
 
-func (algo algo) EntryCheck(q instrument.Quote) (*order.Order, error) {
-	var cash = porttools.Oms.Cash()
+func (algo algo) EntryCheck(ctx *porttools.StrategyContext, q instrument.Quote) (*order.Order, error) {
+	var cash = ctx.Cash()
+	var volume = utils.Amount(ctx.FloatParam("orderVolume", 50.00))
 
-	if cash-q.Ask*q.Volume(0) < 0 {
+	if cash-q.Ask*volume < 0 {
 		return nil, porttools.ErrOrderNotValid
 	}
 
-	newOrder := order.New(true, q)
-
-	newOrder.Volume(utils.Amount(50.00))
-	return newOrder, nil
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), volume)
+	return order.New(true, q), nil
 }
 
-func (algo algo) ExitCheck(openOrder order.Order, t instrument.Tick) (*order.Order, error) {
+func (algo algo) ExitCheck(ctx *porttools.StrategyContext, openOrder order.Order, t instrument.Tick) (*order.Order, error) {
 	if t.Ticker() == openOrder.Ticker() {
 		return order.New(false, *t.Quote), nil
 	}
@@ -59,9 +55,11 @@ func main() {
 	if simErr != nil {
 		log.Fatal("Error in Simulation: ", simErr)
 	}
-	porttools.NewStrategy(newAlgo())
+	sim.SetStrategy(porttools.NewStrategy(algo{}))
 	log.Println("running sim")
-	sim.Run()
+	if err := sim.Run(context.Background()); err != nil {
+		log.Fatal("Error in Simulation: ", err)
+	}
 
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
@@ -0,0 +1,917 @@
+package porttools
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+var (
+	// ErrInvalidFileGlob indiciates that no files could be found from given glob
+	ErrInvalidFileGlob = errors.New("No files could be found from file glob")
+
+	// ErrInvalidFileDelim is thrown when file delimiter is not able to be parsed
+	ErrInvalidFileDelim = errors.New("File delimiter could not be parsed")
+
+	// ErrNoFilesInRange indicates that none of the files found from the given glob
+	// fall between the simulation's start and end dates.
+	ErrNoFilesInRange = errors.New("No files found between simulation start and end dates")
+
+	// ErrNoStdinDate indicates that standard input is replayed without a simulation start date to date it at.
+	ErrNoStdinDate = errors.New("Simulation start date must be set to read from standard input")
+
+	// ErrInvalidFileKind indicates an unknown file kind.
+	ErrInvalidFileKind = errors.New("File kind must be one of quotes, bars or trades")
+
+	// errSourceClosed stops the workers of a FileSource that has been closed.
+	errSourceClosed = errors.New("File source closed")
+)
+
+// FileSource is a TickSource that parses ticks from the delimited data files
+// found from a config's file glob, replaying files in date order.
+// Data files of bars are replayed as an EventSource.
+type FileSource struct {
+	cfg       *config.Config
+	files     []dataFile
+	eventChan chan instrument.Event
+	rejects   *rejectLog
+	once      sync.Once
+	err       error
+
+	quit      chan struct{}
+	closeOnce sync.Once
+
+	// mu guards file, the file being read, which Close closes to unblock a read from a pipe.
+	mu   sync.Mutex
+	file io.Closer
+}
+
+// NewFileSource finds the data files specified by cfg,
+// and returns a FileSource that will replay them.
+func NewFileSource(cfg *config.Config) (*FileSource, error) {
+	switch cfg.File.Kind {
+	case "", config.KindQuotes, config.KindBars, config.KindTrades:
+	default:
+		return nil, ErrInvalidFileKind
+	}
+	files, err := dataFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	rejects, err := newRejectLog(cfg.File.OnBadRecord, rejectsFile(cfg.File, cfg.Simulation.OutputDir))
+	if err != nil {
+		return nil, err
+	}
+	src := &FileSource{
+		cfg:       cfg,
+		files:     files,
+		eventChan: make(chan instrument.Event),
+		rejects:   rejects,
+		quit:      make(chan struct{}),
+	}
+	return src, nil
+}
+
+// Next returns the next tick parsed from the source's data files.
+func (src *FileSource) Next() (*instrument.Tick, error) {
+	for {
+		event, err := src.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if tick, ok := event.(*instrument.Tick); ok {
+			return tick, nil
+		}
+	}
+}
+
+// NextEvent returns the next record parsed from the source's data files.
+func (src *FileSource) NextEvent() (instrument.Event, error) {
+	src.once.Do(func() {
+		go src.run()
+	})
+
+	event, ok := <-src.eventChan
+	if !ok {
+		if src.err != nil {
+			return nil, src.err
+		}
+		return nil, io.EOF
+	}
+	return event, nil
+}
+
+// Summary returns counts of the records read and rejected from the source's data files.
+func (src *FileSource) Summary() IngestSummary {
+	return src.rejects.Summary()
+}
+
+// Close stops the parsing of the source's data files, once they are no longer to be replayed.
+// Close returns once the file being parsed has been closed.
+// A source reading from standard input closes its own pipe of it, so that a read blocked on a pipe
+// returns while standard input is left open.
+func (src *FileSource) Close() error {
+	src.mu.Lock()
+	src.closeOnce.Do(func() {
+		close(src.quit)
+	})
+	if src.file != nil {
+		src.file.Close()
+	}
+	src.mu.Unlock()
+
+	started := true
+	src.once.Do(func() {
+		started = false
+		close(src.eventChan)
+	})
+	if !started {
+		return src.rejects.Close()
+	}
+	for range src.eventChan { // wait out events sent before the workers stopped.
+	}
+	return nil
+}
+
+func (src *FileSource) run() {
+	for i := range src.files {
+		select {
+		case <-src.quit:
+		default:
+			src.err = src.load(src.files[i])
+		}
+		// errors reading a file closed under the workers are not reported.
+		if src.err != nil && src.closed() {
+			src.err = nil
+		}
+		if src.err != nil {
+			break
+		}
+	}
+	if err := src.rejects.Close(); err != nil && src.err == nil {
+		src.err = err
+	}
+	close(src.eventChan)
+}
+
+// closed reports whether the source has been closed.
+func (src *FileSource) closed() bool {
+	select {
+	case <-src.quit:
+		return true
+	default:
+		return false
+	}
+}
+
+// reading sets the file being read, returning errSourceClosed if the source has already been closed.
+func (src *FileSource) reading(file io.Closer) error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	if file != nil && src.closed() {
+		return errSourceClosed
+	}
+	src.file = file
+	return nil
+}
+
+// load replays a single data file through the source's event channel.
+func (src *FileSource) load(f dataFile) error {
+	log.Println("loading", f.name)
+
+	delim, err := parseDelim(src.cfg.File.Delim)
+	if err != nil {
+		return err
+	}
+	loc, err := src.cfg.Location()
+	if err != nil {
+		return err
+	}
+	parseTime, err := newTimestampParser(src.cfg.File.TimestampFormat, src.cfg.File.TimestampUnit, f.date, loc)
+	if err != nil {
+		return err
+	}
+
+	// DO NOT REVIEW
+	colConfig := colConfig{tick: src.cfg.File.Columns.Ticker,
+		tStamp:    src.cfg.File.Columns.Timestamp,
+		bid:       src.cfg.File.Columns.Bid,
+		bidSz:     src.cfg.File.Columns.BidSize,
+		ask:       src.cfg.File.Columns.Ask,
+		askSz:     src.cfg.File.Columns.AskSize,
+		open:      src.cfg.File.Columns.Open,
+		high:      src.cfg.File.Columns.High,
+		low:       src.cfg.File.Columns.Low,
+		close:     src.cfg.File.Columns.Close,
+		volume:    src.cfg.File.Columns.Volume,
+		price:     src.cfg.File.Columns.Price,
+		size:      src.cfg.File.Columns.Size,
+		condition: src.cfg.File.Columns.Conditions,
+		typ:       src.cfg.File.Columns.Type,
+		venue:     src.cfg.File.Columns.Venue,
+		tradeType: src.cfg.File.TradeType,
+		kind:      src.cfg.File.Kind,
+		barLength: time.Duration(src.cfg.Simulation.BarRate),
+		parseTime: parseTime,
+		parsers:   src.cfg.File.Parsers,
+		delim:     delim,
+		headers:   src.cfg.File.Headers,
+	}
+
+	var file io.ReadCloser
+	if f.name == stdinGlob {
+		file = newStdinPipe()
+	} else if file, err = os.Open(f.name); err != nil {
+		return err
+	}
+	defer file.Close()
+	if err = src.reading(file); err != nil {
+		return err
+	}
+	defer src.reading(nil)
+
+	r, err := decompress(f.name, file)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	worker := newWorker(colConfig, src.rejects)
+	worker.file = f.name
+	worker.stop = src.quit
+	return worker.run(src.eventChan, r)
+}
+
+// dataFile is a file found from the config's file glob,
+// along with the date parsed from its filename.
+type dataFile struct {
+	name string
+	date time.Time
+}
+
+// stdinGlob is the file glob used to read tick data from standard input.
+const stdinGlob = "-"
+
+// newStdinPipe returns a reader of standard input owned by the caller,
+// so that closing it stops a replay without closing standard input for the rest of the process.
+// Standard input is copied into the pipe on another goroutine, which stops once the pipe is closed
+// and its read of standard input returns; the data of that last read is dropped.
+func newStdinPipe() io.ReadCloser {
+	stdin := os.Stdin
+	r, w := io.Pipe()
+	go func() {
+		_, err := io.Copy(w, stdin)
+		w.CloseWithError(err)
+	}()
+	return r
+}
+
+// dataFiles returns every file found from the config's file glob that falls
+// between the simulation's start and end dates, sorted by date.
+// A glob of "-" reads from standard input, dated at the simulation's start date,
+// which must then be set.
+func dataFiles(cfg *config.Config) ([]dataFile, error) {
+	var startDate, endDate time.Time
+
+	loc, err := cfg.Location()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Simulation.StartDate != "" {
+		if startDate, err = time.ParseInLocation(cfg.File.ExampleDate, cfg.Simulation.StartDate, loc); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.File.Glob == stdinGlob {
+		if startDate.IsZero() {
+			return nil, ErrNoStdinDate
+		}
+		return []dataFile{{name: stdinGlob, date: startDate}}, nil
+	}
+
+	fileGlob, err := filepath.Glob(cfg.File.Glob)
+	if err != nil || len(fileGlob) == 0 {
+		return nil, ErrInvalidFileGlob
+	}
+
+	if cfg.Simulation.EndDate != "" {
+		if endDate, err = time.ParseInLocation(cfg.File.ExampleDate, cfg.Simulation.EndDate, loc); err != nil {
+			return nil, err
+		}
+	}
+
+	exchange, _, err := cfg.Exchange()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]dataFile, 0, len(fileGlob))
+	for _, name := range fileGlob {
+		fileDate, dateErr := parseFileDate(cfg, name, loc)
+		if dateErr != nil {
+			return nil, dateErr
+		}
+		if !startDate.IsZero() && fileDate.Before(startDate) {
+			continue
+		}
+		if !endDate.IsZero() && fileDate.After(endDate) {
+			continue
+		}
+		// files dated on weekends and holidays are not replayed.
+		if exchange != nil && !exchange.IsTradingDay(fileDate) {
+			continue
+		}
+		files = append(files, dataFile{name: name, date: fileDate})
+	}
+	if len(files) == 0 {
+		return nil, ErrNoFilesInRange
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].date.Before(files[j].date)
+	})
+	return files, nil
+}
+
+// parseFileDate parses the date suffix of a file name, e.g. `mock_20170814`,
+// using the config's example date as the layout, at midnight in loc.
+// Any extensions following the date are ignored.
+func parseFileDate(cfg *config.Config, name string, loc *time.Location) (time.Time, error) {
+	base := filepath.Base(name)
+	suffix := base[strings.LastIndex(base, "_")+1:]
+	if ext := strings.Index(suffix, "."); ext != -1 {
+		suffix = suffix[:ext]
+	}
+	return time.ParseInLocation(cfg.File.ExampleDate, suffix, loc)
+}
+
+// parseDelim returns the rune used to separate fields in a data file.
+// Fields are separated by commas if no delimiter is given.
+func parseDelim(delim string) (rune, error) {
+	if delim == "" {
+		return ',', nil
+	}
+	r, size := utf8.DecodeRuneInString(delim)
+	if size != len(delim) || r == utf8.RuneError {
+		return 0, ErrInvalidFileDelim
+	}
+	switch r {
+	case '"', '\r', '\n':
+		return 0, ErrInvalidFileDelim
+	}
+	return r, nil
+}
+
+type colConfig struct {
+	tick, bid, bidSz, ask, askSz, tStamp config.Column
+	open, high, low, close, volume       config.Column
+	price, size                          config.Column
+	condition, typ, venue                *config.Column
+	tradeType                            string
+	kind                                 string
+	barLength                            time.Duration
+	parseTime                            timestampParser
+	parsers                              int
+	delim                                rune
+	headers                              bool
+}
+
+// colIndex holds the record positions of a colConfig's columns,
+// once they have been resolved against a file's headers.
+type colIndex struct {
+	tick, bid, bidSz, ask, askSz, tStamp int
+	open, high, low, close, volume       int
+	price, size, condition, typ, venue   int
+	max                                  int
+}
+
+const (
+	// chunkSize is the number of records a worker hands to a parser at a time.
+	chunkSize = 512
+
+	// workerBufferSize is the number of chunks per parser that a worker will read ahead
+	// of the chunks that have been sent through the pipeline.
+	workerBufferSize = 4
+)
+
+// A worker reads a data file in chunks of whole records, spreading the parsing
+// of chunks across goroutines before putting parsed records back in file order.
+type worker struct {
+	chunkChan  chan *chunk
+	parsedChan chan *chunk
+	tokens     chan struct{}
+	colCfg     colConfig
+	cols       colIndex
+	file       string
+	rejects    *rejectLog
+	quit       chan struct{}
+	failOnce   sync.Once
+	// stop, if not nil, stops the worker once closed.
+	stop <-chan struct{}
+
+	// mu guards err, which may be set by the stop goroutine after run has finished sending.
+	mu  sync.Mutex
+	err error
+}
+
+// chunk is a run of records of a data file, starting on line.
+// seq is the position of the chunk in the file.
+type chunk struct {
+	seq     int
+	line    int
+	data    []byte
+	records []record
+}
+
+// record is a line of a data file, split into fields and parsed into an event.
+// err is set to the reason a record could not be loaded.
+type record struct {
+	line   int
+	fields []string
+	event  instrument.Event
+	err    error
+
+	// raw is the text of a record that could not be split into fields.
+	raw string
+}
+
+func newWorker(cols colConfig, rejects *rejectLog) *worker {
+	if cols.parsers <= 0 {
+		cols.parsers = runtime.GOMAXPROCS(0)
+	}
+	worker := &worker{
+		colCfg:  cols,
+		rejects: rejects,
+		quit:    make(chan struct{}),
+	}
+	return worker
+}
+
+// run streams records from r through the worker in a single pass,
+// sending parsed records to outChan in the order they were read.
+func (worker *worker) run(outChan chan<- instrument.Event, r io.Reader) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	line := 1
+
+	var headers []string
+	if worker.colCfg.headers {
+		data, n, err := readRecords(reader, 1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(data) == 0 {
+			return nil
+		}
+		if headers, err = worker.csvReader(data).Read(); err != nil {
+			return err
+		}
+		line += n
+	}
+	if err := worker.resolve(headers); err != nil {
+		return err
+	}
+
+	parsers := worker.colCfg.parsers
+	worker.chunkChan = make(chan *chunk, parsers)
+	worker.parsedChan = make(chan *chunk, parsers)
+	worker.tokens = make(chan struct{}, parsers*workerBufferSize)
+
+	var wg sync.WaitGroup
+	wg.Add(parsers)
+	for i := 0; i < parsers; i++ {
+		go func() {
+			worker.parse()
+			wg.Done()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(worker.parsedChan)
+	}()
+
+	done := make(chan struct{})
+	go worker.send(outChan, done)
+	if worker.stop != nil {
+		go func() {
+			select {
+			case <-worker.stop:
+				worker.fail(errSourceClosed)
+			case <-done:
+			}
+		}()
+	}
+	worker.produce(reader, line)
+
+	<-done
+	worker.mu.Lock()
+	defer worker.mu.Unlock()
+	return worker.err
+}
+
+// fail stops the worker, with err returned from run.
+func (worker *worker) fail(err error) {
+	worker.failOnce.Do(func() {
+		worker.mu.Lock()
+		worker.err = err
+		worker.mu.Unlock()
+		close(worker.quit)
+	})
+}
+
+// reject hands a bad record to the worker's bad record policy,
+// stopping the worker if the record cannot be skipped.
+func (worker *worker) reject(data *record) {
+	raw := data.raw
+	if data.fields != nil {
+		raw = strings.Join(data.fields, string(worker.colCfg.delim))
+	}
+	if err := worker.rejects.reject(worker.file, data.line, data.err, raw); err != nil {
+		worker.fail(err)
+	}
+}
+
+// send puts parsed chunks back in file order, sending their events to outChan
+// and handing their bad records to the worker's bad record policy.
+func (worker *worker) send(outChan chan<- instrument.Event, done chan struct{}) {
+	var records, ticks int
+	var next int
+	pending := make(map[int]*chunk)
+
+	for c := range worker.parsedChan {
+		pending[c.seq] = c
+
+		for c, ok := pending[next]; ok; c, ok = pending[next] {
+			delete(pending, next)
+			next++
+
+			records += len(c.records)
+			ticks += worker.sendChunk(outChan, c)
+			<-worker.tokens
+		}
+	}
+	worker.rejects.read(records, ticks)
+	close(done)
+}
+
+// sendChunk sends the events of a chunk to outChan, returning how many were sent.
+func (worker *worker) sendChunk(outChan chan<- instrument.Event, c *chunk) (sent int) {
+	for i := range c.records {
+		select {
+		case <-worker.quit: // drop remaining records once stopped.
+			return sent
+		default:
+		}
+		data := &c.records[i]
+		if data.err != nil {
+			worker.reject(data)
+			continue
+		}
+		select {
+		case outChan <- data.event:
+			sent++
+		case <-worker.quit:
+			return sent
+		}
+	}
+	return sent
+}
+
+// resolve looks up the record position of each configured column.
+// Columns given by name are found in headers.
+func (worker *worker) resolve(headers []string) (err error) {
+	type column struct {
+		col config.Column
+		idx *int
+	}
+	cols := []column{
+		{worker.colCfg.tick, &worker.cols.tick},
+		{worker.colCfg.tStamp, &worker.cols.tStamp},
+	}
+	tradeCols := []column{
+		{worker.colCfg.price, &worker.cols.price},
+		{worker.colCfg.size, &worker.cols.size},
+	}
+	worker.cols.condition, worker.cols.typ, worker.cols.venue = -1, -1, -1
+
+	switch worker.colCfg.kind {
+	case config.KindBars:
+		cols = append(cols,
+			column{worker.colCfg.open, &worker.cols.open},
+			column{worker.colCfg.high, &worker.cols.high},
+			column{worker.colCfg.low, &worker.cols.low},
+			column{worker.colCfg.close, &worker.cols.close},
+			column{worker.colCfg.volume, &worker.cols.volume},
+		)
+	case config.KindTrades:
+		cols = append(cols, tradeCols...)
+	default:
+		cols = append(cols,
+			column{worker.colCfg.bid, &worker.cols.bid},
+			column{worker.colCfg.bidSz, &worker.cols.bidSz},
+			column{worker.colCfg.ask, &worker.cols.ask},
+			column{worker.colCfg.askSz, &worker.cols.askSz},
+		)
+		if worker.colCfg.venue != nil {
+			cols = append(cols, column{*worker.colCfg.venue, &worker.cols.venue})
+		}
+		// files of quotes may also hold trades, told apart by their type.
+		if worker.colCfg.typ != nil && worker.colCfg.tradeType != "" {
+			cols = append(cols, column{*worker.colCfg.typ, &worker.cols.typ})
+			cols = append(cols, tradeCols...)
+		}
+	}
+	hasTrades := worker.colCfg.kind == config.KindTrades || worker.colCfg.typ != nil && worker.colCfg.tradeType != ""
+	if hasTrades && worker.colCfg.condition != nil {
+		cols = append(cols, column{*worker.colCfg.condition, &worker.cols.condition})
+	}
+	worker.cols.max = 0
+
+	for i := range cols {
+		if *cols[i].idx, err = cols[i].col.Resolve(headers); err != nil {
+			return err
+		}
+		if *cols[i].idx > worker.cols.max {
+			worker.cols.max = *cols[i].idx
+		}
+	}
+	return nil
+}
+
+// produce splits the records read from reader into chunks for the worker's parsers.
+// At most workerBufferSize chunks per parser are read ahead of those that have been sent.
+func (worker *worker) produce(reader *bufio.Reader, line int) {
+	defer close(worker.chunkChan)
+
+	for seq := 0; ; seq++ {
+		select {
+		case worker.tokens <- struct{}{}:
+		case <-worker.quit:
+			return
+		}
+
+		data, n, err := readRecords(reader, chunkSize)
+		if len(data) > 0 {
+			worker.chunkChan <- &chunk{seq: seq, line: line, data: data}
+			line += n
+		}
+		if err != nil {
+			if err != io.EOF {
+				worker.fail(err)
+			}
+			return
+		}
+	}
+}
+
+// parse parses the chunks read by the worker, passing them on to be sent in order.
+func (worker *worker) parse() {
+	for c := range worker.chunkChan {
+		select {
+		case <-worker.quit: // drain remaining chunks once stopped.
+			continue
+		default:
+		}
+		worker.parseChunk(c)
+		worker.parsedChan <- c
+	}
+}
+
+// parseChunk splits the lines of a chunk into records, and parses each record into an event.
+func (worker *worker) parseChunk(c *chunk) {
+	reader := worker.csvReader(c.data)
+	c.records = make([]record, 0, chunkSize)
+
+	for {
+		var data record
+
+		fields, err := reader.Read()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			parseErr, ok := err.(*csv.ParseError)
+			if !ok {
+				worker.fail(err)
+				break
+			}
+			data = record{
+				line: c.line + parseErr.StartLine - 1,
+				err:  ErrMalformedRecord,
+				raw:  recordLines(c.data, parseErr.StartLine, parseErr.Line),
+			}
+		} else {
+			line, _ := reader.FieldPos(0)
+			data = record{line: c.line + line - 1, fields: fields}
+			if len(fields) <= worker.cols.max {
+				data.err = ErrMissingFields
+			} else {
+				data.event, data.err = worker.consume(fields)
+			}
+		}
+		c.records = append(c.records, data)
+	}
+	c.data = nil
+}
+
+func (worker *worker) csvReader(data []byte) *csv.Reader {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = worker.colCfg.delim
+	reader.FieldsPerRecord = -1
+	return reader
+}
+
+// recordLines returns lines start to end of data, counted from 1, without their line breaks.
+func recordLines(data []byte, start, end int) string {
+	lines := bytes.SplitAfter(data, []byte{'\n'})
+	if start < 1 || start > len(lines) {
+		return ""
+	}
+	if end < start {
+		end = start
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	raw := bytes.Join(lines[start-1:end], nil)
+	return strings.TrimRight(string(raw), "\r\n")
+}
+
+// readRecords reads the lines of up to n records from r, along with the number of lines read.
+// A record is not split between reads, even if a quoted field holds a line break.
+func readRecords(r *bufio.Reader, n int) (data []byte, lines int, err error) {
+	var quoted bool
+
+	for records := 0; records < n; {
+		var frag []byte
+
+		frag, err = r.ReadSlice('\n')
+		data = append(data, frag...)
+		if bytes.Count(frag, []byte{'"'})%2 == 1 {
+			quoted = !quoted
+		}
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return data, lines, err
+		}
+		lines++
+		if !quoted {
+			records++
+		}
+	}
+	return data, lines, nil
+}
+
+// consume parses a record into a tick, bar or trade, depending on the kind of file being read.
+// Returns the reason a bad record could not be loaded.
+func (worker *worker) consume(fields []string) (instrument.Event, error) {
+	switch {
+	case worker.colCfg.kind == config.KindBars:
+		bar, err := worker.consumeBar(fields)
+		if err != nil {
+			return nil, err
+		}
+		return bar, nil
+
+	case worker.colCfg.kind == config.KindTrades,
+		worker.cols.typ != -1 && fields[worker.cols.typ] == worker.colCfg.tradeType:
+		trade, err := worker.consumeTrade(fields)
+		if err != nil {
+			return nil, err
+		}
+		return trade, nil
+	}
+	tick, err := worker.consumeQuote(fields)
+	if err != nil {
+		return nil, err
+	}
+	return tick, nil
+}
+
+func (worker *worker) consumeQuote(fields []string) (*instrument.Tick, error) {
+	var tick *instrument.Tick
+	var err error
+
+	tick = instrument.NewTick(0, 0, new(instrument.Quote))
+	tick.SetTicker(fields[worker.cols.tick])
+	if worker.cols.venue != -1 {
+		tick.Venue = fields[worker.cols.venue]
+	}
+
+	bid, err := strconv.ParseFloat(fields[worker.cols.bid], 64)
+	if err != nil {
+		return nil, ErrBidPrice
+	}
+	if bid == 0 {
+		return nil, ErrZeroBid
+	}
+	tick.Bid = utils.FloatAmount(bid)
+
+	bidSz, err := strconv.ParseFloat(fields[worker.cols.bidSz], 64)
+	if err != nil {
+		return nil, ErrBidSize
+	}
+	tick.BidSize = utils.Amount(bidSz)
+
+	ask, err := strconv.ParseFloat(fields[worker.cols.ask], 64)
+	if err != nil {
+		return nil, ErrAskPrice
+	}
+	if ask == 0 {
+		return nil, ErrZeroAsk
+	}
+	tick.Ask = utils.FloatAmount(ask)
+
+	askSz, err := strconv.ParseFloat(fields[worker.cols.askSz], 64)
+	if err != nil {
+		return nil, ErrAskSize
+	}
+	tick.AskSize = utils.Amount(askSz)
+
+	if tick.Timestamp, err = worker.colCfg.parseTime(fields[worker.cols.tStamp]); err != nil {
+		return nil, ErrTimestamp
+	}
+	return tick, nil
+}
+
+func (worker *worker) consumeBar(fields []string) (*instrument.Bar, error) {
+	var price instrument.OHLC
+
+	prices := []struct {
+		idx    int
+		amount *utils.Amount
+	}{
+		{worker.cols.open, &price.Open},
+		{worker.cols.high, &price.High},
+		{worker.cols.low, &price.Low},
+		{worker.cols.close, &price.Close},
+	}
+	for i := range prices {
+		p, err := strconv.ParseFloat(fields[prices[i].idx], 64)
+		if err != nil {
+			return nil, ErrBarPrice
+		}
+		if p == 0 {
+			return nil, ErrZeroBarPrice
+		}
+		*prices[i].amount = utils.FloatAmount(p)
+	}
+
+	volume, err := strconv.ParseFloat(fields[worker.cols.volume], 64)
+	if err != nil {
+		return nil, ErrVolume
+	}
+
+	start, err := worker.colCfg.parseTime(fields[worker.cols.tStamp])
+	if err != nil {
+		return nil, ErrTimestamp
+	}
+	end := start.Add(worker.colCfg.barLength)
+
+	return instrument.NewOHLCVBar(fields[worker.cols.tick], start, end, price, utils.Amount(volume)), nil
+}
+
+func (worker *worker) consumeTrade(fields []string) (*instrument.Trade, error) {
+	var conditions string
+
+	price, err := strconv.ParseFloat(fields[worker.cols.price], 64)
+	if err != nil {
+		return nil, ErrTradePrice
+	}
+	if price == 0 {
+		return nil, ErrZeroTradePrice
+	}
+
+	size, err := strconv.ParseFloat(fields[worker.cols.size], 64)
+	if err != nil {
+		return nil, ErrTradeSize
+	}
+
+	if worker.cols.condition != -1 {
+		conditions = fields[worker.cols.condition]
+	}
+
+	timestamp, err := worker.colCfg.parseTime(fields[worker.cols.tStamp])
+	if err != nil {
+		return nil, ErrTimestamp
+	}
+
+	return instrument.NewTrade(fields[worker.cols.tick], utils.FloatAmount(price), utils.Amount(size), conditions, timestamp), nil
+}
@@ -0,0 +1,552 @@
+package porttools
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func mockParseTime(field string) (time.Time, error) {
+	d, err := time.ParseDuration(field + "ns")
+	return time.Time{}.Add(d), err
+}
+
+func mockDataFiles(t testing.TB, names ...string) string {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func Test_dataFiles(t *testing.T) {
+	dir := mockDataFiles(t, "mock_20170816", "mock_20170814", "mock_20170815", "mock_20170901")
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name      string
+		startDate string
+		endDate   string
+		calendar  bool
+		stdin     bool
+		want      []string
+		wantErr   error
+	}{
+		{"No date range", "", "", false, false, []string{"mock_20170814", "mock_20170815", "mock_20170816", "mock_20170901"}, nil},
+		{"Inclusive date range", "20170815", "20170816", false, false, []string{"mock_20170815", "mock_20170816"}, nil},
+		{"Open-ended start date", "20170816", "", false, false, []string{"mock_20170816", "mock_20170901"}, nil},
+		{"No files in range", "20171001", "20171031", false, false, nil, ErrNoFilesInRange},
+		{"Standard input", "20170814", "", false, true, []string{"-"}, nil},
+		{"Standard input without a start date", "", "", false, true, nil, ErrNoStdinDate},
+		{"Trading days", "20170814", "20170816", true, false, []string{"mock_20170814", "mock_20170816"}, nil},
+	}
+	calendarFile := filepath.Join(dir, "calendar.json")
+	if err := ioutil.WriteFile(calendarFile, []byte(`{"XNYS": {"timeZone": "UTC", "sessions": {"regular": {"open": "09:30", "close": "16:00"}}, "holidays": ["2017-08-15"]}}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(config.Config)
+			cfg.File.Glob = filepath.Join(dir, "mock_*")
+			cfg.File.ExampleDate = "20060102"
+			cfg.Simulation.StartDate = tt.startDate
+			cfg.Simulation.EndDate = tt.endDate
+			if tt.stdin {
+				cfg.File.Glob = stdinGlob
+			}
+			if tt.calendar {
+				cfg.Calendar.File, cfg.Calendar.Exchange = calendarFile, "XNYS"
+			}
+
+			got, err := dataFiles(cfg)
+			if err != tt.wantErr {
+				t.Fatalf("dataFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("dataFiles() returned %d files, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if filepath.Base(got[i].name) != tt.want[i] {
+					t.Errorf("dataFiles()[%d] = %s, want %s", i, filepath.Base(got[i].name), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func Test_parseDelim(t *testing.T) {
+	tests := []struct {
+		name    string
+		delim   string
+		want    rune
+		wantErr error
+	}{
+		{"Default delimiter", "", ',', nil},
+		{"Pipe delimiter", "|", '|', nil},
+		{"Tab delimiter", "\t", '\t', nil},
+		{"Multi-character delimiter", "||", 0, ErrInvalidFileDelim},
+		{"Quote delimiter", "\"", 0, ErrInvalidFileDelim},
+		{"Newline delimiter", "\n", 0, ErrInvalidFileDelim},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDelim(tt.delim)
+			if err != tt.wantErr {
+				t.Fatalf("parseDelim() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDelim() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_worker_run(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime,
+	}
+
+	tests := []struct {
+		name    string
+		delim   rune
+		headers bool
+		data    string
+		want    []string
+	}{
+		{"Comma with headers", ',', true,
+			"time,ticker,bid,bidSz,ask,askSz\n1,AAPL,50.00,10,50.10,10\n2,GOOGL,10.00,5,10.10,5\n",
+			[]string{"AAPL", "GOOGL"}},
+		{"Pipe without headers", '|', false,
+			"1|AAPL|50.00|10|50.10|10\n2|GOOGL|10.00|5|10.10|5\n",
+			[]string{"AAPL", "GOOGL"}},
+		{"Tab with quoted fields", '\t', false,
+			"1\t\"BRK\tB\"\t50.00\t10\t50.10\t10\n",
+			[]string{"BRK\tB"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := cols
+			cfg.delim, cfg.headers = tt.delim, tt.headers
+			outChan := make(chan instrument.Event, len(tt.want))
+
+			if err := newWorker(cfg, mockRejectLog(t, config.OnBadRecordFail)).run(outChan, strings.NewReader(tt.data)); err != nil {
+				t.Fatalf("worker.run() error = %v", err)
+			}
+			close(outChan)
+
+			var got []string
+			for event := range outChan {
+				got = append(got, event.Ticker())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("worker.run() tickers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_worker_resolve(t *testing.T) {
+	headers := []string{"\uFEFFTIME", "SYM", "BID_PRICE", "BID_SIZE", "ASK_PRICE", "ASK_SIZE"}
+
+	tests := []struct {
+		name    string
+		bid     config.Column
+		want    int
+		wantErr bool
+	}{
+		{"Index column", config.Column{Index: 2}, 2, false},
+		{"Named column", config.Column{Name: "BID_PRICE"}, 2, false},
+		{"Missing named column", config.Column{Name: "BID"}, -1, true},
+		{"Negative index column", config.Column{Index: -1}, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			worker := newWorker(colConfig{
+				tStamp: config.Column{Name: "TIME"}, tick: config.Column{Name: "SYM"},
+				bid: tt.bid, bidSz: config.Column{Index: 3},
+				ask: config.Column{Name: "ASK_PRICE"}, askSz: config.Column{Name: "ASK_SIZE"},
+			}, nil)
+			err := worker.resolve(headers)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("worker.resolve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && worker.cols.bid != tt.want {
+				t.Errorf("worker.resolve() bid = %d, want %d", worker.cols.bid, tt.want)
+			}
+		})
+	}
+}
+
+func Test_worker_run_stream(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime, delim: ',',
+	}
+	nTicks := chunkSize * workerBufferSize * 4
+
+	// An io.Pipe cannot be rewound, and blocks its writer until the worker reads,
+	// so ticks must be streamed in a single pass.
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < nTicks; i++ {
+			fmt.Fprintf(pw, "%d,AAPL,50.00,10,50.10,10\n", i)
+		}
+		pw.Close()
+	}()
+
+	outChan := make(chan instrument.Event)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- newWorker(cols, mockRejectLog(t, config.OnBadRecordFail)).run(outChan, pr)
+		close(outChan)
+	}()
+
+	var got int
+	for range outChan {
+		got++
+	}
+	if err := <-errChan; err != nil {
+		t.Fatalf("worker.run() error = %v", err)
+	}
+	if got != nTicks {
+		t.Errorf("worker.run() sent %d ticks, want %d", got, nTicks)
+	}
+}
+
+func TestFileSource_Next(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"mock_20170815": "2,GOOGL,10.00,5,10.10,5\n",
+		"mock_20170814": "1,AAPL,50.00,10,50.10,10\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := new(config.Config)
+	cfg.File.Glob = filepath.Join(dir, "mock_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+	src, err := NewFileSource(cfg)
+	if err != nil {
+		t.Fatalf("NewFileSource() error = %v", err)
+	}
+
+	var got []string
+	for {
+		tick, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("FileSource.Next() error = %v", err)
+		}
+		got = append(got, tick.Ticker()+"@"+tick.Timestamp.Format("20060102"))
+	}
+	if want := "AAPL@20170814,GOOGL@20170815"; strings.Join(got, ",") != want {
+		t.Errorf("FileSource.Next() ticks = %v, want %v", got, want)
+	}
+}
+
+func TestFileSource_Close(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+
+	var data bytes.Buffer
+	for i := 0; i < 50*chunkSize; i++ {
+		fmt.Fprintf(&data, "%d,AAPL,50.00,10,50.10,10\n", i)
+	}
+	for _, name := range []string{"mock_20170814", "mock_20170815"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := new(config.Config)
+	cfg.File.Glob = filepath.Join(dir, "mock_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+	tests := []struct {
+		name string
+		read int
+	}{
+		{"Before reading", 0},
+		{"While reading", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, err := NewFileSource(cfg)
+			if err != nil {
+				t.Fatalf("NewFileSource() error = %v", err)
+			}
+			for i := 0; i < tt.read; i++ {
+				if _, err = src.Next(); err != nil {
+					t.Fatalf("FileSource.Next() error = %v", err)
+				}
+			}
+
+			if err = src.Close(); err != nil {
+				t.Errorf("FileSource.Close() error = %v", err)
+			}
+			if _, err = src.Next(); err != io.EOF {
+				t.Errorf("FileSource.Next() after Close() error = %v, want %v", err, io.EOF)
+			}
+			if read := src.Summary().Records; read >= 2*50*chunkSize {
+				t.Errorf("FileSource read %d records after Close(), want fewer than %d", read, 2*50*chunkSize)
+			}
+		})
+	}
+}
+
+func Test_worker_run_bars(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Name: "date"}, tick: config.Column{Name: "symbol"},
+		open: config.Column{Name: "open"}, high: config.Column{Name: "high"},
+		low: config.Column{Name: "low"}, close: config.Column{Name: "close"},
+		volume:    config.Column{Name: "volume"},
+		kind:      config.KindBars,
+		barLength: 24 * time.Hour,
+		delim:     ',', headers: true,
+	}
+	cols.parseTime, _ = newTimestampParser("2006-01-02", "", time.Time{}, time.UTC)
+
+	data := "date,symbol,open,high,low,close,volume\n" +
+		"2017-08-14,AAPL,159.32,160.21,158.75,159.85,21754810\n" +
+		"2017-08-14,GOOGL,0,0,0,0,0\n"
+
+	rejects := mockRejectLog(t, config.OnBadRecordSkip)
+	outChan := make(chan instrument.Event, 2)
+	if err := newWorker(cols, rejects).run(outChan, strings.NewReader(data)); err != nil {
+		t.Fatalf("worker.run() error = %v", err)
+	}
+	close(outChan)
+
+	var bars []*instrument.Bar
+	for event := range outChan {
+		bars = append(bars, event.(*instrument.Bar))
+	}
+	if len(bars) != 1 {
+		t.Fatalf("worker.run() sent %d bars, want 1", len(bars))
+	}
+
+	want := instrument.OHLC{
+		Open: utils.FloatAmount(159.32), High: utils.FloatAmount(160.21),
+		Low: utils.FloatAmount(158.75), Close: utils.FloatAmount(159.85),
+	}
+	start := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+	if bar := bars[0]; bar.Ticker() != "AAPL" || bar.Price != want || bar.Volume != 21754810 ||
+		!bar.Start.Equal(start) || !bar.Time().Equal(start.Add(24*time.Hour)) {
+		t.Errorf("worker.run() bar = %+v, want AAPL %+v with volume 21754810", bar, want)
+	}
+	if n := rejects.Summary().Rejected[ErrZeroBarPrice.Error()]; n != 1 {
+		t.Errorf("Summary().Rejected[%q] = %d, want 1", ErrZeroBarPrice, n)
+	}
+}
+
+func Test_worker_run_trades(t *testing.T) {
+	conditions := config.Column{Name: "cond"}
+	cols := colConfig{
+		tStamp: config.Column{Name: "time"}, tick: config.Column{Name: "sym"},
+		bid: config.Column{Name: "bid"}, bidSz: config.Column{Name: "bidSz"},
+		ask: config.Column{Name: "ask"}, askSz: config.Column{Name: "askSz"},
+		price: config.Column{Name: "price"}, size: config.Column{Name: "size"},
+		typ: &config.Column{Name: "type"}, tradeType: "T", condition: &conditions,
+		parseTime: mockParseTime, delim: ',', headers: true,
+	}
+	data := "time,sym,type,bid,bidSz,ask,askSz,price,size,cond\n" +
+		"1,AAPL,Q,50.00,10,50.10,10,,,\n" +
+		"2,AAPL,T,,,,,50.05,300,@F\n"
+
+	outChan := make(chan instrument.Event, 2)
+	if err := newWorker(cols, mockRejectLog(t, config.OnBadRecordFail)).run(outChan, strings.NewReader(data)); err != nil {
+		t.Fatalf("worker.run() error = %v", err)
+	}
+	close(outChan)
+
+	if _, ok := (<-outChan).(*instrument.Tick); !ok {
+		t.Errorf("worker.run() first event is not a quote")
+	}
+	trade, ok := (<-outChan).(*instrument.Trade)
+	if !ok {
+		t.Fatalf("worker.run() second event is not a trade")
+	}
+	if trade.Ticker() != "AAPL" || trade.Price != utils.FloatAmount(50.05) || trade.Size != 300 || trade.Conditions != "@F" {
+		t.Errorf("worker.run() trade = %+v, want 300 AAPL @ 50.05 with conditions @F", trade)
+	}
+}
+
+func Test_worker_run_venues(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Name: "time"}, tick: config.Column{Name: "sym"},
+		bid: config.Column{Name: "bid"}, bidSz: config.Column{Name: "bidSz"},
+		ask: config.Column{Name: "ask"}, askSz: config.Column{Name: "askSz"},
+		venue:     &config.Column{Name: "ex"},
+		parseTime: mockParseTime, delim: ',', headers: true,
+	}
+	data := "time,sym,ex,bid,bidSz,ask,askSz\n" +
+		"1,AAPL,Q,50.00,10,50.10,10\n" +
+		"1,AAPL,N,50.01,5,50.12,10\n"
+
+	outChan := make(chan instrument.Event, 2)
+	if err := newWorker(cols, mockRejectLog(t, config.OnBadRecordFail)).run(outChan, strings.NewReader(data)); err != nil {
+		t.Fatalf("worker.run() error = %v", err)
+	}
+	close(outChan)
+
+	for _, want := range []string{"Q", "N"} {
+		if tick, ok := (<-outChan).(*instrument.Tick); !ok || tick.Venue != want {
+			t.Errorf("worker.run() tick = %+v, want a quote of venue %s", tick, want)
+		}
+	}
+}
+
+func Test_worker_run_order(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime, parsers: 4, delim: ',',
+	}
+	nTicks := chunkSize*8 + 3
+
+	// A quoted field holding a line break ends the first chunk,
+	// and a bad record ends the file.
+	var buf bytes.Buffer
+	for i := 0; i < nTicks; i++ {
+		ticker := "AAPL"
+		if i == chunkSize-1 {
+			ticker = "\"AA\nPL\""
+		}
+		fmt.Fprintf(&buf, "%d,%s,50.00,10,50.10,10\n", i, ticker)
+	}
+	buf.WriteString("x,AAPL,50.00,10,50.10,10\n")
+
+	outChan := make(chan instrument.Event)
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- newWorker(cols, mockRejectLog(t, config.OnBadRecordFail)).run(outChan, &buf)
+		close(outChan)
+	}()
+
+	var got int
+	for event := range outChan {
+		if want := (time.Time{}).Add(time.Duration(got)); !event.Time().Equal(want) {
+			t.Fatalf("worker.run() event %d at %v, want %v", got, event.Time(), want)
+		}
+		got++
+	}
+	if got != nTicks {
+		t.Errorf("worker.run() sent %d ticks, want %d", got, nTicks)
+	}
+	err := <-errChan
+	if recordErr, ok := err.(*RecordError); !ok || recordErr.Line != nTicks+2 {
+		t.Errorf("worker.run() error = %v, want *RecordError on line %d", err, nTicks+2)
+	}
+}
+
+func Benchmark_worker_run(b *testing.B) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime, delim: ',',
+	}
+	nTicks := 100000
+
+	var buf bytes.Buffer
+	for i := 0; i < nTicks; i++ {
+		fmt.Fprintf(&buf, "%d,AAPL,%.2f,%d,%.2f,%d\n", i, 50+float64(i%100)/100, 100+i%7, 50.01+float64(i%100)/100, 200+i%5)
+	}
+	data := buf.Bytes()
+
+	for _, parsers := range []int{1, 2, 4, 8} {
+		cols.parsers = parsers
+
+		b.Run(fmt.Sprintf("parsers=%d", parsers), func(b *testing.B) {
+			log.SetOutput(ioutil.Discard)
+			defer log.SetOutput(os.Stderr)
+
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+			start := time.Now()
+
+			for i := 0; i < b.N; i++ {
+				outChan := make(chan instrument.Event, workerBufferSize*chunkSize)
+				go func() {
+					for range outChan {
+					}
+				}()
+				if err := newWorker(cols, mockRejectLog(b, config.OnBadRecordFail)).run(outChan, bytes.NewReader(data)); err != nil {
+					b.Fatal(err)
+				}
+				close(outChan)
+			}
+			b.ReportMetric(float64(nTicks*b.N)/time.Since(start).Seconds(), "ticks/s")
+		})
+	}
+}
+
+// Test_worker_run_stop stops workers as they finish sending, for the race detector
+// to check the reason they stopped is handed back safely.
+func Test_worker_run_stop(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime, delim: ',',
+	}
+	data := "1,AAPL,50.00,10,50.10,10\n"
+
+	for i := 0; i < 100; i++ {
+		stop := make(chan struct{})
+		worker := newWorker(cols, mockRejectLog(t, config.OnBadRecordFail))
+		worker.stop = stop
+
+		outChan := make(chan instrument.Event, 1)
+		go func() {
+			<-outChan
+			close(stop)
+		}()
+		if err := worker.run(outChan, strings.NewReader(data)); err != nil && err != errSourceClosed {
+			t.Fatalf("worker.run() error = %v", err)
+		}
+	}
+}
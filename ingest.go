@@ -0,0 +1,233 @@
+package porttools
+
+import (
+	"encoding/csv"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/jakeschurch/porttools/config"
+)
+
+var (
+	// ErrMalformedRecord indicates a record that could not be split into fields.
+	ErrMalformedRecord = errors.New("malformed record")
+
+	// ErrMissingFields indicates a record with fewer fields than the configured columns.
+	ErrMissingFields = errors.New("record is missing fields")
+
+	// ErrZeroBid indicates a record with a bid price of zero.
+	ErrZeroBid = errors.New("zero bid price")
+
+	// ErrZeroAsk indicates a record with an ask price of zero.
+	ErrZeroAsk = errors.New("zero ask price")
+
+	// ErrBidPrice indicates a bid price that could not be parsed.
+	ErrBidPrice = errors.New("bid price could not be parsed")
+
+	// ErrBidSize indicates a bid size that could not be parsed.
+	ErrBidSize = errors.New("bid size could not be parsed")
+
+	// ErrAskPrice indicates an ask price that could not be parsed.
+	ErrAskPrice = errors.New("ask price could not be parsed")
+
+	// ErrAskSize indicates an ask size that could not be parsed.
+	ErrAskSize = errors.New("ask size could not be parsed")
+
+	// ErrBarPrice indicates an open, high, low or close price that could not be parsed.
+	ErrBarPrice = errors.New("bar price could not be parsed")
+
+	// ErrZeroBarPrice indicates an open, high, low or close price of zero.
+	ErrZeroBarPrice = errors.New("zero bar price")
+
+	// ErrVolume indicates a volume that could not be parsed.
+	ErrVolume = errors.New("volume could not be parsed")
+
+	// ErrTradePrice indicates a trade price that could not be parsed.
+	ErrTradePrice = errors.New("trade price could not be parsed")
+
+	// ErrZeroTradePrice indicates a trade price of zero.
+	ErrZeroTradePrice = errors.New("zero trade price")
+
+	// ErrTradeSize indicates a trade size that could not be parsed.
+	ErrTradeSize = errors.New("trade size could not be parsed")
+
+	// ErrTimestamp indicates a timestamp that could not be parsed.
+	ErrTimestamp = errors.New("timestamp could not be parsed")
+
+	// ErrInvalidBadRecordPolicy indicates an unknown onBadRecord policy.
+	ErrInvalidBadRecordPolicy = errors.New("onBadRecord must be one of skip, fail or quarantine")
+
+	// ErrSharedRejectsFile indicates that quote and trade records would be quarantined to the same file,
+	// which each would truncate.
+	ErrSharedRejectsFile = errors.New("Quote and trade files must be quarantined to different rejects files")
+
+	// ErrNoRejectsFile indicates that bad records are to be quarantined,
+	// but neither a rejects file nor an output directory to write them to is configured.
+	ErrNoRejectsFile = errors.New("Quarantined records need a rejects file or output directory to be written to")
+)
+
+// RecordError is returned when a record of a data file could not be loaded.
+type RecordError struct {
+	File   string
+	Line   int
+	Reason error
+}
+
+func (e *RecordError) Error() string {
+	return e.File + ":" + strconv.Itoa(e.Line) + ": " + e.Reason.Error()
+}
+
+// IngestSummary reports the records read from a simulation's data files.
+type IngestSummary struct {
+	Records  int
+	Ticks    int
+	Rejected map[string]int
+}
+
+// Add returns the sum of two summaries.
+func (s IngestSummary) Add(other IngestSummary) IngestSummary {
+	sum := IngestSummary{
+		Records:  s.Records + other.Records,
+		Ticks:    s.Ticks + other.Ticks,
+		Rejected: make(map[string]int, len(s.Rejected)+len(other.Rejected)),
+	}
+	for reason, n := range s.Rejected {
+		sum.Rejected[reason] += n
+	}
+	for reason, n := range other.Rejected {
+		sum.Rejected[reason] += n
+	}
+	return sum
+}
+
+// String returns a one-line representation of an IngestSummary, e.g. for logging.
+func (s IngestSummary) String() string {
+	reasons := make([]string, 0, len(s.Rejected))
+	for reason, n := range s.Rejected {
+		reasons = append(reasons, reason+": "+strconv.Itoa(n))
+	}
+	sort.Strings(reasons)
+
+	return "records: " + strconv.Itoa(s.Records) +
+		", ticks: " + strconv.Itoa(s.Ticks) +
+		", rejected: [" + strings.Join(reasons, ", ") + "]"
+}
+
+// ------------------------------------------------------------------
+
+// rejectLog applies a bad record policy to the records rejected by workers,
+// and keeps count of records read.
+type rejectLog struct {
+	mu      sync.Mutex
+	policy  string
+	name    string
+	file    *os.File
+	w       *csv.Writer
+	summary IngestSummary
+}
+
+// rejectsFile returns the name of the file a data file's bad records are quarantined to.
+// If not configured, it is rejects.csv, or trades_rejects.csv for trade files, in dir.
+// An empty name is returned if neither the file nor dir are given.
+func rejectsFile(f config.File, dir string) string {
+	switch {
+	case f.RejectsFile != "":
+		return f.RejectsFile
+	case dir == "":
+		return ""
+	case f.Kind == config.KindTrades:
+		return filepath.Join(dir, "trades_rejects.csv")
+	}
+	return filepath.Join(dir, "rejects.csv")
+}
+
+// newRejectLog returns a rejectLog for the given policy.
+// Quarantined records are written to the file name, which is created when first needed.
+func newRejectLog(policy, name string) (*rejectLog, error) {
+	switch policy {
+	case "":
+		policy = config.OnBadRecordSkip
+	case config.OnBadRecordSkip, config.OnBadRecordFail:
+	case config.OnBadRecordQuarantine:
+		if name == "" {
+			return nil, ErrNoRejectsFile
+		}
+	default:
+		return nil, ErrInvalidBadRecordPolicy
+	}
+	l := &rejectLog{
+		policy:  policy,
+		name:    name,
+		summary: IngestSummary{Rejected: make(map[string]int)},
+	}
+	return l, nil
+}
+
+// read counts records read and ticks loaded from data files.
+func (l *rejectLog) read(records, ticks int) {
+	l.mu.Lock()
+	l.summary.Records += records
+	l.summary.Ticks += ticks
+	l.mu.Unlock()
+}
+
+// reject counts a bad record, returning a *RecordError if the policy is to fail.
+// record is the text of the record, as read from file.
+func (l *rejectLog) reject(file string, line int, reason error, record string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.summary.Rejected[reason.Error()]++
+
+	switch l.policy {
+	case config.OnBadRecordFail:
+		return &RecordError{File: file, Line: line, Reason: reason}
+
+	case config.OnBadRecordQuarantine:
+		if l.w == nil {
+			var err error
+			if l.file, err = os.Create(l.name); err != nil {
+				return err
+			}
+			l.w = csv.NewWriter(l.file)
+			l.w.Write([]string{"File", "Line", "Reason", "Record"})
+		}
+		l.w.Write([]string{file, strconv.Itoa(line), reason.Error(), record})
+		return l.w.Error()
+	}
+	return nil
+}
+
+// Summary returns counts of the records read and rejected so far.
+func (l *rejectLog) Summary() IngestSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	summary := l.summary
+	summary.Rejected = make(map[string]int, len(l.summary.Rejected))
+	for reason, n := range l.summary.Rejected {
+		summary.Rejected[reason] = n
+	}
+	return summary
+}
+
+// Close flushes any quarantined records to the rejects file.
+func (l *rejectLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.w == nil {
+		return nil
+	}
+	l.w.Flush()
+	if err := l.w.Error(); err != nil {
+		l.file.Close()
+		return err
+	}
+	return l.file.Close()
+}
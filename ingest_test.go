@@ -0,0 +1,136 @@
+package porttools
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+func mockRejectLog(t testing.TB, policy string) *rejectLog {
+	rejects, err := newRejectLog(policy, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rejects
+}
+
+func Test_worker_run_badRecords(t *testing.T) {
+	cols := colConfig{
+		tStamp: config.Column{Index: 0}, tick: config.Column{Index: 1},
+		bid: config.Column{Index: 2}, bidSz: config.Column{Index: 3},
+		ask: config.Column{Index: 4}, askSz: config.Column{Index: 5},
+		parseTime: mockParseTime, delim: ',',
+	}
+	data := "1,AAPL,50.00,10,50.10,10\n" +
+		"2,AAPL,0,10,50.10,10\n" +
+		"3,AAPL,50.00,ten,50.10,10\n" +
+		"4,AAPL\n" +
+		"5,GOOGL,10.00,5,10.10,5\n" +
+		"6,AA\"PL,10.00,5,10.10,5\n"
+
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rejectsFile := filepath.Join(dir, "rejects.csv")
+
+	tests := []struct {
+		name         string
+		policy       string
+		wantTicks    []string
+		wantErr      bool
+		wantRejected map[string]int
+	}{
+		{"Skip", config.OnBadRecordSkip, []string{"AAPL", "GOOGL"}, false,
+			map[string]int{ErrZeroBid.Error(): 1, ErrBidSize.Error(): 1, ErrMissingFields.Error(): 1, ErrMalformedRecord.Error(): 1}},
+		{"Fail", config.OnBadRecordFail, []string{"AAPL"}, true,
+			map[string]int{ErrZeroBid.Error(): 1}},
+		{"Quarantine", config.OnBadRecordQuarantine, []string{"AAPL", "GOOGL"}, false,
+			map[string]int{ErrZeroBid.Error(): 1, ErrBidSize.Error(): 1, ErrMissingFields.Error(): 1, ErrMalformedRecord.Error(): 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rejects, err := newRejectLog(tt.policy, rejectsFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			outChan := make(chan instrument.Event, 5)
+
+			worker := newWorker(cols, rejects)
+			worker.file = "mock_20170814"
+			err = worker.run(outChan, strings.NewReader(data))
+			close(outChan)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("worker.run() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if recordErr, ok := err.(*RecordError); tt.wantErr && (!ok || recordErr.Line != 2) {
+				t.Errorf("worker.run() error = %v, want *RecordError on line 2", err)
+			}
+			if err = rejects.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for event := range outChan {
+				got = append(got, event.Ticker())
+			}
+			if strings.Join(got, ",") != strings.Join(tt.wantTicks, ",") {
+				t.Errorf("worker.run() tickers = %v, want %v", got, tt.wantTicks)
+			}
+
+			summary := rejects.Summary()
+			for reason, n := range tt.wantRejected {
+				if summary.Rejected[reason] != n {
+					t.Errorf("Summary().Rejected[%q] = %d, want %d", reason, summary.Rejected[reason], n)
+				}
+			}
+
+			if tt.policy == config.OnBadRecordQuarantine {
+				quarantined, err := ioutil.ReadFile(rejectsFile)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !strings.Contains(string(quarantined), "mock_20170814,3,bid size could not be parsed,\"3,AAPL,50.00,ten,50.10,10\"") {
+					t.Errorf("rejects file = %q, want bad bid size record on line 3", quarantined)
+				}
+				if !strings.Contains(string(quarantined), "mock_20170814,6,malformed record,\"6,AA\"\"PL,10.00,5,10.10,5\"") {
+					t.Errorf("rejects file = %q, want malformed record on line 6", quarantined)
+				}
+			}
+		})
+	}
+
+	if _, err = newRejectLog("ignore", ""); err != ErrInvalidBadRecordPolicy {
+		t.Errorf("newRejectLog() error = %v, want %v", err, ErrInvalidBadRecordPolicy)
+	}
+	if _, err = newRejectLog(config.OnBadRecordQuarantine, ""); err != ErrNoRejectsFile {
+		t.Errorf("newRejectLog() error = %v, want %v", err, ErrNoRejectsFile)
+	}
+}
+
+func Test_rejectsFile(t *testing.T) {
+	tests := []struct {
+		name string
+		file config.File
+		dir  string
+		want string
+	}{
+		{"Quotes", config.File{}, "out", filepath.Join("out", "rejects.csv")},
+		{"Trades", config.File{Kind: config.KindTrades}, "out", filepath.Join("out", "trades_rejects.csv")},
+		{"Configured", config.File{Kind: config.KindTrades, RejectsFile: "bad.csv"}, "out", "bad.csv"},
+		{"No output directory", config.File{}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rejectsFile(tt.file, tt.dir); got != tt.want {
+				t.Errorf("rejectsFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package instrument
 
 import (
+	"math"
 	"time"
 
 	"github.com/jakeschurch/porttools/utils"
@@ -17,6 +18,14 @@ type Financial interface {
 
 // ------------------------------------------------------------------
 
+// Event is an interface for timestamped market data, such as ticks and bars.
+type Event interface {
+	Ticker() string
+	Time() time.Time
+}
+
+// ------------------------------------------------------------------
+
 // Instrument is the base type of a financial widget.
 type Instrument struct {
 	ticker string
@@ -29,7 +38,7 @@ func NewInstrument(ticker string, volume utils.Amount) *Instrument {
 	instrument := new(Instrument)
 	instrument.ticker = ticker
 	instrument.Nticks = 0
-	instrument.Volume(volume)
+	instrument.dxVolume(volume)
 	return instrument
 }
 
@@ -43,6 +52,12 @@ func (i Instrument) Volume(delta utils.Amount) utils.Amount {
 	return i.dxVolume(delta)
 }
 
+// AdjustVolume adds delta to an instrument's volume, returning the new volume.
+// Unlike Volume, it changes the instrument it is called on, rather than a copy of it.
+func (i *Instrument) AdjustVolume(delta utils.Amount) utils.Amount {
+	return i.dxVolume(delta)
+}
+
 func (i *Instrument) dxVolume(delta utils.Amount) utils.Amount {
 	i.volume += delta
 	return i.volume
@@ -52,6 +67,16 @@ func (i Instrument) Ticker() string {
 	return i.ticker
 }
 
+// SetTicker renames an instrument, e.g. on a change of symbol.
+func (i *Instrument) SetTicker(ticker string) {
+	i.ticker = ticker
+}
+
+// Split adjusts an instrument's volume for a stock split of ratio new shares for each old share.
+func (i *Instrument) Split(ratio float64) {
+	i.volume = utils.Amount(math.Round(float64(i.volume) * ratio))
+}
+
 // Update for an instrument is used to  implement Financial interface
 func (i Instrument) Update(q Quote) error {
 	return nil
@@ -80,12 +105,26 @@ func (q Quote) Ticker() string {
 	return q.ticker
 }
 
+// Split adjusts a quote's prices and volume for a stock split of ratio new shares for each old share.
+func (q *Quote) Split(ratio float64) {
+	q.Instrument.Split(ratio)
+	q.Bid, q.Ask = splitPrice(q.Bid, ratio), splitPrice(q.Ask, ratio)
+}
+
+// Mid returns the midpoint of a quote's bid and ask prices.
+func (q Quote) Mid() utils.Amount {
+	return (q.Bid + q.Ask) / 2
+}
+
 // ------------------------------------------------------------------
 
 // Tick structs holds information about a financial asset at a specific point in time.
 type Tick struct {
 	*Quote
 	BidSize, AskSize utils.Amount
+	// Venue is the exchange a tick was quoted on,
+	// or empty for ticks of the whole market, such as a national best bid and offer.
+	Venue string
 }
 
 func NewTick(bidSz, askSz utils.Amount, q *Quote) *Tick {
@@ -103,6 +142,111 @@ func (t *Tick) SetTicker(ticker string) {
 	t.ticker = ticker
 }
 
+// Time returns the time a tick was quoted at.
+func (t *Tick) Time() time.Time {
+	return t.Timestamp
+}
+
+// ------------------------------------------------------------------
+
+// Trade structs hold information about an execution of a financial asset, i.e. a last sale.
+type Trade struct {
+	Instrument
+	Price, Size utils.Amount
+	Conditions  string
+	Timestamp   time.Time
+}
+
+// NewTrade instantiates a Trade of size shares of ticker at price.
+func NewTrade(ticker string, price, size utils.Amount, conditions string, ts time.Time) *Trade {
+	return &Trade{
+		Instrument: Instrument{ticker: ticker},
+		Price:      price, Size: size,
+		Conditions: conditions, Timestamp: ts,
+	}
+}
+
+// Time returns the time a trade was executed at.
+func (t *Trade) Time() time.Time {
+	return t.Timestamp
+}
+
+// ------------------------------------------------------------------
+
+// OHLC holds the open, high, low and close prices of an interval of time.
+type OHLC struct {
+	Open, High, Low, Close utils.Amount
+}
+
+// NewOHLC instantiates an OHLC that opens at price.
+func NewOHLC(price utils.Amount) OHLC {
+	return OHLC{Open: price, High: price, Low: price, Close: price}
+}
+
+// Update brings an OHLC's high, low and close up to date with a new price.
+func (p *OHLC) Update(price utils.Amount) {
+	if price > p.High {
+		p.High = price
+	}
+	if price < p.Low {
+		p.Low = price
+	}
+	p.Close = price
+}
+
+// Bar structs hold aggregate information about a financial asset over an interval of time.
+// Bars aggregated from ticks hold quote prices, whereas bars loaded from
+// OHLCV files hold traded prices and volume.
+type Bar struct {
+	Instrument
+	Start, End       time.Time
+	Bid, Ask, Mid    OHLC
+	BidSize, AskSize utils.Amount
+
+	Price  OHLC
+	Volume utils.Amount
+}
+
+// NewOHLCVBar instantiates a Bar of traded prices and volume over the interval [start, end).
+func NewOHLCVBar(ticker string, start, end time.Time, price OHLC, volume utils.Amount) *Bar {
+	return &Bar{
+		Instrument: Instrument{ticker: ticker, Nticks: 1},
+		Start:      start, End: end,
+		Price: price, Volume: volume,
+	}
+}
+
+// NewBar instantiates a Bar over the interval [start, end) that opens with tick t.
+func NewBar(start, end time.Time, t Tick) *Bar {
+	bar := &Bar{
+		Instrument: Instrument{ticker: t.Ticker(), Nticks: 1},
+		Start:      start, End: end,
+		Bid: NewOHLC(t.Bid), Ask: NewOHLC(t.Ask), Mid: NewOHLC(t.Mid()),
+		BidSize: t.BidSize, AskSize: t.AskSize,
+	}
+	return bar
+}
+
+// Add updates a bar's metrics with tick t.
+func (b *Bar) Add(t Tick) {
+	b.Bid.Update(t.Bid)
+	b.Ask.Update(t.Ask)
+	b.Mid.Update(t.Mid())
+	b.BidSize += t.BidSize
+	b.AskSize += t.AskSize
+	b.Nticks++
+}
+
+// Time returns the time a bar is complete at.
+func (b *Bar) Time() time.Time {
+	return b.End
+}
+
+// Quote returns a quote of a bar at the given price, as of ts.
+func (b *Bar) Quote(price utils.Amount, ts time.Time) *Quote {
+	return NewQuote(price, price, ts, Instrument{ticker: b.ticker})
+}
+
 // ------------------------------------------------------------------
 
 // Asset is tradeable instrument type.
@@ -113,6 +257,17 @@ type Asset struct {
 	AvgBid, AvgAsk   utils.Amount
 	MaxBid, MaxAsk   *utils.DatedMetric
 	MinBid, MinAsk   *utils.DatedMetric
+
+	TradeMetrics
+}
+
+// TradeMetrics are the last sale, volume-weighted average price and cumulative traded volume
+// gathered from the trade prints of a security.
+type TradeMetrics struct {
+	LastTrade    *utils.DatedMetric
+	VWAP         utils.Amount
+	TradedVolume utils.Amount
+	tradedValue  utils.Amount
 }
 
 // GetUnderlying returns an asset's embedded Quote type.
@@ -156,12 +311,69 @@ func (a *Asset) update(q Quote) error {
 	return nil
 }
 
+// Split adjusts an asset's price metrics and volumes for a stock split of ratio new shares for each old share.
+func (a *Asset) Split(ratio float64) {
+	if a.Quote != nil {
+		a.Quote.Split(ratio)
+	}
+	a.adjustPrices(ratio)
+	a.TradedVolume = utils.Amount(math.Round(float64(a.TradedVolume) * ratio))
+}
+
+// Dividend back-adjusts an asset's price metrics for a cash dividend of amount a share,
+// so that returns measured from them include the dividend paid.
+// Prices are scaled by the dividend's share of the last bid before the ex-date.
+func (a *Asset) Dividend(amount utils.Amount) {
+	if a.LastBid == nil || a.LastBid.Amount <= amount || amount <= 0 {
+		return
+	}
+	ratio := float64(a.LastBid.Amount) / float64(a.LastBid.Amount-amount)
+	if a.Quote != nil {
+		a.Quote.Bid, a.Quote.Ask = splitPrice(a.Quote.Bid, ratio), splitPrice(a.Quote.Ask, ratio)
+	}
+	a.adjustPrices(ratio)
+}
+
+// adjustPrices divides an asset's price metrics by ratio.
+func (a *Asset) adjustPrices(ratio float64) {
+	a.AvgBid, a.AvgAsk = splitPrice(a.AvgBid, ratio), splitPrice(a.AvgAsk, ratio)
+	for _, metric := range []**utils.DatedMetric{
+		&a.LastBid, &a.MaxBid, &a.MinBid,
+		&a.LastAsk, &a.MaxAsk, &a.MinAsk,
+		&a.LastTrade,
+	} {
+		*metric = splitMetric(*metric, ratio)
+	}
+	a.VWAP = splitPrice(a.VWAP, ratio)
+}
+
+// Split adjusts trade metrics for a stock split of ratio new shares for each old share.
+func (m *TradeMetrics) Split(ratio float64) {
+	m.LastTrade = splitMetric(m.LastTrade, ratio)
+	m.VWAP = splitPrice(m.VWAP, ratio)
+	m.TradedVolume = utils.Amount(math.Round(float64(m.TradedVolume) * ratio))
+}
+
+// AddTrade uses a trade print to update the last sale,
+// volume-weighted average price and cumulative traded volume.
+func (a *TradeMetrics) AddTrade(t Trade) {
+	a.LastTrade = &utils.DatedMetric{Amount: t.Price, Date: t.Timestamp}
+
+	a.TradedVolume += t.Size
+	a.tradedValue += t.Price * t.Size
+	if a.TradedVolume != 0 {
+		a.VWAP = a.tradedValue / a.TradedVolume
+	}
+}
+
 // ------------------------------------------------------------------
 
 // Holding structs refer the holding of a financial asset.
 type Holding struct {
 	Instrument
 	BuyPrice, SellPrice *utils.DatedMetric
+	// BenchmarkBuy is the benchmark's level of the holding's ticker when it was bought, if it had one.
+	BenchmarkBuy *utils.DatedMetric
 }
 
 // NewHolding instantities struct of type Holding.
@@ -172,6 +384,12 @@ func NewHolding(i Instrument, buyPrice *utils.DatedMetric) *Holding {
 	}
 }
 
+// Split adjusts a holding's volume and prices for a stock split of ratio new shares for each old share.
+func (h *Holding) Split(ratio float64) {
+	h.Instrument.Split(ratio)
+	h.BuyPrice, h.SellPrice = splitMetric(h.BuyPrice, ratio), splitMetric(h.SellPrice, ratio)
+}
+
 // GetUnderlying method of Holding returns an instrument.Instrument type.
 func (h Holding) GetUnderlying() Financial {
 	return h.Instrument
@@ -185,6 +403,9 @@ func (h Holding) GetUnderlying() Financial {
 type Security struct {
 	Asset
 	BuyPrice, SellPrice *utils.DatedMetric
+	// BenchmarkBuy and BenchmarkSell are the benchmark's levels of the security's ticker
+	// when it was bought and sold, if it had them.
+	BenchmarkBuy, BenchmarkSell *utils.DatedMetric
 }
 
 // NewSecurity instantiates a security object from Tick data.
@@ -195,9 +416,29 @@ func NewSecurity(buy, sell *utils.DatedMetric, asset Asset) *Security {
 	}
 }
 
+// Split adjusts a security's metrics for a stock split of ratio new shares for each old share.
+func (s *Security) Split(ratio float64) {
+	s.Asset.Split(ratio)
+	s.BuyPrice, s.SellPrice = splitMetric(s.BuyPrice, ratio), splitMetric(s.SellPrice, ratio)
+}
+
 // GetUnderlying method for security returns an instrument.Asset type.
 func (s Security) GetUnderlying() Financial {
 	return s.Asset
 }
 
 // ------------------------------------------------------------------
+
+// splitPrice adjusts a price for a stock split of ratio new shares for each old share.
+func splitPrice(price utils.Amount, ratio float64) utils.Amount {
+	return utils.Amount(math.Round(float64(price) / ratio))
+}
+
+// splitMetric returns a copy of a price metric adjusted for a stock split,
+// as metrics may be shared between instruments. Nil metrics are left as they are.
+func splitMetric(metric *utils.DatedMetric, ratio float64) *utils.DatedMetric {
+	if metric == nil {
+		return nil
+	}
+	return &utils.DatedMetric{Amount: splitPrice(metric.Amount, ratio), Date: metric.Date}
+}
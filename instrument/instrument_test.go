@@ -0,0 +1,81 @@
+package instrument
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func TestAsset_AddTrade(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		trades     []*Trade
+		wantVWAP   utils.Amount
+		wantVolume utils.Amount
+		wantLast   utils.Amount
+	}{
+		{"Single trade", []*Trade{
+			NewTrade("AAPL", utils.FloatAmount(50.00), 100, "", open),
+		}, utils.FloatAmount(50.00), 100, utils.FloatAmount(50.00)},
+		{"Volume weighted", []*Trade{
+			NewTrade("AAPL", utils.FloatAmount(50.00), 100, "", open),
+			NewTrade("AAPL", utils.FloatAmount(51.00), 300, "", open.Add(time.Second)),
+		}, utils.FloatAmount(50.75), 400, utils.FloatAmount(51.00)},
+		{"Zero size trade", []*Trade{
+			NewTrade("AAPL", utils.FloatAmount(50.00), 0, "", open),
+		}, 0, 0, utils.FloatAmount(50.00)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAsset(NewQuote(utils.FloatAmount(49.90), utils.FloatAmount(50.10), open, *NewInstrument("AAPL", 0)))
+			for _, trade := range tt.trades {
+				a.AddTrade(*trade)
+			}
+
+			if a.VWAP != tt.wantVWAP {
+				t.Errorf("Asset.VWAP = %d, want %d", a.VWAP, tt.wantVWAP)
+			}
+			if a.TradedVolume != tt.wantVolume {
+				t.Errorf("Asset.TradedVolume = %d, want %d", a.TradedVolume, tt.wantVolume)
+			}
+			last := tt.trades[len(tt.trades)-1]
+			if a.LastTrade == nil || a.LastTrade.Amount != tt.wantLast || !a.LastTrade.Date.Equal(last.Timestamp) {
+				t.Errorf("Asset.LastTrade = %+v, want %d at %v", a.LastTrade, tt.wantLast, last.Timestamp)
+			}
+		})
+	}
+}
+
+func TestAsset_Dividend(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		amount  utils.Amount
+		wantBid utils.Amount
+		wantAsk utils.Amount
+	}{
+		{"Cash dividend", utils.FloatAmount(0.50), utils.FloatAmount(49.50), utils.FloatAmount(49.70)},
+		{"Zero dividend", 0, utils.FloatAmount(50.00), utils.FloatAmount(50.20)},
+		{"Dividend above price", utils.FloatAmount(60.00), utils.FloatAmount(50.00), utils.FloatAmount(50.20)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAsset(NewQuote(utils.FloatAmount(50.00), utils.FloatAmount(50.20), open, *NewInstrument("AAPL", 100)))
+			a.Dividend(tt.amount)
+
+			if a.LastBid.Amount != tt.wantBid || a.LastAsk.Amount != tt.wantAsk {
+				t.Errorf("Asset.Dividend() last = %d/%d, want %d/%d", a.LastBid.Amount, a.LastAsk.Amount, tt.wantBid, tt.wantAsk)
+			}
+			if a.Quote.Bid != tt.wantBid || a.Quote.Ask != tt.wantAsk {
+				t.Errorf("Asset.Dividend() quote = %d/%d, want %d/%d", a.Quote.Bid, a.Quote.Ask, tt.wantBid, tt.wantAsk)
+			}
+			if a.Quote.Volume(0) != 100 {
+				t.Errorf("Asset.Dividend() volume = %d, want 100", a.Quote.Volume(0))
+			}
+		})
+	}
+}
@@ -0,0 +1,425 @@
+// Package itch parses NASDAQ TotalView-ITCH 5.0 binary captures,
+// building the top of book of each stock from its order messages.
+//
+// Captures are expected in the framing of NASDAQ's historical files,
+// with each message preceded by its length as a 2-byte big-endian integer.
+package itch
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+var (
+	// ErrShortMessage indicates a message shorter than its type requires.
+	ErrShortMessage = errors.New("ITCH message is shorter than its type requires")
+
+	// ErrUnknownOrder indicates a message referring to an order that was never added.
+	ErrUnknownOrder = errors.New("ITCH message refers to an unknown order")
+)
+
+// Message types that are parsed. Messages of other types are skipped.
+const (
+	SystemEvent          = 'S'
+	StockDirectory       = 'R'
+	AddOrder             = 'A'
+	AddOrderMPID         = 'F'
+	OrderExecuted        = 'E'
+	OrderExecutedAtPrice = 'C'
+	OrderCancel          = 'X'
+	OrderDelete          = 'D'
+	OrderReplace         = 'U'
+	Trade                = 'P'
+)
+
+// EndOfMessages is the event code of the system event sent after the last message of a day.
+const EndOfMessages = 'C'
+
+// messageLength holds the length of each parsed message type.
+var messageLength = map[byte]int{
+	SystemEvent:          12,
+	StockDirectory:       39,
+	AddOrder:             36,
+	AddOrderMPID:         40,
+	OrderExecuted:        31,
+	OrderExecutedAtPrice: 36,
+	OrderCancel:          23,
+	OrderDelete:          19,
+	OrderReplace:         35,
+	Trade:                44,
+}
+
+// headerLength is the length of the stock locate, tracking number and timestamp
+// that follow the type of every message.
+const headerLength = 11
+
+// priceScale is the number of ITCH price units, of 1/10000 dollars, in a utils.Amount.
+const priceScale = 100
+
+// ------------------------------------------------------------------
+
+// Parser reads ticks and trades from an ITCH capture.
+type Parser struct {
+	r      *bufio.Reader
+	date   time.Time
+	filter func(ticker string) bool
+
+	stocks map[uint16]*stock
+	orders map[uint64]*order
+	events []instrument.Event
+	buf    []byte
+	done   bool
+}
+
+// stock holds the order book of a stock, by its locate code.
+type stock struct {
+	ticker string
+	skip   bool
+	bids   *book
+	asks   *book
+	top    top
+}
+
+// top is the best bid and ask of a book, with the shares quoted at each.
+type top struct {
+	bid, bidSize, ask, askSize uint64
+}
+
+type order struct {
+	stock  *stock
+	buy    bool
+	shares uint32
+	price  uint32
+}
+
+// NewParser returns a Parser reading a capture of the trading day date from r.
+// Timestamps are taken as nanoseconds since midnight of date, in date's location.
+// If filter is not nil, only stocks it returns true for are replayed.
+func NewParser(r io.Reader, date time.Time, filter func(ticker string) bool) *Parser {
+	year, month, day := date.Date()
+	return &Parser{
+		r:      bufio.NewReaderSize(r, 64*1024),
+		date:   time.Date(year, month, day, 0, 0, 0, 0, date.Location()),
+		filter: filter,
+		stocks: make(map[uint16]*stock),
+		orders: make(map[uint64]*order),
+		buf:    make([]byte, 0, 64),
+	}
+}
+
+// Next returns the next tick or trade of the capture.
+// A tick is returned whenever the top of a stock's book changes while quoted on both sides.
+// io.EOF is returned after the end of messages, or the end of the capture.
+func (p *Parser) Next() (instrument.Event, error) {
+	for len(p.events) == 0 {
+		if p.done {
+			return nil, io.EOF
+		}
+		if err := p.read(); err != nil {
+			return nil, err
+		}
+	}
+	event := p.events[0]
+	p.events = p.events[1:]
+	return event, nil
+}
+
+// read parses the next message of the capture.
+func (p *Parser) read() error {
+	var size [2]byte
+	if _, err := io.ReadFull(p.r, size[:]); err != nil {
+		if err == io.EOF {
+			p.done = true
+			return nil
+		}
+		return err
+	}
+	n := int(binary.BigEndian.Uint16(size[:]))
+	if cap(p.buf) < n {
+		p.buf = make([]byte, n)
+	}
+	msg := p.buf[:n]
+	if _, err := io.ReadFull(p.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	if n == 0 {
+		return nil
+	}
+
+	length, ok := messageLength[msg[0]]
+	if !ok {
+		return nil
+	}
+	if n < length {
+		return ErrShortMessage
+	}
+	return p.parse(msg)
+}
+
+func (p *Parser) parse(msg []byte) error {
+	locate := binary.BigEndian.Uint16(msg[1:])
+	body := msg[headerLength:]
+
+	switch msg[0] {
+	case SystemEvent:
+		if body[0] == EndOfMessages {
+			p.done = true
+		}
+
+	case StockDirectory:
+		p.stock(locate, body[0:8])
+
+	case AddOrder, AddOrderMPID:
+		s := p.stock(locate, body[13:21])
+		if s.skip {
+			return nil
+		}
+		o := &order{
+			stock:  s,
+			buy:    body[8] == 'B',
+			shares: binary.BigEndian.Uint32(body[9:]),
+			price:  binary.BigEndian.Uint32(body[21:]),
+		}
+		p.orders[binary.BigEndian.Uint64(body)] = o
+		s.side(o.buy).add(o.price, uint64(o.shares))
+		p.quote(s, msg)
+
+	case OrderExecuted, OrderExecutedAtPrice:
+		o, ok, err := p.order(binary.BigEndian.Uint64(body))
+		if !ok {
+			return err
+		}
+		shares := binary.BigEndian.Uint32(body[8:])
+		price := o.price
+		printable := true
+		if msg[0] == OrderExecutedAtPrice {
+			printable = body[20] == 'Y'
+			price = binary.BigEndian.Uint32(body[21:])
+		}
+		if printable {
+			p.trade(o.stock, price, shares, msg)
+		}
+		p.reduce(binary.BigEndian.Uint64(body), o, shares)
+		p.quote(o.stock, msg)
+
+	case OrderCancel:
+		o, ok, err := p.order(binary.BigEndian.Uint64(body))
+		if !ok {
+			return err
+		}
+		p.reduce(binary.BigEndian.Uint64(body), o, binary.BigEndian.Uint32(body[8:]))
+		p.quote(o.stock, msg)
+
+	case OrderDelete:
+		o, ok, err := p.order(binary.BigEndian.Uint64(body))
+		if !ok {
+			return err
+		}
+		p.reduce(binary.BigEndian.Uint64(body), o, o.shares)
+		p.quote(o.stock, msg)
+
+	case OrderReplace:
+		o, ok, err := p.order(binary.BigEndian.Uint64(body))
+		if !ok {
+			return err
+		}
+		p.reduce(binary.BigEndian.Uint64(body), o, o.shares)
+
+		replacement := &order{
+			stock:  o.stock,
+			buy:    o.buy,
+			shares: binary.BigEndian.Uint32(body[16:]),
+			price:  binary.BigEndian.Uint32(body[20:]),
+		}
+		p.orders[binary.BigEndian.Uint64(body[8:])] = replacement
+		o.stock.side(o.buy).add(replacement.price, uint64(replacement.shares))
+		p.quote(o.stock, msg)
+
+	case Trade:
+		s := p.stock(locate, body[13:21])
+		if s.skip {
+			return nil
+		}
+		p.trade(s, binary.BigEndian.Uint32(body[21:]), binary.BigEndian.Uint32(body[9:]), msg)
+	}
+	return nil
+}
+
+// stock returns the stock of a locate code, adding it with the given symbol if it is new.
+func (p *Parser) stock(locate uint16, symbol []byte) *stock {
+	s, ok := p.stocks[locate]
+	if !ok {
+		ticker := strings.TrimRight(string(symbol), " ")
+		s = &stock{
+			ticker: ticker,
+			skip:   p.filter != nil && !p.filter(ticker),
+			bids:   newBook(true),
+			asks:   newBook(false),
+		}
+		p.stocks[locate] = s
+	}
+	return s
+}
+
+// order looks up an order by its reference number.
+// Orders of stocks that are filtered out are never added, and are not an error.
+func (p *Parser) order(ref uint64) (*order, bool, error) {
+	o, ok := p.orders[ref]
+	if ok {
+		return o, true, nil
+	}
+	if p.filter != nil {
+		return nil, false, nil
+	}
+	return nil, false, ErrUnknownOrder
+}
+
+// reduce takes shares off an order, removing it once none are left.
+func (p *Parser) reduce(ref uint64, o *order, shares uint32) {
+	if shares > o.shares {
+		shares = o.shares
+	}
+	o.shares -= shares
+	o.stock.side(o.buy).remove(o.price, uint64(shares))
+	if o.shares == 0 {
+		delete(p.orders, ref)
+	}
+}
+
+// quote queues a tick if the top of a stock's book has changed.
+// Books that are empty on either side are not quoted.
+func (p *Parser) quote(s *stock, msg []byte) {
+	t := top{}
+	t.bid, t.bidSize = s.bids.top()
+	t.ask, t.askSize = s.asks.top()
+	if t == s.top {
+		return
+	}
+	s.top = t
+	if t.bidSize == 0 || t.askSize == 0 {
+		return
+	}
+
+	quote := instrument.NewQuote(amount(uint32(t.bid)), amount(uint32(t.ask)), p.timestamp(msg), instrument.Instrument{})
+	tick := instrument.NewTick(utils.Amount(t.bidSize), utils.Amount(t.askSize), quote)
+	tick.SetTicker(s.ticker)
+	p.events = append(p.events, tick)
+}
+
+// trade queues a trade print.
+func (p *Parser) trade(s *stock, price, shares uint32, msg []byte) {
+	p.events = append(p.events, instrument.NewTrade(s.ticker, amount(price), utils.Amount(shares), "", p.timestamp(msg)))
+}
+
+// timestamp returns the time of a message, given in its header as
+// a 6-byte count of nanoseconds since midnight.
+func (p *Parser) timestamp(msg []byte) time.Time {
+	var ns uint64
+	for _, b := range msg[5:headerLength] {
+		ns = ns<<8 | uint64(b)
+	}
+	return p.date.Add(time.Duration(ns))
+}
+
+func (s *stock) side(buy bool) *book {
+	if buy {
+		return s.bids
+	}
+	return s.asks
+}
+
+// amount converts an ITCH price to an amount, rounding to the nearest cent.
+func amount(price uint32) utils.Amount {
+	return utils.Amount((price + priceScale/2) / priceScale)
+}
+
+// ------------------------------------------------------------------
+
+// book holds the shares resting at each price level of one side of a stock's book.
+// Price levels are kept in a heap ordered best first, so that the best level is found in O(log n)
+// as levels are emptied. Emptied levels are left in the heap until they reach its top.
+type book struct {
+	levels map[uint32]uint64
+	prices priceHeap
+	// queued holds the prices in the heap, emptied or not.
+	queued map[uint32]bool
+}
+
+func newBook(bids bool) *book {
+	return &book{
+		levels: make(map[uint32]uint64),
+		prices: priceHeap{bids: bids},
+		queued: make(map[uint32]bool),
+	}
+}
+
+func (b *book) add(price uint32, shares uint64) {
+	b.levels[price] += shares
+	if !b.queued[price] {
+		b.queued[price] = true
+		heap.Push(&b.prices, price)
+	}
+}
+
+func (b *book) remove(price uint32, shares uint64) {
+	level := b.levels[price]
+	if shares < level {
+		b.levels[price] = level - shares
+		return
+	}
+	delete(b.levels, price)
+}
+
+// top returns the best price of the book and the shares resting at it,
+// dropping emptied levels from the top of the heap.
+func (b *book) top() (price, shares uint64) {
+	for len(b.prices.prices) > 0 {
+		best := b.prices.prices[0]
+		if shares, ok := b.levels[best]; ok {
+			return uint64(best), shares
+		}
+		heap.Pop(&b.prices)
+		delete(b.queued, best)
+	}
+	return 0, 0
+}
+
+// priceHeap implements heap.Interface for the price levels of a book,
+// ordered highest first for bids, and lowest first for asks.
+type priceHeap struct {
+	bids   bool
+	prices []uint32
+}
+
+func (h priceHeap) Len() int { return len(h.prices) }
+
+func (h priceHeap) Less(i, j int) bool {
+	if h.bids {
+		return h.prices[i] > h.prices[j]
+	}
+	return h.prices[i] < h.prices[j]
+}
+
+func (h priceHeap) Swap(i, j int) { h.prices[i], h.prices[j] = h.prices[j], h.prices[i] }
+
+func (h *priceHeap) Push(x interface{}) {
+	h.prices = append(h.prices, x.(uint32))
+}
+
+func (h *priceHeap) Pop() interface{} {
+	n := len(h.prices)
+	price := h.prices[n-1]
+	h.prices = h.prices[:n-1]
+	return price
+}
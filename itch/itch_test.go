@@ -0,0 +1,218 @@
+package itch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+var mockDate = time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+
+// mockCapture frames messages as they are laid out in a capture.
+func mockCapture(msgs ...[]byte) io.Reader {
+	var buf bytes.Buffer
+	for _, msg := range msgs {
+		binary.Write(&buf, binary.BigEndian, uint16(len(msg)))
+		buf.Write(msg)
+	}
+	return &buf
+}
+
+// message builds a message of type typ for locate at ns nanoseconds past midnight,
+// followed by fields written in big-endian order.
+func message(typ byte, locate uint16, ns uint64, fields ...interface{}) []byte {
+	var buf bytes.Buffer
+	buf.WriteByte(typ)
+	binary.Write(&buf, binary.BigEndian, locate)
+	binary.Write(&buf, binary.BigEndian, uint16(0))
+	for i := 5; i >= 0; i-- {
+		buf.WriteByte(byte(ns >> (8 * uint(i))))
+	}
+	for _, field := range fields {
+		switch f := field.(type) {
+		case string:
+			buf.WriteString(f)
+		default:
+			binary.Write(&buf, binary.BigEndian, f)
+		}
+	}
+	return buf.Bytes()
+}
+
+func symbol(ticker string) string {
+	return ticker + "        "[len(ticker):]
+}
+
+func addOrder(locate uint16, ns, ref uint64, side byte, shares uint32, ticker string, price uint32) []byte {
+	return message(AddOrder, locate, ns, ref, side, shares, symbol(ticker), price)
+}
+
+func mockTick(ticker string, bid, bidSize, ask, askSize float64, ns uint64) *instrument.Tick {
+	quote := instrument.NewQuote(utils.FloatAmount(bid), utils.FloatAmount(ask), mockDate.Add(time.Duration(ns)), instrument.Instrument{})
+	tick := instrument.NewTick(utils.Amount(bidSize), utils.Amount(askSize), quote)
+	tick.SetTicker(ticker)
+	return tick
+}
+
+func mockTrade(ticker string, price, size float64, ns uint64) *instrument.Trade {
+	return instrument.NewTrade(ticker, utils.FloatAmount(price), utils.Amount(size), "", mockDate.Add(time.Duration(ns)))
+}
+
+func TestParser_Next(t *testing.T) {
+	book := [][]byte{
+		addOrder(1, 100, 1, 'B', 100, "AAPL", 1599900),
+		addOrder(1, 200, 2, 'S', 200, "AAPL", 1600100),
+	}
+	top := mockTick("AAPL", 159.99, 100, 160.01, 200, 200)
+
+	tests := []struct {
+		name   string
+		msgs   [][]byte
+		filter func(string) bool
+		want   []instrument.Event
+	}{
+		{"Top of book",
+			append(book,
+				addOrder(1, 300, 3, 'B', 50, "AAPL", 1599800),  // behind the best bid
+				addOrder(1, 400, 4, 'B', 50, "AAPL", 1599900)), // joins the best bid
+			nil,
+			[]instrument.Event{top, mockTick("AAPL", 159.99, 150, 160.01, 200, 400)}},
+		{"Execution",
+			append(book, message(OrderExecuted, 1, 300, uint64(2), uint32(50), uint64(1))),
+			nil,
+			[]instrument.Event{top, mockTrade("AAPL", 160.01, 50, 300), mockTick("AAPL", 159.99, 100, 160.01, 150, 300)}},
+		{"Execution at a price",
+			append(book,
+				message(OrderExecutedAtPrice, 1, 300, uint64(1), uint32(100), uint64(1), byte('Y'), uint32(1599950)),
+				addOrder(1, 400, 3, 'B', 100, "AAPL", 1599900),
+				message(OrderExecutedAtPrice, 1, 500, uint64(3), uint32(40), uint64(2), byte('N'), uint32(1599950))),
+			nil,
+			[]instrument.Event{top, mockTrade("AAPL", 160.00, 100, 300),
+				mockTick("AAPL", 159.99, 100, 160.01, 200, 400), mockTick("AAPL", 159.99, 60, 160.01, 200, 500)}},
+		{"Cancel, replace and delete",
+			append(book,
+				message(OrderCancel, 1, 300, uint64(1), uint32(40)),
+				message(OrderReplace, 1, 400, uint64(2), uint64(3), uint32(300), uint32(1600200)),
+				message(OrderDelete, 1, 500, uint64(1))),
+			nil,
+			[]instrument.Event{top, mockTick("AAPL", 159.99, 60, 160.01, 200, 300), mockTick("AAPL", 159.99, 60, 160.02, 300, 400)}},
+		{"Trade message",
+			[][]byte{message(Trade, 1, 100, uint64(0), byte('B'), uint32(300), symbol("AAPL"), uint32(1600000), uint64(1))},
+			nil,
+			[]instrument.Event{mockTrade("AAPL", 160.00, 300, 100)}},
+		{"Filtered stocks",
+			append(book,
+				addOrder(2, 300, 3, 'B', 100, "GOOGL", 9200000),
+				addOrder(2, 400, 4, 'S', 100, "GOOGL", 9201000),
+				message(OrderDelete, 2, 500, uint64(3))),
+			func(ticker string) bool { return ticker == "GOOGL" },
+			[]instrument.Event{mockTick("GOOGL", 920.00, 100, 920.10, 100, 400)}},
+		{"End of messages",
+			append(book,
+				message(SystemEvent, 0, 300, byte(EndOfMessages)),
+				addOrder(1, 400, 3, 'B', 100, "AAPL", 1600000)),
+			nil,
+			[]instrument.Event{top}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(mockCapture(tt.msgs...), mockDate, tt.filter)
+
+			var got []instrument.Event
+			for {
+				event, err := p.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Parser.Next() error = %v", err)
+				}
+				got = append(got, event)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Parser.Next() = %d events, want %d", len(got), len(tt.want))
+			}
+			for i := range got {
+				if !sameEvent(got[i], tt.want[i]) {
+					t.Errorf("Parser.Next()[%d] = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParser_Next_errors(t *testing.T) {
+	tests := []struct {
+		name    string
+		msgs    [][]byte
+		wantErr error
+	}{
+		{"Short message", [][]byte{message(AddOrder, 1, 100, uint64(1))}, ErrShortMessage},
+		{"Unknown order", [][]byte{message(OrderDelete, 1, 100, uint64(1))}, ErrUnknownOrder},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := NewParser(mockCapture(tt.msgs...), mockDate, nil)
+			if _, err := p.Next(); err != tt.wantErr {
+				t.Errorf("Parser.Next() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBook_top(t *testing.T) {
+	type level struct {
+		price  uint32
+		shares uint64
+		remove bool
+	}
+	tests := []struct {
+		name       string
+		bids       bool
+		levels     []level
+		wantPrice  uint64
+		wantShares uint64
+	}{
+		{"Empty", true, nil, 0, 0},
+		{"Best bid", true, []level{{100, 10, false}, {102, 20, false}, {101, 30, false}}, 102, 20},
+		{"Best ask", false, []level{{100, 10, false}, {102, 20, false}, {99, 30, false}}, 99, 30},
+		{"Best bid emptied", true, []level{{100, 10, false}, {102, 20, false}, {101, 30, false}, {102, 20, true}}, 101, 30},
+		{"Best ask partly removed", false, []level{{100, 10, false}, {102, 20, false}, {100, 4, true}}, 100, 6},
+		{"All emptied", false, []level{{100, 10, false}, {102, 20, false}, {100, 10, true}, {102, 20, true}}, 0, 0},
+		{"Emptied level re-added", true, []level{{102, 20, false}, {101, 30, false}, {102, 20, true}, {102, 5, false}}, 102, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBook(tt.bids)
+			for _, l := range tt.levels {
+				if l.remove {
+					b.remove(l.price, l.shares)
+				} else {
+					b.add(l.price, l.shares)
+				}
+				b.top()
+			}
+			if price, shares := b.top(); price != tt.wantPrice || shares != tt.wantShares {
+				t.Errorf("book.top() = %d, %d, want %d, %d", price, shares, tt.wantPrice, tt.wantShares)
+			}
+		})
+	}
+}
+
+func sameEvent(got, want instrument.Event) bool {
+	switch want := want.(type) {
+	case *instrument.Tick:
+		tick, ok := got.(*instrument.Tick)
+		return ok && tick.Ticker() == want.Ticker() && *tick.Quote == *want.Quote &&
+			tick.BidSize == want.BidSize && tick.AskSize == want.AskSize
+	case *instrument.Trade:
+		trade, ok := got.(*instrument.Trade)
+		return ok && *trade == *want
+	}
+	return false
+}
@@ -0,0 +1,179 @@
+package porttools
+
+import (
+	"io"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/itch"
+)
+
+// ITCHSource is a TickSource that replays the ITCH 5.0 captures found from
+// a config's file glob, in date order. Quotes are taken from the top of each
+// stock's book, and trades from its executions and trade messages.
+// Ticks of stocks outside the simulation's universe are not built.
+type ITCHSource struct {
+	cfg    *config.Config
+	files  []dataFile
+	filter func(string) bool
+
+	// mu guards the capture being replayed, which may be closed while a read is in flight.
+	mu      sync.Mutex
+	parser  *itch.Parser
+	file    io.Closer
+	raw     io.Closer
+	reading bool
+	closed  bool
+}
+
+// NewITCHSource finds the captures specified by cfg,
+// and returns an ITCHSource that will replay them.
+func NewITCHSource(cfg *config.Config) (*ITCHSource, error) {
+	files, err := dataFiles(cfg)
+	if err != nil {
+		return nil, err
+	}
+	universe, err := newUniverse(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &ITCHSource{cfg: cfg, files: files}
+	if universe != nil {
+		src.filter = universe.contains
+	}
+	return src, nil
+}
+
+// Next returns the next tick parsed from the source's captures.
+func (src *ITCHSource) Next() (*instrument.Tick, error) {
+	for {
+		event, err := src.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		if tick, ok := event.(*instrument.Tick); ok {
+			return tick, nil
+		}
+	}
+}
+
+// NextEvent returns the next tick or trade parsed from the source's captures.
+// NextEvent returns io.EOF once the source has been closed.
+func (src *ITCHSource) NextEvent() (instrument.Event, error) {
+	for {
+		src.mu.Lock()
+		if src.closed {
+			src.mu.Unlock()
+			return nil, io.EOF
+		}
+		if src.parser == nil {
+			if len(src.files) == 0 {
+				src.mu.Unlock()
+				return nil, io.EOF
+			}
+			if err := src.open(src.files[0]); err != nil {
+				src.mu.Unlock()
+				return nil, err
+			}
+			src.files = src.files[1:]
+		}
+		parser := src.parser
+		src.reading = true
+		src.mu.Unlock()
+
+		event, err := parser.Next()
+
+		src.mu.Lock()
+		src.reading = false
+		if src.closed {
+			// the capture was closed during the read, and is released here once the read has returned.
+			src.closeCapture()
+			src.mu.Unlock()
+			return nil, io.EOF
+		}
+		if err != nil {
+			src.closeCapture()
+		}
+		src.mu.Unlock()
+
+		if err == io.EOF {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return event, nil
+	}
+}
+
+// Close closes the capture being replayed. A read in flight on another goroutine is unblocked
+// by closing the file it reads from, or the source's pipe of standard input,
+// and the rest of the capture is released once the read returns.
+func (src *ITCHSource) Close() error {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+
+	src.closed = true
+	if src.reading {
+		return src.raw.Close()
+	}
+	return src.closeCapture()
+}
+
+// closeCapture closes the capture being replayed, if any. src.mu must be held.
+func (src *ITCHSource) closeCapture() error {
+	if src.parser == nil {
+		return nil
+	}
+	src.parser = nil
+	return src.file.Close()
+}
+
+// open starts replaying a capture. src.mu must be held.
+func (src *ITCHSource) open(f dataFile) error {
+	log.Println("loading", f.name)
+
+	var file io.ReadCloser
+	if f.name == stdinGlob {
+		file = newStdinPipe()
+	} else {
+		var err error
+		if file, err = os.Open(f.name); err != nil {
+			return err
+		}
+	}
+	r, err := decompress(f.name, file)
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	src.parser = itch.NewParser(r, f.date, src.filter)
+	src.file = multiCloser{r, file}
+	src.raw = file
+	return nil
+}
+
+// multiCloser closes each of its closers in turn, returning the first error.
+type multiCloser []io.Closer
+
+func (c multiCloser) Close() (err error) {
+	for i := range c {
+		if cerr := c[i].Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// newDataSource returns the source of the data files configured by cfg.
+func newDataSource(cfg *config.Config) (TickSource, error) {
+	if cfg.File.Kind == config.KindITCH {
+		return NewITCHSource(cfg)
+	}
+	return NewFileSource(cfg)
+}
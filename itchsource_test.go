@@ -0,0 +1,165 @@
+package porttools
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/itch"
+)
+
+// mockITCHMessage frames an ITCH message of type typ for locate at ns nanoseconds past midnight,
+// followed by fields written in big-endian order.
+func mockITCHMessage(typ byte, locate uint16, ns uint64, fields ...interface{}) []byte {
+	var msg bytes.Buffer
+	msg.WriteByte(typ)
+	binary.Write(&msg, binary.BigEndian, locate)
+	binary.Write(&msg, binary.BigEndian, uint16(0))
+	for i := 5; i >= 0; i-- {
+		msg.WriteByte(byte(ns >> (8 * uint(i))))
+	}
+	for _, field := range fields {
+		if s, ok := field.(string); ok {
+			msg.WriteString(s + "        "[len(s):])
+			continue
+		}
+		binary.Write(&msg, binary.BigEndian, field)
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.BigEndian, uint16(msg.Len()))
+	buf.Write(msg.Bytes())
+	return buf.Bytes()
+}
+
+func TestITCHSource_NextEvent(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+
+	captures := map[string][][]byte{
+		"itch_20170814": {
+			mockITCHMessage(itch.AddOrder, 1, 100, uint64(1), byte('B'), uint32(100), "AAPL", uint32(1599900)),
+			mockITCHMessage(itch.AddOrder, 1, 200, uint64(2), byte('S'), uint32(200), "AAPL", uint32(1600100)),
+			mockITCHMessage(itch.AddOrder, 2, 250, uint64(3), byte('B'), uint32(100), "GOOGL", uint32(9200000)),
+			mockITCHMessage(itch.AddOrder, 2, 260, uint64(4), byte('S'), uint32(100), "GOOGL", uint32(9201000)),
+			mockITCHMessage(itch.OrderExecuted, 1, 300, uint64(2), uint32(50), uint64(1)),
+		},
+		"itch_20170815": {
+			mockITCHMessage(itch.Trade, 1, 100, uint64(0), byte('B'), uint32(300), "AAPL", uint32(1600000), uint64(1)),
+		},
+	}
+	for name, msgs := range captures {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), bytes.Join(msgs, nil), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		securities []string
+		want       []string
+	}{
+		{"All stocks", nil, []string{
+			"tick AAPL 08-14 $159.99x100/$160.01x200",
+			"tick GOOGL 08-14 $920.00x100/$920.10x100",
+			"trade AAPL 08-14 $160.01x50",
+			"tick AAPL 08-14 $159.99x100/$160.01x150",
+			"trade AAPL 08-15 $160.00x300",
+		}},
+		{"Universe", []string{"AAPL"}, []string{
+			"tick AAPL 08-14 $159.99x100/$160.01x200",
+			"trade AAPL 08-14 $160.01x50",
+			"tick AAPL 08-14 $159.99x100/$160.01x150",
+			"trade AAPL 08-15 $160.00x300",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(config.Config)
+			cfg.File.Kind = config.KindITCH
+			cfg.File.Glob = filepath.Join(dir, "itch_*")
+			cfg.File.ExampleDate = "20060102"
+			cfg.Backtest.Securities = tt.securities
+
+			src, err := newDataSource(cfg)
+			if err != nil {
+				t.Fatalf("newDataSource() error = %v", err)
+			}
+			itchSrc, ok := src.(*ITCHSource)
+			if !ok {
+				t.Fatalf("newDataSource() = %T, want *ITCHSource", src)
+			}
+			defer itchSrc.Close()
+
+			var got []string
+			for {
+				event, err := itchSrc.NextEvent()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("ITCHSource.NextEvent() error = %v", err)
+				}
+				switch e := event.(type) {
+				case *instrument.Tick:
+					got = append(got, fmt.Sprintf("tick %s %s %vx%d/%vx%d", e.Ticker(), e.Timestamp.Format("01-02"),
+						e.Bid, e.BidSize, e.Ask, e.AskSize))
+				case *instrument.Trade:
+					got = append(got, fmt.Sprintf("trade %s %s %vx%d", e.Ticker(), e.Timestamp.Format("01-02"),
+						e.Price, e.Size))
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("ITCHSource.NextEvent() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestSimulation_Run_itchCancel(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	// after a resting offer, each order raises the best bid, so that every message of the capture builds a tick.
+	var capture bytes.Buffer
+	capture.Write(mockITCHMessage(itch.AddOrder, 1, 1, uint64(1), byte('S'), uint32(100), "AAPL", uint32(2000000)))
+	for i := 1; i < 100000; i++ {
+		capture.Write(mockITCHMessage(itch.AddOrder, 1, uint64(i+1), uint64(i+1), byte('B'), uint32(100), "AAPL", uint32(1000000+i)))
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "itch_20170814"), capture.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{}
+	cfg.Simulation.OutputDir = dir
+	cfg.File.Kind = config.KindITCH
+	cfg.File.Glob = filepath.Join(dir, "itch_*")
+	cfg.File.ExampleDate = "20060102"
+
+	// the replay is cancelled from another goroutine, while the capture may be being read.
+	for _, timeout := range []time.Duration{time.Millisecond, 10 * time.Millisecond, 50 * time.Millisecond} {
+		t.Run(fmt.Sprintf("After %v", timeout), func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			sim, err := NewSimulationFromConfig(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+			if err = sim.Run(ctx); err != context.DeadlineExceeded {
+				t.Errorf("Simulation.Run() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package porttools
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+// Consolidator keeps the latest quote of each venue a security is quoted on,
+// and consolidates them into a national best bid and offer (NBBO):
+// the highest bid and lowest ask across venues, with the sizes quoted at each summed.
+type Consolidator struct {
+	mu       sync.RWMutex
+	quotes   map[string]map[string]*instrument.Tick // by ticker, then venue
+	national map[string]nbbo
+}
+
+// nbbo is the national best bid and offer of a security.
+type nbbo struct {
+	bid, bidSize, ask, askSize utils.Amount
+}
+
+// NewConsolidator returns a Consolidator with no quotes.
+func NewConsolidator() *Consolidator {
+	return &Consolidator{
+		quotes:   make(map[string]map[string]*instrument.Tick),
+		national: make(map[string]nbbo),
+	}
+}
+
+// Update replaces the quote of a tick's venue, returning a tick of the resulting NBBO,
+// stamped with the time of t. A nil tick is returned if the NBBO is unchanged,
+// or if no venue quotes one side of the market.
+// Venues are taken off a side of the market when they quote it with a size of zero.
+func (c *Consolidator) Update(t *instrument.Tick) *instrument.Tick {
+	ticker := t.Ticker()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	venues, ok := c.quotes[ticker]
+	if !ok {
+		venues = make(map[string]*instrument.Tick)
+		c.quotes[ticker] = venues
+	}
+	venues[t.Venue] = t
+
+	var best nbbo
+	for _, q := range venues {
+		if q.BidSize > 0 {
+			switch {
+			case best.bidSize == 0 || q.Bid > best.bid:
+				best.bid, best.bidSize = q.Bid, q.BidSize
+			case q.Bid == best.bid:
+				best.bidSize += q.BidSize
+			}
+		}
+		if q.AskSize > 0 {
+			switch {
+			case best.askSize == 0 || q.Ask < best.ask:
+				best.ask, best.askSize = q.Ask, q.AskSize
+			case q.Ask == best.ask:
+				best.askSize += q.AskSize
+			}
+		}
+	}
+	if best == c.national[ticker] {
+		return nil
+	}
+	c.national[ticker] = best
+	if best.bidSize == 0 || best.askSize == 0 {
+		return nil
+	}
+
+	quote := instrument.NewQuote(best.bid, best.ask, t.Timestamp, instrument.Instrument{})
+	tick := instrument.NewTick(best.bidSize, best.askSize, quote)
+	tick.SetTicker(ticker)
+	return tick
+}
+
+// VenueQuotes returns the latest quote of each venue a security is quoted on, ordered by venue.
+func (c *Consolidator) VenueQuotes(ticker string) []instrument.Tick {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	venues := c.quotes[ticker]
+	quotes := make([]instrument.Tick, 0, len(venues))
+	for _, q := range venues {
+		quotes = append(quotes, *q)
+	}
+	sort.Slice(quotes, func(i, j int) bool {
+		return quotes[i].Venue < quotes[j].Venue
+	})
+	return quotes
+}
+
+// Reset drops the quotes of a security, which are rebuilt as each venue quotes it again.
+func (c *Consolidator) Reset(ticker string) {
+	c.mu.Lock()
+	delete(c.quotes, ticker)
+	delete(c.national, ticker)
+	c.mu.Unlock()
+}
+
+// ------------------------------------------------------------------
+
+// nbboSource is an EventSource that consolidates the venue quotes of another source,
+// yielding a tick each time a security's NBBO changes in their place.
+// Events other than ticks are passed through.
+type nbboSource struct {
+	EventSource
+	consolidator *Consolidator
+}
+
+// NextEvent returns the next NBBO tick, bar or trade.
+func (src *nbboSource) NextEvent() (instrument.Event, error) {
+	for {
+		event, err := src.EventSource.NextEvent()
+		if err != nil {
+			return nil, err
+		}
+		tick, ok := event.(*instrument.Tick)
+		if !ok {
+			return event, nil
+		}
+		if national := src.consolidator.Update(tick); national != nil {
+			return national, nil
+		}
+	}
+}
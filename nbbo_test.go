@@ -0,0 +1,151 @@
+package porttools
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func mockVenueTick(ticker, venue string, bid, bidSize, ask, askSize float64, ts time.Time) *instrument.Tick {
+	quote := instrument.NewQuote(utils.FloatAmount(bid), utils.FloatAmount(ask), ts, instrument.Instrument{})
+	tick := instrument.NewTick(utils.Amount(bidSize), utils.Amount(askSize), quote)
+	tick.SetTicker(ticker)
+	tick.Venue = venue
+	return tick
+}
+
+func TestConsolidator_Update(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	type nbboTick struct {
+		bid, bidSize, ask, askSize float64
+	}
+	tests := []struct {
+		name   string
+		quotes []*instrument.Tick
+		want   []*nbboTick // the NBBO after each quote, nil if unchanged
+	}{
+		{"Best of each side",
+			[]*instrument.Tick{
+				mockVenueTick("AAPL", "Q", 159.99, 100, 160.02, 100, open),
+				mockVenueTick("AAPL", "N", 159.98, 200, 160.01, 300, open),
+				mockVenueTick("AAPL", "Z", 160.00, 50, 160.03, 100, open),
+			},
+			[]*nbboTick{{159.99, 100, 160.02, 100}, {159.99, 100, 160.01, 300}, {160.00, 50, 160.01, 300}}},
+		{"Sizes summed at the best price",
+			[]*instrument.Tick{
+				mockVenueTick("AAPL", "Q", 159.99, 100, 160.01, 100, open),
+				mockVenueTick("AAPL", "N", 159.99, 200, 160.01, 300, open),
+			},
+			[]*nbboTick{{159.99, 100, 160.01, 100}, {159.99, 300, 160.01, 400}}},
+		{"Venue quotes replaced",
+			[]*instrument.Tick{
+				mockVenueTick("AAPL", "Q", 160.00, 100, 160.01, 100, open),
+				mockVenueTick("AAPL", "N", 159.99, 200, 160.02, 300, open),
+				mockVenueTick("AAPL", "Q", 159.98, 100, 160.03, 100, open),
+			},
+			[]*nbboTick{{160.00, 100, 160.01, 100}, nil, {159.99, 200, 160.02, 300}}},
+		{"Unchanged NBBO",
+			[]*instrument.Tick{
+				mockVenueTick("AAPL", "Q", 160.00, 100, 160.01, 100, open),
+				mockVenueTick("AAPL", "N", 159.90, 200, 160.10, 300, open),
+			},
+			[]*nbboTick{{160.00, 100, 160.01, 100}, nil}},
+		{"One-sided market",
+			[]*instrument.Tick{
+				mockVenueTick("AAPL", "Q", 160.00, 100, 160.01, 0, open),
+				mockVenueTick("AAPL", "N", 159.99, 200, 160.02, 300, open),
+				mockVenueTick("AAPL", "N", 159.99, 200, 160.02, 0, open),
+			},
+			[]*nbboTick{nil, {160.00, 100, 160.02, 300}, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewConsolidator()
+			for i, q := range tt.quotes {
+				got := c.Update(q)
+				want := tt.want[i]
+				if want == nil {
+					if got != nil {
+						t.Errorf("Consolidator.Update(%d) = %+v, want nil", i, got)
+					}
+					continue
+				}
+				if got == nil {
+					t.Fatalf("Consolidator.Update(%d) = nil, want %+v", i, want)
+				}
+				if got.Ticker() != q.Ticker() || got.Venue != "" || !got.Timestamp.Equal(q.Timestamp) ||
+					got.Bid != utils.FloatAmount(want.bid) || got.BidSize != utils.Amount(want.bidSize) ||
+					got.Ask != utils.FloatAmount(want.ask) || got.AskSize != utils.Amount(want.askSize) {
+					t.Errorf("Consolidator.Update(%d) = %s %d x %d @ %d x %d, want %+v",
+						i, got.Ticker(), got.Bid, got.BidSize, got.Ask, got.AskSize, *want)
+				}
+			}
+		})
+	}
+}
+
+func TestConsolidator_VenueQuotes(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	c := NewConsolidator()
+	for _, q := range []*instrument.Tick{
+		mockVenueTick("AAPL", "Q", 159.99, 100, 160.01, 100, open),
+		mockVenueTick("AAPL", "N", 159.98, 200, 160.02, 300, open),
+		mockVenueTick("GOOGL", "Q", 920.00, 100, 920.10, 100, open),
+		mockVenueTick("AAPL", "Q", 160.00, 100, 160.01, 100, open.Add(time.Second)),
+	} {
+		c.Update(q)
+	}
+
+	got := c.VenueQuotes("AAPL")
+	if len(got) != 2 || got[0].Venue != "N" || got[1].Venue != "Q" {
+		t.Fatalf("Consolidator.VenueQuotes() = %+v, want quotes of N and Q", got)
+	}
+	if got[1].Bid != utils.FloatAmount(160.00) {
+		t.Errorf("Consolidator.VenueQuotes() Q bid = %d, want the latest quote's %d", got[1].Bid, utils.FloatAmount(160.00))
+	}
+
+	c.Reset("AAPL")
+	if got = c.VenueQuotes("AAPL"); len(got) != 0 {
+		t.Errorf("Consolidator.VenueQuotes() after Reset() = %+v, want none", got)
+	}
+}
+
+func Test_nbboSource_NextEvent(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	trade := instrument.NewTrade("AAPL", utils.FloatAmount(160.00), 100, "", open)
+	events := make(chan instrument.Event, 4)
+	events <- mockVenueTick("AAPL", "Q", 159.99, 100, 160.01, 100, open)
+	events <- mockVenueTick("AAPL", "N", 159.90, 100, 160.10, 100, open)
+	events <- trade
+	events <- mockVenueTick("AAPL", "N", 160.00, 100, 160.10, 100, open)
+	close(events)
+
+	src := &nbboSource{EventSource: mockEventSource(events), consolidator: NewConsolidator()}
+
+	var got []instrument.Event
+	for {
+		event, err := src.NextEvent()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("nbboSource.NextEvent() error = %v", err)
+		}
+		got = append(got, event)
+	}
+	if len(got) != 3 {
+		t.Fatalf("nbboSource.NextEvent() = %d events, want 3", len(got))
+	}
+	if got[1] != trade {
+		t.Errorf("nbboSource.NextEvent()[1] = %+v, want the trade", got[1])
+	}
+	if tick, ok := got[2].(*instrument.Tick); !ok || tick.Bid != utils.FloatAmount(160.00) || tick.Ask != utils.FloatAmount(160.01) {
+		t.Errorf("nbboSource.NextEvent()[2] = %+v, want NBBO of 160.00 x 160.01", got[2])
+	}
+}
@@ -3,10 +3,15 @@ package porttools
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/jakeschurch/porttools/collection"
+	"github.com/jakeschurch/porttools/collection/benchmark"
+	"github.com/jakeschurch/porttools/collection/portfolio"
+	"github.com/jakeschurch/porttools/config"
 	"github.com/jakeschurch/porttools/instrument"
 	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/output"
 	"github.com/jakeschurch/porttools/utils"
 )
 
@@ -14,30 +19,108 @@ var (
 	// ErrNegativeVolume indicates that the processed order
 	// has too high of a volume to act upon.
 	ErrNegativeVolume = errors.New("not enough volume to fill order")
+
+	// ErrInvalidBarFill indicates an unknown bar fill rule.
+	ErrInvalidBarFill = errors.New("barFill must be one of nextOpen or close")
 )
 
 // OMS acts as an `Order Management System` to test trading signals and fill orders.
 type OMS struct {
-	mu   sync.RWMutex
-	open *collection.HoldingList
-	cash utils.Amount
+	mu      sync.RWMutex
+	open    *collection.HoldingList
+	pending map[string][]*order.Order
+	events  *Queue
+
+	// closes holds the open order each pending exit order closes,
+	// and exiting the open orders that pending exit orders close.
+	closes  map[*order.Order]*collection.LinkedNode
+	exiting map[*collection.LinkedNode]bool
+	// selling holds the volume of each ticker that pending sell orders, other than exit orders, are to sell.
+	selling map[string]utils.Amount
+	clock   Clock
+	venues  *Consolidator
+
+	// trades holds the trade metrics of every security a trade has been printed for, held or not.
+	trades map[string]*instrument.TradeMetrics
+
+	// port holds the positions of filled orders, which are logged once closed,
+	// and the simulation's cash balance that orders are filled against.
+	port      *portfolio.Portfolio
+	positions *output.PositionLog
+	// index is the benchmark whose levels are recorded on positions as they are opened and closed.
+	index      *benchmark.Index
+	strategy   Strategy
+	ctx        *StrategyContext
+	costMethod utils.CostMethod
+	barFill    string
 }
 
-// NewOMS inits a new OMS type.
-func NewOMS() *OMS {
+// NewOMS inits a new OMS type, filling orders into port
+// and logging closed positions to positions.
+func NewOMS(port *portfolio.Portfolio, positions *output.PositionLog) *OMS {
 	oms := &OMS{
-		open: collection.NewHoldingList(),
-		cash: 0,
+		open:      collection.NewHoldingList(),
+		pending:   make(map[string][]*order.Order),
+		events:    NewQueue(),
+		closes:    make(map[*order.Order]*collection.LinkedNode),
+		exiting:   make(map[*collection.LinkedNode]bool),
+		selling:   make(map[string]utils.Amount),
+		trades:    make(map[string]*instrument.TradeMetrics),
+		clock:     NewSimClock(),
+		port:      port,
+		positions: positions,
 	}
+	oms.ctx = newStrategyContext(oms, port)
 	return oms
 }
 
+// SetClock sets the clock the OMS reads the current time from.
+func (oms *OMS) SetClock(c Clock) {
+	oms.mu.Lock()
+	oms.clock = c
+	oms.mu.Unlock()
+}
+
+// Now returns the current time of the OMS's clock.
+// Algorithms should use Now in place of time.Now.
+func (oms *OMS) Now() time.Time {
+	oms.mu.RLock()
+	c := oms.clock
+	oms.mu.RUnlock()
+	return c.Now()
+}
+
+// SetVenues sets the consolidator of the venue quotes replayed to the OMS.
+func (oms *OMS) SetVenues(c *Consolidator) {
+	oms.mu.Lock()
+	oms.venues = c
+	oms.mu.Unlock()
+}
+
+// VenueQuotes returns the latest quote of each venue a security is quoted on,
+// for algorithms that route orders by venue.
+// Quotes are only kept when the simulation's data files have a venue column.
+func (oms *OMS) VenueQuotes(ticker string) []instrument.Tick {
+	oms.mu.RLock()
+	venues := oms.venues
+	oms.mu.RUnlock()
+	if venues == nil {
+		return nil
+	}
+	return venues.VenueQuotes(ticker)
+}
+
 // Insert checks to see if we can insert a new buy order into the OMS.
 // If it can, order will be inserted into oms, updates cash,
-// and stores new holding in Port.
+// and stores new holding in the OMS's portfolio.
+// Orders without a timestamp are stamped with the current time of the OMS's clock.
 func (oms *OMS) Insert(o *order.Order) error {
 	var dxCash utils.Amount
 
+	if o.Timestamp.IsZero() {
+		o.Timestamp = oms.Now()
+	}
+
 	switch o.Buy {
 	case true:
 		dxCash = -o.Ask * o.Volume(0)
@@ -49,100 +132,459 @@ func (oms *OMS) Insert(o *order.Order) error {
 		return err
 	}
 	oms.updateCash(dxCash)
-	return Port.Insert(
-		instrument.NewHolding(o.Instrument, &utils.DatedMetric{o.Ask, o.Timestamp}),
-		o.Quote)
+	holding := instrument.NewHolding(o.Instrument, &utils.DatedMetric{Amount: o.Ask, Date: o.Timestamp})
+	holding.BenchmarkBuy = oms.benchmarkLevel(o.Ticker(), o.Timestamp)
+	return oms.port.Insert(holding, o.Quote)
 }
 
+// Query fills the pending orders of a tick's ticker against the tick,
+// then checks the tick against the strategy's entry and exit logic,
+// submitting any orders that are returned.
+// Fills are applied before the strategy is called, so its callbacks see the filled orders.
 func (oms *OMS) Query(t instrument.Tick) error {
-	var entryOrder *order.Order
+	if err := oms.fillPending(t.Ticker(), t.Bid, t.Ask, t.Timestamp); err != nil {
+		return err
+	}
 
-	switch entryOrder, _ = strategy.CheckEntryLogic(*t.Quote); entryOrder != nil {
-	case true:
-		oms.Insert(entryOrder)
-	case false: // do nothing if entry logic is not met.
+	// entry orders that sell more shares than are held are dropped, as are invalid entries.
+	if entryOrder, _ := oms.strategy.CheckEntryLogic(oms.ctx, *t.Quote); entryOrder != nil {
+		oms.Submit(entryOrder)
+	}
+	exits, err := oms.queryOpenOrders(t)
+	if err != nil {
+		return err
+	}
+	for _, exit := range exits {
+		oms.submit(exit.order, exit.closes)
+	}
+	return nil
+}
+
+// QueryBar checks a completed bar against the strategy's bar logic,
+// submitting any entry order that is returned.
+func (oms *OMS) QueryBar(b instrument.Bar) error {
+	entryOrder, _ := oms.strategy.CheckBarLogic(oms.ctx, b)
+	if entryOrder == nil {
+		return nil
+	}
+	oms.Submit(entryOrder)
+	return nil
+}
+
+// AddTrade updates the trade metrics of a trade's ticker with a trade print.
+func (oms *OMS) AddTrade(t instrument.Trade) {
+	metrics, ok := oms.trades[t.Ticker()]
+	if !ok {
+		metrics = new(instrument.TradeMetrics)
+		oms.trades[t.Ticker()] = metrics
+	}
+	metrics.AddTrade(t)
+}
+
+// TradeMetrics returns a copy of the trade metrics of a ticker,
+// and whether any trades have been printed for it.
+func (oms *OMS) TradeMetrics(ticker string) (instrument.TradeMetrics, bool) {
+	metrics, ok := oms.trades[ticker]
+	if !ok {
+		return instrument.TradeMetrics{}, false
 	}
-	return oms.queryOpenOrders(t)
+	return *metrics, true
 }
 
-func (oms *OMS) queryOpenOrders(t instrument.Tick) error {
+// QueryTrade checks a trade print against the strategy's trade logic,
+// submitting any entry order that is returned.
+func (oms *OMS) QueryTrade(t instrument.Trade) error {
+	entryOrder, _ := oms.strategy.CheckTradeLogic(oms.ctx, t)
+	if entryOrder == nil {
+		return nil
+	}
+	oms.Submit(entryOrder)
+	return nil
+}
+
+// QueryPriceBar checks a bar of traded prices against the strategy's logic,
+// filling market orders at the bar's prices according to the simulation's bar fill rule.
+//
+// Orders are either filled at the bar's close, or submitted at the bar's close and
+// filled at the open of the ticker's next bar, at which open orders are checked for exits.
+// Orders submitted otherwise, such as through the StrategyContext, fill at the close
+// or open of the ticker's next bar respectively.
+func (oms *OMS) QueryPriceBar(b instrument.Bar) error {
+	closeQuote := b.Quote(b.Price.Close, b.End)
+
+	switch oms.barFill {
+	case config.BarFillClose:
+		// orders submitted since the ticker's last bar fill at this bar's close.
+		if err := oms.fillPending(b.Ticker(), b.Price.Close, b.Price.Close, b.End); err != nil {
+			return err
+		}
+		if entryOrder := oms.barEntry(b, *closeQuote); entryOrder != nil && oms.reserve(entryOrder) == nil {
+			fillAt(entryOrder, b.Price.Close, b.End)
+			if err := oms.fill(orderSubmitted{order: entryOrder}); err != nil {
+				return err
+			}
+		}
+		exits, err := oms.queryOpenOrders(*instrument.NewTick(b.Volume, b.Volume, closeQuote))
+		if err != nil {
+			return err
+		}
+		for _, exit := range exits {
+			fillAt(exit.order, b.Price.Close, b.End)
+			if err = oms.fill(exit); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	default:
+		ticker := b.Ticker()
+		if err := oms.fillPending(ticker, b.Price.Open, b.Price.Open, b.Start); err != nil {
+			return err
+		}
+
+		openQuote := b.Quote(b.Price.Open, b.Start)
+		exits, err := oms.queryOpenOrders(*instrument.NewTick(b.Volume, b.Volume, openQuote))
+		if err != nil {
+			return err
+		}
+		for _, exit := range exits {
+			oms.submit(exit.order, exit.closes)
+		}
+
+		if entryOrder := oms.barEntry(b, *closeQuote); entryOrder != nil {
+			oms.Submit(entryOrder)
+		}
+		return nil
+	}
+}
+
+// barEntry checks a bar against the strategy's bar logic,
+// or its entry logic if the strategy's algorithm does not implement BarAlgorithm.
+func (oms *OMS) barEntry(b instrument.Bar, closeQuote instrument.Quote) *order.Order {
+	if _, ok := oms.strategy.Algorithm.(BarAlgorithm); ok {
+		entryOrder, _ := oms.strategy.CheckBarLogic(oms.ctx, b)
+		return entryOrder
+	}
+	entryOrder, _ := oms.strategy.CheckEntryLogic(oms.ctx, closeQuote)
+	return entryOrder
+}
+
+// fillAt sets the price and time an order is filled at.
+func fillAt(o *order.Order, price utils.Amount, ts time.Time) {
+	o.Bid, o.Ask = price, price
+	o.Timestamp = ts
+}
+
+// queryOpenOrders checks the open orders of a tick's ticker against the strategy's exit logic,
+// returning the exit orders of those to be closed.
+// Open orders already being closed are not checked again,
+// and those the exit logic returns no order for are left open.
+func (oms *OMS) queryOpenOrders(t instrument.Tick) ([]orderSubmitted, error) {
 	var orderList *collection.LinkedList
 	var openOrderNode *collection.LinkedNode
 	var exitOrder *order.Order
+	var exits []orderSubmitted
 	var err error
 
+	// sell orders close open orders once filled, so open orders are not checked for exits
+	// while sells of their ticker are pending.
+	if oms.selling[t.Ticker()] > 0 {
+		return nil, nil
+	}
 	if orderList, err = oms.open.Get(t.Ticker()); err != nil {
-		return err
+		if err == collection.ErrNoListExists {
+			return nil, nil
+		}
+		return nil, err
 	}
 
 	for openOrderNode = orderList.PeekFront(); openOrderNode != nil; openOrderNode = openOrderNode.Next() {
+		if oms.exiting[openOrderNode] {
+			continue
+		}
 
-		// TEMP: for now, do nothing with exitOrder
-		exitOrder, err = strategy.CheckExitLogic(openOrderNode.GetUnderlying().(order.Order), t)
+		exitOrder, err = oms.strategy.CheckExitLogic(oms.ctx, openOrderNode.GetUnderlying().(order.Order), t)
 
 		switch err != nil {
 		case false:
-			if err = oms.open.RemoveNode(openOrderNode); err != nil {
-				return err
+			if exitOrder != nil {
+				exits = append(exits, orderSubmitted{order: exitOrder, closes: openOrderNode})
 			}
-			oms.updateCash(exitOrder.Volume(0) * exitOrder.Bid)
 
 		case true: // do nothing if invalid exit logic
 		}
 	}
+	return exits, nil
+}
+
+// Submit submits an order to the OMS, to be filled against the next quote of its ticker.
+// Orders without a timestamp are stamped with the current time of the OMS's clock.
+//
+// Sell orders sell shares held in the portfolio, closing open orders in the order
+// given by the OMS's cost method. They are rejected with ErrNegativeVolume
+// if they sell more shares than are held and not already being sold.
+func (oms *OMS) Submit(o *order.Order) error {
+	if err := oms.reserve(o); err != nil {
+		return err
+	}
+	oms.submit(o, nil)
 	return nil
 }
 
-func (oms *OMS) updateCash(dxCash utils.Amount) {
-	oms.mu.Lock()
-	oms.cash += dxCash
-	oms.mu.Unlock()
+// reserve sets aside the shares a sell order sells, so they are not sold again by later orders,
+// returning ErrNegativeVolume if the portfolio does not hold enough shares not already being sold.
+func (oms *OMS) reserve(o *order.Order) error {
+	if o.Buy {
+		return nil
+	}
+	ticker := o.Ticker()
 
-	return
+	var held utils.Amount
+	if list, err := oms.port.GetList(ticker); err == nil {
+		held = list.Volume(0)
+	}
+	if list, err := oms.open.Get(ticker); err == nil {
+		for node := list.PeekFront(); node != nil; node = node.Next() {
+			if oms.exiting[node] {
+				held -= node.Volume(0)
+			}
+		}
+	}
+	if o.Volume(0) <= 0 || o.Volume(0) > held-oms.selling[ticker] {
+		return ErrNegativeVolume
+	}
+	oms.selling[ticker] += o.Volume(0)
+	return nil
 }
 
-func (oms *OMS) executeSell(o order.Order) error {
-	var closed = make([]*instrument.Security, 0)
-	var list *collection.LinkedList
-	var toSell *collection.LinkedNode
-	var sellVolume utils.Amount
-	var err error
+// release returns the shares set aside for a sell order that is filled or cancelled.
+func (oms *OMS) release(o *order.Order) {
+	ticker := o.Ticker()
+	if oms.selling[ticker] -= o.Volume(0); oms.selling[ticker] <= 0 {
+		delete(oms.selling, ticker)
+	}
+}
+
+func (oms *OMS) submit(o *order.Order, closes *collection.LinkedNode) {
+	if o.Timestamp.IsZero() {
+		o.Timestamp = oms.Now()
+	}
+	if closes != nil {
+		oms.exiting[closes] = true
+	}
+	oms.events.Enqueue(NewNode(&orderSubmitted{order: o, closes: closes}, o.Timestamp))
+}
+
+// Cancel cancels an order that has been submitted to the OMS, if it has not yet been filled.
+func (oms *OMS) Cancel(o *order.Order) {
+	oms.events.Enqueue(NewNode(&orderCancelled{order: o}, oms.Now()))
+}
+
+// fillPending fills the pending orders of a ticker, buying at ask and selling at bid,
+// in the order they were submitted.
+func (oms *OMS) fillPending(ticker string, bid, ask utils.Amount, ts time.Time) error {
+	pending := oms.pending[ticker]
+	delete(oms.pending, ticker)
 
-	var orderVolume = o.Volume(0)
-	var ticker = o.Ticker()
+	for _, o := range pending {
+		price := bid
+		if o.Buy {
+			price = ask
+		}
+		fillAt(o, price, ts)
+		closes := oms.closes[o]
+		delete(oms.closes, o)
+		if err := oms.fill(orderSubmitted{order: o, closes: closes}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handle handles an order event taken from the simulation's event queue.
+func (oms *OMS) handle(event interface{}) error {
+	switch event := event.(type) {
+	case *orderSubmitted:
+		ticker := event.order.Ticker()
+		oms.pending[ticker] = append(oms.pending[ticker], event.order)
+		if event.closes != nil {
+			oms.closes[event.order] = event.closes
+		}
+
+	case *orderCancelled:
+		ticker := event.order.Ticker()
+		pending := oms.pending[ticker]
+		for i := range pending {
+			if pending[i] == event.order {
+				oms.pending[ticker] = append(pending[:i:i], pending[i+1:]...)
+				if closes, ok := oms.closes[event.order]; ok {
+					delete(oms.exiting, closes)
+					delete(oms.closes, event.order)
+				} else if !event.order.Buy {
+					oms.release(event.order)
+				}
+				break
+			}
+		}
+	}
+	return nil
+}
 
-	if list, err = Port.GetList(ticker); err != nil {
+// fill fills an order at its price, opening a position,
+// or closing the open order an exit order was submitted for
+// by selling its volume out of the portfolio.
+// Other sell orders sell their volume out of the portfolio, closing open orders of as much volume.
+func (oms *OMS) fill(o orderSubmitted) error {
+	if o.closes == nil && o.order.Buy {
+		return oms.Insert(o.order)
+	}
+	if o.closes == nil {
+		oms.release(o.order)
+		ticker, volume := o.order.Ticker(), o.order.Volume(0)
+		if err := oms.executeSell(ticker, volume, &utils.DatedMetric{Amount: o.order.Bid, Date: o.order.Timestamp}); err != nil {
+			return err
+		}
+		return oms.closeOpen(ticker, volume)
+	}
+	delete(oms.exiting, o.closes)
+	volume := o.closes.Volume(0)
+	if err := oms.open.RemoveNode(o.closes); err != nil {
 		return err
 	}
+	return oms.executeSell(o.order.Ticker(), volume, &utils.DatedMetric{Amount: o.order.Bid, Date: o.order.Timestamp})
+}
 
-	if list.Volume(0) < o.Volume(0) {
+// closeOpen closes volume shares of a ticker's open orders not being exited,
+// in the order given by the OMS's cost method, once they have been sold out of the portfolio.
+func (oms *OMS) closeOpen(ticker string, volume utils.Amount) error {
+	list, err := oms.open.Get(ticker)
+	if err == collection.ErrNoListExists {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var nodes []*collection.LinkedNode
+	for node := list.PeekFront(); node != nil; node = node.Next() {
+		if !oms.exiting[node] {
+			nodes = append(nodes, node)
+		}
+	}
+	if oms.costMethod == utils.Lifo {
+		for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+			nodes[i], nodes[j] = nodes[j], nodes[i]
+		}
+	}
+
+	for _, node := range nodes {
+		if volume <= 0 {
+			break
+		}
+		o, ok := node.Financial.(*order.Order)
+		if !ok {
+			continue
+		}
+		if o.Volume(0) <= volume {
+			volume -= o.Volume(0)
+			if err = oms.open.RemoveNode(node); err != nil {
+				return err
+			}
+			continue
+		}
+		nticks := o.Nticks
+		o.Instrument = *instrument.NewInstrument(ticker, o.Volume(0)-volume)
+		o.Nticks = nticks
+		list.Volume(-volume)
+		volume = 0
+	}
+	return nil
+}
+
+func (oms *OMS) updateCash(dxCash utils.Amount) {
+	oms.port.UpdateCash(dxCash)
+}
+
+// executeSell sells volume shares of a ticker out of the portfolio at sell,
+// taking them from its holdings in the order given by the OMS's cost method.
+// The sale is credited to cash, and the shares sold from each holding are logged as closed positions.
+func (oms *OMS) executeSell(ticker string, volume utils.Amount, sell *utils.DatedMetric) error {
+	var closed []*instrument.Security
+	var list *collection.LinkedList
+	var err error
+
+	if list, err = oms.port.GetList(ticker); err != nil {
+		return err
+	}
+	if list.Volume(0) < volume {
 		return ErrNegativeVolume
 	}
 
-	// loop over slice until order has been completely crumpled
-	for orderVolume > 0 {
-		toSell = Port.Peek(ticker, costMethod)
-		holdingVolume := toSell.Volume(0)
+	benchmarkSell := oms.benchmarkLevel(ticker, sell.Date)
 
-		switch holdingVolume >= orderVolume {
-		case true:
+	// take shares out of holdings until the order has been completely filled.
+	for orderVolume := volume; orderVolume > 0; {
+		toSell := list.Peek(oms.costMethod)
+		holding, ok := toSell.Financial.(*instrument.Holding)
+		if !ok {
+			return ErrNegativeVolume
+		}
+		sellVolume := holding.Volume(0)
+		if sellVolume > orderVolume {
 			sellVolume = orderVolume
-		case false:
-			sellVolume = holdingVolume
 		}
-		closed = append(closed, list.PeekToSecurity(sellVolume, costMethod))
+		security := closedSecurity(list, holding, sellVolume, sell)
+		security.BenchmarkBuy, security.BenchmarkSell = holding.BenchmarkBuy, benchmarkSell
+		closed = append(closed, security)
 
-		if toSell.Volume(0) == 0 {
-			list.Pop(costMethod)
+		if sellVolume == holding.Volume(0) {
+			if err = oms.port.Holdings().RemoveNode(toSell); err != nil {
+				return err
+			}
+		} else {
+			nticks := holding.Nticks
+			holding.Instrument = *instrument.NewInstrument(ticker, holding.Volume(0)-sellVolume)
+			holding.Nticks = nticks
+			list.Volume(-sellVolume)
 		}
 		orderVolume -= sellVolume
 	}
-	return positionLog.Insert(closed...)
+	if list.PeekFront() == nil {
+		if err = oms.port.Delete(ticker); err != nil {
+			return err
+		}
+	}
+
+	oms.updateCash(sell.Amount * volume)
+	return oms.positions.Insert(closed...)
 }
 
+// closedSecurity returns a closed position of volume shares of a holding sold at sell,
+// with the metrics of the list the holding was held in.
+func closedSecurity(list *collection.LinkedList, holding *instrument.Holding, volume utils.Amount, sell *utils.DatedMetric) *instrument.Security {
+	asset := *list.Asset
+	quote := *asset.Quote
+	quote.Instrument = *instrument.NewInstrument(holding.Ticker(), volume)
+	quote.Nticks = list.Nticks
+	asset.Quote = &quote
+
+	return instrument.NewSecurity(holding.BuyPrice, sell, asset)
+}
+
+// benchmarkLevel returns the level of a ticker in the OMS's benchmark index, dated at t,
+// or nil if the index has no level of the ticker.
+func (oms *OMS) benchmarkLevel(ticker string, t time.Time) *utils.DatedMetric {
+	if oms.index == nil {
+		return nil
+	}
+	level, ok := oms.index.Level(ticker)
+	if !ok {
+		return nil
+	}
+	return &utils.DatedMetric{Amount: level, Date: t}
+}
+
+// Cash returns the cash balance of the OMS's portfolio.
 func (oms *OMS) Cash() utils.Amount {
-	oms.mu.RLock()
-	cash := oms.cash
-	oms.mu.RUnlock()
-	return cash
+	return oms.port.Cash()
 }
@@ -0,0 +1,189 @@
+package porttools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+// mockPriceBars returns n minute bars of a ticker, the i-th of which opens at 10+i and closes at 10.5+i.
+func mockPriceBars(ticker string, n int) []*instrument.Bar {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	bars := make([]*instrument.Bar, n)
+	for i := range bars {
+		start := open.Add(time.Duration(i) * time.Minute)
+		price := instrument.OHLC{
+			Open:  utils.FloatAmount(10 + float64(i)),
+			High:  utils.FloatAmount(11 + float64(i)),
+			Low:   utils.FloatAmount(9 + float64(i)),
+			Close: utils.FloatAmount(10.5 + float64(i)),
+		}
+		bars[i] = instrument.NewOHLCVBar(ticker, start, start.Add(time.Minute), price, 100)
+	}
+	return bars
+}
+
+// replayPriceBars replays bars through a simulation filling orders by the barFill rule.
+func replayPriceBars(t *testing.T, barFill string, algo Algorithm, bars []*instrument.Bar) *Simulation {
+	var cfg config.Config
+	cfg.Backtest.StartCashAmt = 100
+	cfg.Simulation.BarFill = barFill
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.SetStrategy(NewStrategy(algo))
+
+	events := make(chan instrument.Event, len(bars))
+	for i := range bars {
+		events <- bars[i]
+	}
+	close(events)
+
+	if err := sim.replay(context.Background(), mockEventSource(events), NewSimClock()); err != nil {
+		t.Fatalf("Simulation.replay() error = %v", err)
+	}
+	return sim
+}
+
+// mockSubmitAlgorithm submits a buy of a share through its StrategyContext on the first quote it is passed.
+type mockSubmitAlgorithm struct {
+	submitted *order.Order
+}
+
+func (a *mockSubmitAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	if a.submitted == nil {
+		q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+		a.submitted = order.New(true, q)
+		if err := ctx.Submit(a.submitted); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+func (a *mockSubmitAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+func TestOMS_QueryPriceBar_submitted(t *testing.T) {
+	bars := mockPriceBars("AAPL", 3)
+
+	// orders submitted through the context on the first bar fill on the second.
+	tests := []struct {
+		name      string
+		barFill   string
+		wantPrice utils.Amount
+		wantTime  time.Time
+	}{
+		{"Next open", config.BarFillNextOpen, bars[1].Price.Open, bars[1].Start},
+		{"Close", config.BarFillClose, bars[1].Price.Close, bars[1].End},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algo := &mockSubmitAlgorithm{}
+			sim := replayPriceBars(t, tt.barFill, algo, bars)
+
+			if n := len(sim.oms.ctx.Position("AAPL")); n != 1 {
+				t.Fatalf("StrategyContext.Position() holds %d orders, want 1", n)
+			}
+			if algo.submitted.Ask != tt.wantPrice || !algo.submitted.Timestamp.Equal(tt.wantTime) {
+				t.Errorf("Order filled at %d at %v, want %d at %v",
+					algo.submitted.Ask, algo.submitted.Timestamp, tt.wantPrice, tt.wantTime)
+			}
+			if want := utils.FloatAmount(100) - tt.wantPrice; sim.oms.Cash() != want {
+				t.Errorf("OMS.Cash() = %d, want %d", sim.oms.Cash(), want)
+			}
+		})
+	}
+}
+
+// mockBarRoundTripAlgorithm buys a share on the first quote it is passed,
+// exiting the order at the first tick checked after it was filled.
+type mockBarRoundTripAlgorithm struct {
+	entry, exit *order.Order
+}
+
+func (a *mockBarRoundTripAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	if a.entry != nil {
+		return nil, nil
+	}
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+	a.entry = order.New(true, q)
+	return a.entry, nil
+}
+
+func (a *mockBarRoundTripAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	if !t.Timestamp.After(o.Timestamp) {
+		return nil, ErrOrderNotValid
+	}
+	q := *t.Quote
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+	a.exit = order.New(false, q)
+	return a.exit, nil
+}
+
+func TestOMS_QueryPriceBar(t *testing.T) {
+	bars := mockPriceBars("AAPL", 4)
+
+	tests := []struct {
+		name           string
+		barFill        string
+		wantEntryPrice utils.Amount
+		wantEntryTime  time.Time
+		wantExitPrice  utils.Amount
+		wantExitTime   time.Time
+		// the benchmark's levels of the ticker recorded on the closed position are those of the closes
+		// of the bars the entry and exit filled on.
+		wantBenchmarkBuy, wantBenchmarkSell utils.Amount
+	}{
+		// the entry is placed at the first bar's close and filled at the second bar's open.
+		// the exit is placed at the third bar's open, and filled at the fourth bar's open.
+		{"Next open", config.BarFillNextOpen, bars[1].Price.Open, bars[1].Start, bars[3].Price.Open, bars[3].Start,
+			bars[1].Price.Close, bars[3].Price.Close},
+		// the entry is filled at the first bar's close, and the exit at the second bar's close.
+		{"Close", config.BarFillClose, bars[0].Price.Close, bars[0].End, bars[1].Price.Close, bars[1].End,
+			bars[0].Price.Close, bars[1].Price.Close},
+		{"Default", "", bars[1].Price.Open, bars[1].Start, bars[3].Price.Open, bars[3].Start,
+			bars[1].Price.Close, bars[3].Price.Close},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algo := &mockBarRoundTripAlgorithm{}
+			sim := replayPriceBars(t, tt.barFill, algo, bars)
+
+			if algo.entry == nil || algo.exit == nil {
+				t.Fatalf("Algorithm placed entry %v and exit %v, want both", algo.entry, algo.exit)
+			}
+			if algo.entry.Ask != tt.wantEntryPrice || !algo.entry.Timestamp.Equal(tt.wantEntryTime) {
+				t.Errorf("Entry filled at %d at %v, want %d at %v",
+					algo.entry.Ask, algo.entry.Timestamp, tt.wantEntryPrice, tt.wantEntryTime)
+			}
+			if algo.exit.Bid != tt.wantExitPrice || !algo.exit.Timestamp.Equal(tt.wantExitTime) {
+				t.Errorf("Exit filled at %d at %v, want %d at %v",
+					algo.exit.Bid, algo.exit.Timestamp, tt.wantExitPrice, tt.wantExitTime)
+			}
+			if want := utils.FloatAmount(100) - tt.wantEntryPrice + tt.wantExitPrice; sim.oms.Cash() != want {
+				t.Errorf("OMS.Cash() = %d, want %d", sim.oms.Cash(), want)
+			}
+			if n := len(sim.oms.ctx.Position("AAPL")); n != 0 {
+				t.Errorf("StrategyContext.Position() holds %d orders, want 0", n)
+			}
+
+			closed, ok := sim.positions.ClosedPositions.GetByIndex(0).PeekFront().Financial.(*instrument.Security)
+			if !ok {
+				t.Fatalf("Closed position %+v is not a security", sim.positions.ClosedPositions.GetByIndex(0).PeekFront().Financial)
+			}
+			if closed.BenchmarkBuy == nil || closed.BenchmarkSell == nil ||
+				closed.BenchmarkBuy.Amount != tt.wantBenchmarkBuy || closed.BenchmarkSell.Amount != tt.wantBenchmarkSell {
+				t.Errorf("Closed position benchmark levels = %v, %v, want %d, %d",
+					closed.BenchmarkBuy, closed.BenchmarkSell, tt.wantBenchmarkBuy, tt.wantBenchmarkSell)
+			}
+		})
+	}
+}
@@ -30,7 +30,7 @@ func (o Order) GetUnderlying() instrument.Financial {
 }
 
 func (o Order) Ticker() string {
-	return o.Ticker()
+	return o.Quote.Ticker()
 }
 
 // Status variables refer to a status of an order's execution.
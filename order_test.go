@@ -1,21 +1,193 @@
 package porttools
 
 import (
+	"fmt"
 	"testing"
 	"time"
-)
 
-var (
-	oms = NewOMS()
+	"github.com/jakeschurch/porttools/collection/portfolio"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/output"
+	"github.com/jakeschurch/porttools/utils"
 )
 
-func TestOMS_closeOutOrder(t *testing.T) {
-	order := NewMarketOrder(true, "AAPL", FloatAmount(50.00), FloatAmount(51.00), 10, time.Time{})
-	oms.openOrders = append(oms.openOrders, order)
+// mockOrder returns a market order for volume shares of ticker, quoted at bid and ask.
+func mockOrder(buy bool, ticker string, bid, ask float64, volume utils.Amount) *order.Order {
+	q := instrument.NewQuote(utils.FloatAmount(bid), utils.FloatAmount(ask),
+		time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC), *instrument.NewInstrument(ticker, volume))
+	return order.New(buy, *q)
+}
+
+func TestOMS_Insert(t *testing.T) {
+	tests := []struct {
+		name     string
+		orders   []*order.Order
+		wantCash utils.Amount
+		wantOpen int
+	}{
+		{"Single order", []*order.Order{mockOrder(true, "AAPL", 50.00, 51.00, 10)}, -utils.FloatAmount(51.00) * 10, 1},
+		{"Two orders", []*order.Order{
+			mockOrder(true, "AAPL", 50.00, 51.00, 10),
+			mockOrder(true, "AAPL", 52.00, 53.00, 5),
+		}, -utils.FloatAmount(51.00)*10 - utils.FloatAmount(53.00)*5, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := portfolio.New()
+			oms := NewOMS(port, output.NewPositionLog())
+			for _, o := range tt.orders {
+				if err := oms.Insert(o); err != nil {
+					t.Fatalf("OMS.Insert() error = %v", err)
+				}
+			}
+
+			if got := oms.Cash(); got != tt.wantCash {
+				t.Errorf("OMS.Cash() = %d, want %d", got, tt.wantCash)
+			}
+			if got := len(oms.ctx.OpenOrders("AAPL")); got != tt.wantOpen {
+				t.Errorf("OMS holds %d open orders, want %d", got, tt.wantOpen)
+			}
+			if got := len(oms.ctx.Position("AAPL")); got != tt.wantOpen {
+				t.Errorf("Portfolio holds %d holdings, want %d", got, tt.wantOpen)
+			}
+		})
+	}
+}
+
+func TestOMS_fill_exit(t *testing.T) {
+	tests := []struct {
+		name       string
+		costMethod utils.CostMethod
+		exits      int
+		wantCash   utils.Amount
+		wantHeld   []utils.Amount
+		wantClosed []string
+	}{
+		{"First in, first out", utils.Fifo, 1,
+			-utils.FloatAmount(51.00)*10 - utils.FloatAmount(53.00)*5 + utils.FloatAmount(60.00)*10,
+			[]utils.Amount{5}, []string{"10 bought at $51.00"}},
+		{"Last in, first out", utils.Lifo, 1,
+			-utils.FloatAmount(51.00)*10 - utils.FloatAmount(53.00)*5 + utils.FloatAmount(60.00)*10,
+			[]utils.Amount{5}, []string{"5 bought at $53.00", "5 bought at $51.00"}},
+		{"Whole position", utils.Fifo, 2,
+			-utils.FloatAmount(51.00)*10 - utils.FloatAmount(53.00)*5 + utils.FloatAmount(60.00)*15,
+			nil, []string{"10 bought at $51.00", "5 bought at $53.00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := portfolio.New()
+			positions := output.NewPositionLog()
+			oms := NewOMS(port, positions)
+			oms.costMethod = tt.costMethod
+			for _, o := range []*order.Order{
+				mockOrder(true, "AAPL", 50.00, 51.00, 10),
+				mockOrder(true, "AAPL", 52.00, 53.00, 5),
+			} {
+				if err := oms.Insert(o); err != nil {
+					t.Fatalf("OMS.Insert() error = %v", err)
+				}
+			}
+
+			for i := 0; i < tt.exits; i++ {
+				open, err := oms.open.Get("AAPL")
+				if err != nil {
+					t.Fatalf("OMS.open.Get() error = %v", err)
+				}
+				exit := mockOrder(false, "AAPL", 60.00, 61.00, 0)
+				if err = oms.fill(orderSubmitted{order: exit, closes: open.PeekFront()}); err != nil {
+					t.Fatalf("OMS.fill() error = %v", err)
+				}
+			}
+
+			if got := oms.Cash(); got != tt.wantCash {
+				t.Errorf("OMS.Cash() = %d, want %d", got, tt.wantCash)
+			}
+			var held []utils.Amount
+			for _, h := range oms.ctx.Position("AAPL") {
+				held = append(held, h.Volume(0))
+			}
+			if fmt.Sprint(held) != fmt.Sprint(tt.wantHeld) {
+				t.Errorf("Portfolio holdings = %v, want %v", held, tt.wantHeld)
+			}
+
+			closed, err := positions.ClosedPositions.Get("AAPL")
+			if err != nil {
+				t.Fatalf("PositionLog.ClosedPositions.Get() error = %v", err)
+			}
+			var got []string
+			for node := closed.PeekFront(); node != nil; node = node.Next() {
+				s := node.Financial.(*instrument.Security)
+				if s.SellPrice.Amount != utils.FloatAmount(60.00) {
+					t.Errorf("Closed position sold at %v, want %v", s.SellPrice.Amount, utils.FloatAmount(60.00))
+				}
+				got = append(got, fmt.Sprintf("%d bought at %v", s.Volume(0), s.BuyPrice.Amount))
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantClosed) {
+				t.Errorf("Closed positions = %v, want %v", got, tt.wantClosed)
+			}
+
+			// positions can be opened again once closed.
+			if err = oms.Insert(mockOrder(true, "AAPL", 50.00, 51.00, 10)); err != nil {
+				t.Fatalf("OMS.Insert() error = %v", err)
+			}
+			if got := len(oms.ctx.Position("AAPL")); got != len(tt.wantHeld)+1 {
+				t.Errorf("Portfolio holds %d holdings, want %d", got, len(tt.wantHeld)+1)
+			}
+		})
+	}
+}
+
+// mockExitAlgorithm never enters, returning exit and err from each exit check.
+type mockExitAlgorithm struct {
+	exit *order.Order
+	err  error
+}
+
+func (a *mockExitAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	return nil, nil
+}
+
+func (a *mockExitAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return a.exit, a.err
+}
+
+func TestOMS_Query_exit(t *testing.T) {
+	tests := []struct {
+		name        string
+		exit        *order.Order
+		err         error
+		wantExiting int
+	}{
+		{"Exit", mockOrder(false, "AAPL", 60.00, 61.00, 10), nil, 1},
+		{"Not exited yet", nil, nil, 0},
+		{"Invalid exit", nil, ErrOrderNotValid, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oms := NewOMS(portfolio.New(), output.NewPositionLog())
+			oms.strategy = NewStrategy(&mockExitAlgorithm{exit: tt.exit, err: tt.err})
+			if err := oms.Insert(mockOrder(true, "AAPL", 50.00, 51.00, 10)); err != nil {
+				t.Fatalf("OMS.Insert() error = %v", err)
+			}
+
+			tick := instrument.NewTick(10, 10, instrument.NewQuote(utils.FloatAmount(60.00), utils.FloatAmount(61.00),
+				time.Date(2017, 8, 14, 9, 31, 0, 0, time.UTC), *instrument.NewInstrument("AAPL", 0)))
+			if err := oms.Query(*tick); err != nil {
+				t.Fatalf("OMS.Query() error = %v", err)
+			}
+			if len(oms.exiting) != tt.wantExiting {
+				t.Errorf("OMS is exiting %d open orders, want %d", len(oms.exiting), tt.wantExiting)
+			}
+			if got := len(oms.ctx.OpenOrders("AAPL")); got != 1 {
+				t.Errorf("OMS holds %d open orders, want 1", got)
+			}
 
-	oms.closeOutOrder(order)
-	if len(oms.openOrders) != 0 {
-		t.Errorf("Expected 0 open orders, got %d open orders", len(oms.openOrders))
-		t.Fail()
+			// ticks of tickers without open orders are not checked for exits.
+			tick.SetTicker("GOOGL")
+			if err := oms.Query(*tick); err != nil {
+				t.Errorf("OMS.Query() without open orders error = %v", err)
+			}
+		})
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"encoding/csv"
 	"log"
 	"os"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/jakeschurch/porttools/collection"
@@ -35,6 +37,25 @@ var headers = []string{
 // PositionLog allows for performance analysis.
 type PositionLog struct {
 	ClosedPositions *collection.HoldingList
+
+	mu          sync.Mutex
+	Adjustments []Adjustment
+}
+
+// Adjustment records the application of a corporate action to a ticker's open positions.
+type Adjustment struct {
+	Ticker string
+	Date   time.Time
+	Type   string
+
+	// Volume is the volume of the positions held when the action was applied.
+	Volume utils.Amount
+	// Ratio is the number of new shares given for each old share by a split.
+	Ratio float64
+	// Cash is the amount of cash paid by a dividend.
+	Cash utils.Amount
+	// NewTicker is the symbol positions were renamed to by a symbol change.
+	NewTicker string
 }
 
 func NewPositionLog() *PositionLog {
@@ -56,6 +77,13 @@ func (p *PositionLog) Insert(securities ...*instrument.Security) error {
 	return nil
 }
 
+// Adjust records the application of a corporate action to open positions.
+func (p *PositionLog) Adjust(a Adjustment) {
+	p.mu.Lock()
+	p.Adjustments = append(p.Adjustments, a)
+	p.mu.Unlock()
+}
+
 // ------------------------------------------------------------------
 
 type result struct {
@@ -78,8 +106,8 @@ func toSlice(result *result) []string {
 
 	return []string{
 		result.Ticker(),
-		string(result.Volume(0)),
-		string(result.Nticks),
+		result.Volume(0).ToVolume(),
+		strconv.FormatUint(uint64(result.Nticks), 10),
 
 		result.BuyPrice.Date.Format(fmtString),
 		result.BuyPrice.Amount.String(),
@@ -99,53 +127,58 @@ func toSlice(result *result) []string {
 	}
 }
 
-// GetResults ...TODO
-func GetResults(outputFormat Format, closed *collection.HoldingList, benchmark *collection.HoldingList) {
+// GetResults writes out the results of closed positions against the benchmark
+// to the file name, in the given output format.
+func GetResults(outputFormat Format, closed *collection.HoldingList, name string) error {
 	var results []*result
-	var benchmarkPosition *collection.LinkedList
 
-	for key, index := range closed.Items() {
-		linkedList := closed.GetByIndex(index)
-		benchmarkPosition, _ = benchmark.Get(key)
-		results = append(results, resultSet(linkedList, benchmarkPosition)...)
+	for _, index := range closed.Items() {
+		results = append(results, resultSet(closed.GetByIndex(index))...)
 	}
 
 	log.Println("Outputting results: ")
 	switch outputFormat {
 	case CSV:
-		resultsToCSV(results)
+		return resultsToCSV(results, name)
 
 	}
+	return nil
 }
 
-func resultSet(closed, index *collection.LinkedList) []*result {
+// resultSet returns the results of a list of closed positions.
+// Each position's alpha is its return over that of the benchmark's level of its ticker,
+// from when the position was bought to when it was sold.
+// Positions without benchmark levels are measured against a flat benchmark.
+func resultSet(closed *collection.LinkedList) []*result {
 	var results []*result
-	var security, benchmarkSecurity instrument.Security
-	var node = closed.PeekFront()
-
-	switch node.Financial.(type) {
-	case instrument.Security:
-		benchmarkSecurity = index.PeekFront().GetUnderlying().(instrument.Security)
-	default:
-		return nil
-	}
 
-	for node := closed.PeekFront(); node == nil; node = node.Next() {
-		security = node.GetUnderlying().(instrument.Security)
-		pctReturn := utils.DivideAmt(security.SellPrice.Amount-security.BuyPrice.Amount, security.BuyPrice.Amount)
+	for node := closed.PeekFront(); node != nil; node = node.Next() {
+		security, ok := node.GetUnderlying().(instrument.Security)
+		if !ok {
+			continue
+		}
+		pctReturn := percentChange(security.BuyPrice.Amount, security.SellPrice.Amount)
 
-		newResult := &result{
+		var benchmarkReturn utils.Amount
+		if entry, exit := security.BenchmarkBuy, security.BenchmarkSell; entry != nil && exit != nil && entry.Amount != 0 {
+			benchmarkReturn = percentChange(entry.Amount, exit.Amount)
+		}
+
+		results = append(results, &result{
 			Security:  &security,
 			PctReturn: pctReturn,
-			Alpha:     pctReturn - utils.DivideAmt(benchmarkSecurity.SellPrice.Amount-security.BuyPrice.Amount, security.BuyPrice.Amount),
-		}
-		newResult.Nticks = closed.Nticks - newResult.Instrument.Nticks
-		results = append(results, newResult)
+			Alpha:     pctReturn - benchmarkReturn,
+		})
 	}
 	return results
 }
 
-func resultsToCSV(results []*result) (ok bool) {
+// percentChange returns the change from one price to another, in hundredths of a percent.
+func percentChange(from, to utils.Amount) utils.Amount {
+	return utils.DivideAmt((to-from)*100, from)
+}
+
+func resultsToCSV(results []*result, name string) error {
 	var output [][]string
 	output = append(output, headers)
 
@@ -153,19 +186,20 @@ func resultsToCSV(results []*result) (ok bool) {
 		output = append(output, toSlice(result))
 	}
 
-	outFile, fileErr := os.Create("simOutput.csv")
+	outFile, fileErr := os.Create(name)
 	if fileErr != nil {
-		log.Fatal("Cannot create file: ", fileErr)
+		return fileErr
 	}
 
-	// TEMP: allow filename as method argument
 	w := csv.NewWriter(outFile)
 
 	for _, row := range output {
 		w.Write(row)
 	}
 	w.Flush()
-	outFile.Close()
-
-	return true
+	if err := w.Error(); err != nil {
+		outFile.Close()
+		return err
+	}
+	return outFile.Close()
 }
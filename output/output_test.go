@@ -1,29 +1,95 @@
 package output
 
 import (
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
-	"github.com/jakeschurch/porttools/collection/benchmark"
+	"github.com/jakeschurch/porttools/collection"
 	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/utils"
 )
 
-// FIX: UPDATE/REALLOCATE
-
 func TestGetResults(t *testing.T) {
-	type args struct {
-		closedholdings []instrument.Holding
-		benchmark      *benchmark.Index
-		outputFormat   Format
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "simOutput.csv")
+
+	// mockSecurity returns volume shares of ticker bought at buy and sold at sell,
+	// while the benchmark's level of ticker moved from benchmarkBuy to benchmarkSell.
+	// Benchmark levels of 0 are left unset.
+	mockSecurity := func(ticker string, volume utils.Amount, buy, sell, benchmarkBuy, benchmarkSell float64) *instrument.Security {
+		date := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+		quote := instrument.NewQuote(utils.FloatAmount(sell), utils.FloatAmount(sell), date, *instrument.NewInstrument(ticker, volume))
+		security := instrument.NewSecurity(&utils.DatedMetric{Amount: utils.FloatAmount(buy), Date: date},
+			&utils.DatedMetric{Amount: utils.FloatAmount(sell), Date: date.Add(time.Hour)}, *instrument.NewAsset(quote))
+		if benchmarkBuy != 0 {
+			security.BenchmarkBuy = &utils.DatedMetric{Amount: utils.FloatAmount(benchmarkBuy), Date: date}
+			security.BenchmarkSell = &utils.DatedMetric{Amount: utils.FloatAmount(benchmarkSell), Date: date.Add(time.Hour)}
+		}
+		return security
 	}
+
 	tests := []struct {
-		name string
-		args args
+		name         string
+		outputFormat Format
+		closed       []*instrument.Security
+		want         []string
 	}{
-		// TODO: Add test cases.
+		{"No closed positions", CSV, nil, nil},
+		{"Closed positions", CSV, []*instrument.Security{
+			// the benchmark's level of AAPL rises 5% over the positions' holding period.
+			mockSecurity("AAPL", 10, 50.00, 55.00, 50.00, 52.50),
+			mockSecurity("AAPL", 1500, 50.00, 45.00, 50.00, 52.50),
+			mockSecurity("GOOGL", 2, 100.00, 90.00, 0, 0),
+		}, []string{
+			// ticker, volume, buy price, sell price, percent return and alpha against the benchmark over the holding period.
+			"AAPL 1,500 $50.00 $45.00 -10.00% -15.00%",
+			"AAPL 10 $50.00 $55.00 10.00% 5.00%",
+			"GOOGL 2 $100.00 $90.00 -10.00% -10.00%",
+		}},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			GetResults(tt.args.closedholdings, tt.args.benchmark, tt.args.outputFormat)
+			closed := collection.NewHoldingList()
+			for _, security := range tt.closed {
+				if err := closed.Insert(security); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := GetResults(tt.outputFormat, closed, name); err != nil {
+				t.Fatalf("GetResults() error = %v", err)
+			}
+
+			f, err := os.Open(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			rows, err := csv.NewReader(f).ReadAll()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) == 0 || len(rows[0]) != len(headers) {
+				t.Fatalf("GetResults() wrote headers %v, want %v", rows, headers)
+			}
+
+			var got []string
+			for _, row := range rows[1:] {
+				got = append(got, strings.Join([]string{row[0], row[1], row[4], row[6], row[13], row[14]}, " "))
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("GetResults() wrote rows\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
 		})
 	}
 }
@@ -1,17 +1,15 @@
 package porttools
 
 import (
-	"bufio"
+	"context"
 	"errors"
 	"io"
 	"log"
-	"os"
 	"path/filepath"
-	"strconv"
-	"strings"
 	"sync"
 	"time"
 
+	"github.com/jakeschurch/porttools/calendar"
 	"github.com/jakeschurch/porttools/collection/benchmark"
 	"github.com/jakeschurch/porttools/collection/portfolio"
 	"github.com/jakeschurch/porttools/config"
@@ -21,43 +19,63 @@ import (
 )
 
 var (
-	Oms         *OMS
-	Port        *portfolio.Portfolio
-	positionLog *output.PositionLog
-	index       *benchmark.Index
-	strategy    Strategy
-	simConfig   config.Config
-	costMethod  utils.CostMethod
-
-	// ErrInvalidFileGlob indiciates that no files could be found from given glob
-	ErrInvalidFileGlob = errors.New("No files could be found from file glob")
-
-	// ErrInvalidFileDelim is thrown when file delimiter is not able to be parsed
-	ErrInvalidFileDelim = errors.New("File delimiter could not be parsed")
-)
+	// ErrNoAlgorithm indicates that a simulation was run without a strategy's algorithm to run.
+	ErrNoAlgorithm = errors.New("Algorithm needs to be implemented by end-user")
 
-func init() {
-	Oms = NewOMS()
-	Port = portfolio.New()
-	positionLog = output.NewPositionLog()
-	index = benchmark.NewIndex()
-}
+	// ErrNoOutputDir indicates that a simulation was run without a directory to write its results to.
+	ErrNoOutputDir = errors.New("Simulation needs an output directory to write results to")
+)
 
 // NewSimulation is a constructor for the Simulation data type,
 // and a pre-processor function for the embedded types.
 func NewSimulation(file string) (*Simulation, error) {
-	simConfig, simConfigErr := config.Load(file)
+	cfg, simConfigErr := config.Load(file)
 	if simConfigErr != nil {
-		log.Fatal("Config error reached: ", simConfigErr)
 		return nil, simConfigErr
 	}
-	costMethod = simConfig.Simulation.Costmethod
-	Port.UpdateCash(utils.FloatAmount(simConfig.Backtest.StartCashAmt))
+	return NewSimulationFromConfig(*cfg)
+}
+
+// NewSimulationFromConfig creates a Simulation from a loaded config.
+// Each simulation owns its OMS, portfolio, benchmark and position log,
+// so that several simulations, e.g. of variants of a config, can be run at once.
+func NewSimulationFromConfig(cfg config.Config) (*Simulation, error) {
+	var simConfigErr error
+
 	sim := &Simulation{
-		// Channels
-		processChan: make(chan *instrument.Tick),
-		tickChan:    make(chan *instrument.Tick),
-		errChan:     make(chan error),
+		config:    cfg,
+		port:      portfolio.New(),
+		positions: output.NewPositionLog(),
+		index:     benchmark.NewIndex(),
+	}
+	sim.oms = NewOMS(sim.port, sim.positions)
+	sim.oms.ctx.params = cfg.Strategy.Params
+	sim.events = sim.oms.events
+	sim.oms.costMethod = cfg.Simulation.Costmethod
+	sim.oms.barFill = cfg.Simulation.BarFill
+	sim.oms.index = sim.index
+	sim.port.UpdateCash(utils.FloatAmount(cfg.Backtest.StartCashAmt))
+
+	if cfg.Simulation.BarRate > 0 {
+		sim.bars = newBarAggregator(time.Duration(cfg.Simulation.BarRate))
+	}
+	if sim.exchange, sim.session, simConfigErr = sim.config.Exchange(); simConfigErr != nil {
+		return nil, simConfigErr
+	}
+	if sim.exchange != nil {
+		sim.loc = sim.exchange.Location()
+	} else if sim.loc, simConfigErr = sim.config.Location(); simConfigErr != nil {
+		return nil, simConfigErr
+	}
+	clock, simConfigErr := newClock(&sim.config)
+	if simConfigErr != nil {
+		return nil, simConfigErr
+	}
+	sim.SetClock(clock)
+	if cfg.Backtest.CorporateActions != "" {
+		if sim.actions, simConfigErr = LoadCorporateActions(cfg.Backtest.CorporateActions, sim.loc); simConfigErr != nil {
+			return nil, simConfigErr
+		}
 	}
 	log.Println("Created sim")
 	return sim, nil
@@ -65,228 +83,454 @@ func NewSimulation(file string) (*Simulation, error) {
 
 // Simulation embeds all data structs necessary for running a backtest of an algorithmic strategy.
 type Simulation struct {
-	mu          sync.RWMutex
-	processChan chan *instrument.Tick
-	tickChan    chan *instrument.Tick
-	errChan     chan error
+	mu      sync.RWMutex
+	source  TickSource
+	summary IngestSummary
+	bars    *barAggregator
+	clock   Clock
+
+	config    config.Config
+	oms       *OMS
+	port      *portfolio.Portfolio
+	positions *output.PositionLog
+	index     *benchmark.Index
+	strategy  Strategy
+
+	// exchange is nil if the simulation is not restricted to an exchange's trading session.
+	exchange *calendar.Exchange
+	session  calendar.Session
+
+	// actions are the corporate actions yet to be applied, in ex-date order.
+	actions []CorporateAction
+
+	// universe is the set of tickers replayed, nil if every security is replayed.
+	universe *universe
+
+	// events are the order and timer events yet to be handled, in time order.
+	events *Queue
+
+	// loc is the location days start and end in, and day the midnight the current day started at.
+	loc *time.Location
+	day time.Time
+}
+
+// SetStrategy sets the strategy orders are placed by.
+func (sim *Simulation) SetStrategy(s Strategy) {
+	sim.mu.Lock()
+	sim.strategy = s
+	sim.oms.strategy = s
+	sim.mu.Unlock()
+}
+
+// OMS returns the simulation's order management system.
+// Algorithms read the simulation's state through the StrategyContext passed to their callbacks.
+func (sim *Simulation) OMS() *OMS {
+	return sim.oms
+}
+
+// Portfolio returns the simulation's portfolio of open positions.
+func (sim *Simulation) Portfolio() *portfolio.Portfolio {
+	return sim.port
+}
+
+// PositionLog returns the log of the simulation's closed positions.
+func (sim *Simulation) PositionLog() *output.PositionLog {
+	return sim.positions
+}
+
+// Benchmark returns the simulation's benchmark index.
+func (sim *Simulation) Benchmark() *benchmark.Index {
+	return sim.index
+}
+
+// summarizer is implemented by TickSources that report on the records they have read.
+type summarizer interface {
+	Summary() IngestSummary
+}
+
+// Summary returns the ingestion summary of the simulation's last run,
+// if its TickSource reports one.
+func (sim *Simulation) Summary() IngestSummary {
+	sim.mu.RLock()
+	summary := sim.summary
+	sim.mu.RUnlock()
+	return summary
+}
+
+// SetSource sets the source of ticks replayed by the simulation,
+// in place of the data files found from the config's file glob.
+func (sim *Simulation) SetSource(src TickSource) {
+	sim.mu.Lock()
+	sim.source = src
+	sim.mu.Unlock()
+}
+
+// SetOutputDir sets the directory the simulation's results are written to,
+// along with the records quarantined from data files without a configured rejects file,
+// in place of the config's output directory.
+// Simulations run at once should each be given their own directory.
+func (sim *Simulation) SetOutputDir(dir string) {
+	sim.mu.Lock()
+	sim.config.Simulation.OutputDir = dir
+	sim.mu.Unlock()
+}
+
+// SetClock sets the clock events are replayed by, which the OMS reads the current time from.
+func (sim *Simulation) SetClock(c Clock) {
+	sim.mu.Lock()
+	sim.clock = c
+	sim.mu.Unlock()
+	sim.oms.SetClock(c)
 }
 
 // Run acts as the simulation's primary pipeline function; directing everything to where it needs to go.
-func (sim *Simulation) Run() error {
+//
+// Run stops once ctx is done, returning the context's error.
+// Whether the replay finishes or is stopped, by ctx or by the first error from
+// reading or processing events, the results gathered so far are written out.
+func (sim *Simulation) Run(ctx context.Context) (err error) {
 	log.Println("Starting sim...")
+	sim.mu.RLock()
+	strategy := sim.strategy
+	sim.mu.RUnlock()
 	if strategy.Algorithm == nil {
-		log.Fatal("Algorithm needs to be implemented by end-user")
-	}
-
-	done := make(chan struct{})
-	go func() {
-		cachedTicks := make([]*instrument.Tick, 0)
-
-		for sim.tickChan != nil {
-			tick, ok := <-sim.tickChan
-			if !ok {
-				if tick != nil {
-					cachedTicks = append(cachedTicks, tick)
-					// assuming rest of ticks are nil, loop over and process the remaining ticks
-				} else {
-					for i := range cachedTicks {
-						sim.process(cachedTicks[i])
-					}
-					break
-				}
-			}
-			if tick != nil {
-				sim.process(tick)
+		return ErrNoAlgorithm
+	}
+	switch sim.config.Simulation.BarFill {
+	case "", config.BarFillNextOpen, config.BarFillClose:
+	default:
+		return ErrInvalidBarFill
+	}
+
+	sim.mu.RLock()
+	src := sim.source
+	clock := sim.clock
+	outputDir := sim.config.Simulation.OutputDir
+	sim.mu.RUnlock()
+	if outputDir == "" {
+		return ErrNoOutputDir
+	}
+	if clock == nil {
+		clock = NewSimClock()
+		sim.SetClock(clock)
+	}
+
+	// sources opened by the simulation are closed once the replay stops,
+	// so that their files are released if it stops early.
+	var opened []io.Closer
+	defer func() {
+		for i := range opened {
+			if closeErr := opened[i].Close(); closeErr != nil && err == nil {
+				err = closeErr
 			}
 		}
-		close(done)
 	}()
 
-	log.Println("loading input...")
-	fileName, fileDate := fileInfo()
+	var tradeSrc *FileSource
+	if src == nil {
+		if sim.config.File.Cache != "" {
+			cacheSrc, err := loadTickCache(ctx, &sim.config)
+			if err != nil {
+				return err
+			}
+			opened = append(opened, cacheSrc)
+			src = cacheSrc
+		} else {
+			dataSrc, err := newDataSource(&sim.config)
+			if err != nil {
+				return err
+			}
+			if c, ok := dataSrc.(io.Closer); ok {
+				opened = append(opened, c)
+			}
+			src = dataSrc
+		}
+
+		if sim.config.Trades != nil {
+			tradeCfg := sim.config
+			tradeCfg.File = *sim.config.Trades
+			tradeCfg.File.Kind = config.KindTrades
+			if tradeCfg.File.OnBadRecord == config.OnBadRecordQuarantine &&
+				sim.config.File.OnBadRecord == config.OnBadRecordQuarantine &&
+				rejectsFile(tradeCfg.File, outputDir) == rejectsFile(sim.config.File, outputDir) {
+				return ErrSharedRejectsFile
+			}
 
-	// DO NOT REVIEW
-	colConfig := colConfig{tick: simConfig.File.Columns.Ticker,
-		bid:      simConfig.File.Columns.Bid,
-		bidSz:    simConfig.File.Columns.BidSize,
-		ask:      simConfig.File.Columns.Ask,
-		askSz:    simConfig.File.Columns.AskSize,
-		filedate: fileDate,
-		timeUnit: simConfig.File.TimestampUnit,
+			var err error
+			if tradeSrc, err = NewFileSource(&tradeCfg); err != nil {
+				return err
+			}
+			opened = append(opened, tradeSrc)
+		}
 	}
 
-	file, err := os.Open(fileName)
+	universe, err := newUniverse(&sim.config)
 	if err != nil {
 		return err
 	}
-	worker := newWorker(colConfig)
-	go worker.run(sim.tickChan, file)
-
-	<-done
-	log.Println(positionLog.ClosedPositions)
-	output.GetResults(output.CSV, positionLog.ClosedPositions, index.Holdings)
+	// captures are filtered by the same universe, so that changes of symbol carry over to them.
+	if itchSrc, ok := src.(*ITCHSource); ok && universe != nil {
+		itchSrc.filter = universe.contains
+	}
+	sim.universe = universe
+	// sources that may block waiting for events are read on another goroutine,
+	// so that the replay stops as soon as ctx is done.
+	events := eventSource(src)
+	switch src.(type) {
+	case *SliceSource, *CacheSource:
+	default:
+		blocking := withContext(ctx, events)
+		defer blocking.Close()
+		events = blocking
+	}
+	if sim.config.File.Columns.Venue != nil {
+		consolidator := NewConsolidator()
+		sim.oms.SetVenues(consolidator)
+		events = &nbboSource{EventSource: events, consolidator: consolidator}
+	}
+	if tradeSrc != nil {
+		trades := withContext(ctx, tradeSrc)
+		defer trades.Close()
+		events = mergeEvents(events, trades)
+	}
+	if universe != nil {
+		events = &universeSource{EventSource: events, universe: universe}
+	}
 
-	return nil
-}
+	log.Println("loading input...")
+	strategy.Start(sim.oms.ctx)
+	err = sim.replay(ctx, events, clock)
+	if err != nil {
+		log.Println("replay stopped:", err)
+	}
+	if sim.bars != nil {
+		if barErr := sim.processBars(sim.bars.Flush()); barErr != nil && err == nil {
+			err = barErr
+		}
+	}
+	strategy.End(sim.oms.ctx)
 
-func fileInfo() (string, time.Time) {
-	fileGlob, err := filepath.Glob(simConfig.File.Glob)
-	if err != nil || len(fileGlob) == 0 {
-		// return ErrInvalidFileGlob
+	if s, ok := src.(summarizer); ok {
+		summary := s.Summary()
+		if tradeSrc != nil {
+			summary = summary.Add(tradeSrc.Summary())
+		}
+		sim.mu.Lock()
+		sim.summary = summary
+		sim.mu.Unlock()
+		log.Println("ingested", summary)
 	}
-	file := fileGlob[0]
-	lastUnderscore := strings.LastIndex(file, "_")
-	fileDate := file[lastUnderscore+1:]
 
-	lastDate, dateErr := time.Parse(simConfig.File.ExampleDate, fileDate)
-	if dateErr != nil {
-		log.Fatal("Date cannot be parsed")
+	log.Println(sim.positions.ClosedPositions)
+	if outErr := output.GetResults(output.CSV, sim.positions.ClosedPositions, filepath.Join(outputDir, "simOutput.csv")); err == nil {
+		err = outErr
 	}
-	filedate := lastDate
-	return file, filedate
+	return err
 }
 
-// Process simulates tick data going through our simulation pipeline
-func (sim *Simulation) process(t *instrument.Tick) error {
+// replay processes every event yielded by src, advancing clock to the time of each in turn,
+// until src is exhausted or ctx is done.
+// Queued events due by the time of each event are handled before it;
+// once src is exhausted, those due by the end of the day of its last event are handled.
+func (sim *Simulation) replay(ctx context.Context, src EventSource, clock Clock) error {
+	sim.day = time.Time{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
 
-	Oms.Query(*t)
+		event, err := src.NextEvent()
+		if err == io.EOF {
+			if sim.day.IsZero() {
+				return nil
+			}
+			return sim.handleQueued(ctx, clock, sim.day.AddDate(0, 0, 1).Add(-time.Nanosecond))
+		}
+		if err != nil {
+			return err
+		}
+		sim.scheduleDay(event.Time())
+		if err = sim.handleQueued(ctx, clock, event.Time()); err != nil {
+			return err
+		}
 
-	Port.Update(*t.Quote)
+		if err = clock.Advance(ctx, event.Time()); err != nil {
+			return err
+		}
 
-	index.Update(*t.Quote)
+		if err = sim.applyActions(event.Time()); err != nil {
+			return err
+		}
 
+		switch event := event.(type) {
+		case *instrument.Tick:
+			err = sim.process(event)
+		case *instrument.Bar:
+			err = sim.processBar(event)
+		case *instrument.Trade:
+			err = sim.processTrade(event)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// handleQueued handles the queued events due at or before t in time order,
+// advancing clock to the time of each in turn.
+func (sim *Simulation) handleQueued(ctx context.Context, clock Clock, t time.Time) error {
+	for node := sim.events.Peek(); node != nil && !node.Time().After(t); node = sim.events.Peek() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		sim.events.Dequeue()
+		if err := clock.Advance(ctx, node.Time()); err != nil {
+			return err
+		}
+
+		switch data := node.data.(type) {
+		case *timerFired:
+			data.fn()
+		default:
+			if err := sim.oms.handle(data); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
-type colConfig struct {
-	tick, bid, bidSz, ask, askSz, tStamp uint8
-	filedate                             time.Time
-	timeUnit                             string
+// scheduleDay schedules the strategy's day hooks for the day of t, if t is the first event of its day.
+// No hooks are scheduled for days the simulation's exchange is closed.
+func (sim *Simulation) scheduleDay(t time.Time) {
+	local := t.In(sim.loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, sim.loc)
+	if day.Equal(sim.day) {
+		return
+	}
+	sim.day = day
+
+	open, close := day, day.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	if sim.exchange != nil {
+		var ok bool
+		if open, close, ok = sim.exchange.Hours(sim.session, day); !ok {
+			return
+		}
+	}
+	sim.Schedule(open, func() { sim.strategy.StartDay(sim.oms.ctx, day) })
+	sim.Schedule(close, func() { sim.strategy.EndDay(sim.oms.ctx, day) })
 }
 
-type worker struct {
-	dataChan chan []string
-	colCfg   colConfig
+// Schedule queues fn to be run at t, before any market data of the same time.
+// Functions are only run while the simulation is replaying market data,
+// up to the end of the day of its last event.
+// Schedule is not safe to call concurrently with a running simulation
+// other than from the simulation's own callbacks.
+func (sim *Simulation) Schedule(t time.Time, fn func()) {
+	schedule(sim.events, t, fn)
 }
 
-func newWorker(cols colConfig) *worker {
-	worker := &worker{
-		colCfg: cols,
-	}
-	return worker
+// ScheduleEvery queues fn to be run at from, then every interval after it.
+// Non-positive intervals run fn once, at from.
+func (sim *Simulation) ScheduleEvery(from time.Time, interval time.Duration, fn func()) {
+	scheduleEvery(sim.events, from, interval, fn)
 }
 
-func (worker *worker) run(outChan chan<- *instrument.Tick, r io.ReadSeeker) {
-	var lineCount int
-	done := make(chan struct{}, 2)
+// Process simulates tick data going through our simulation pipeline
+func (sim *Simulation) process(t *instrument.Tick) error {
+	if sim.bars != nil {
+		if err := sim.processBars(sim.bars.Add(*t)); err != nil {
+			return err
+		}
+	}
+
+	// the benchmark is brought up to date first, so orders filled against the tick record its level.
+	sim.index.Update(*t.Quote)
 
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		lineCount++
+	if sim.inSession(t.Timestamp) {
+		if err := sim.oms.Query(*t); err != nil {
+			return err
+		}
 	}
-	r.Seek(0, 0)
 
-	worker.dataChan = make(chan []string, lineCount)
-	go worker.send(outChan, done)
-	go worker.produce(done, r)
+	sim.port.Update(*t.Quote)
 
-	<-done
-	<-done
+	return nil
 }
 
-func (worker *worker) send(outChan chan<- *instrument.Tick, done chan struct{}) {
-	for {
-		data, ok := <-worker.dataChan
-		if !ok {
-			if len(worker.dataChan) == 0 {
-				close(outChan)
-				break
-			}
-		}
-		tick, err := worker.consume(data)
-		if tick != nil && err == nil {
-			outChan <- tick
+// inSession reports whether market data at t is routed to the strategy,
+// i.e. whether t falls within the simulation's trading session.
+func (sim *Simulation) inSession(t time.Time) bool {
+	return sim.exchange == nil || sim.exchange.InSession(sim.session, t)
+}
+
+// barInSession reports whether a bar is routed to the strategy,
+// i.e. whether any of the bar's interval falls within the simulation's trading session.
+// Bars without a length that are stamped at midnight are taken to be daily bars.
+func (sim *Simulation) barInSession(b *instrument.Bar) bool {
+	if sim.exchange == nil {
+		return true
+	}
+	start, end := b.Start.In(sim.loc), b.End.In(sim.loc)
+	if !end.After(start) {
+		if start.Hour() != 0 || start.Minute() != 0 || start.Second() != 0 || start.Nanosecond() != 0 {
+			return sim.inSession(start)
 		}
+		end = start.AddDate(0, 0, 1)
 	}
-	done <- struct{}{}
+	return sim.exchange.Overlaps(sim.session, start, end)
 }
 
-// 3 by 2 feet
-func (worker *worker) produce(done chan struct{}, r io.ReadSeeker) {
-	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
-
-	scanner.Scan() // for headers...
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		// Check to see if error has been thrown or
-		if err := scanner.Err(); err != nil {
-			if err == io.EOF {
-				break
-			} else {
-				log.Fatalln(err)
-			}
+// processBars passes completed bars to the strategy.
+func (sim *Simulation) processBars(bars []*instrument.Bar) error {
+	for i := range bars {
+		if !sim.barInSession(bars[i]) {
+			continue
 		}
-		record := strings.Split(line, "|")
-		if len(record) > 4 {
-			worker.dataChan <- record
+		if err := sim.oms.QueryBar(*bars[i]); err != nil {
+			return err
 		}
 	}
-	close(worker.dataChan)
-	log.Println("done reading from file")
-	done <- struct{}{}
+	return nil
 }
 
-func (worker *worker) consume(record []string) (*instrument.Tick, error) {
-	var loadErr, parseErr error
-	var tick *instrument.Tick
+// processBar simulates a bar of traded prices going through our simulation pipeline.
+func (sim *Simulation) processBar(b *instrument.Bar) error {
+	closeQuote := b.Quote(b.Price.Close, b.End)
 
-	tick = new(instrument.Tick)
-	tick.SetTicker(record[worker.colCfg.tick])
+	// orders filled on the bar record the benchmark's level at its close.
+	sim.index.Update(*closeQuote)
 
-	bid, bidErr := strconv.ParseFloat(record[worker.colCfg.bid], 64)
-	if bid == 0 {
-		return tick, errors.New("bid Price could not be parsed")
-	}
-	if bidErr != nil {
-		loadErr = errors.New("bid Price could not be parsed")
+	if sim.barInSession(b) {
+		if err := sim.oms.QueryPriceBar(*b); err != nil {
+			return err
+		}
 	}
-	tick.Bid = utils.FloatAmount(bid)
 
-	bidSz, bidSzErr := strconv.ParseFloat(record[worker.colCfg.bidSz], 64)
-	if bidSzErr != nil {
-		loadErr = errors.New("bid Size could not be parsed")
-	}
-	tick.BidSize = utils.Amount(bidSz)
+	sim.port.Update(*closeQuote)
 
-	ask, askErr := strconv.ParseFloat(record[worker.colCfg.ask], 64)
-	if ask == 0 {
-		return nil, askErr
-	}
-	if askErr != nil {
-		loadErr = errors.New("ask Price could not be parsed")
-	}
-	tick.Ask = utils.FloatAmount(ask)
+	return nil
+}
+
+// processTrade simulates a trade print going through our simulation pipeline.
+func (sim *Simulation) processTrade(t *instrument.Trade) error {
+	sim.oms.AddTrade(*t)
 
-	askSz, askSzErr := strconv.ParseFloat(record[worker.colCfg.askSz], 64)
-	if askSzErr != nil {
-		loadErr = errors.New("ask Size could not be parsed")
+	if sim.inSession(t.Timestamp) {
+		if err := sim.oms.QueryTrade(*t); err != nil {
+			return err
+		}
 	}
-	tick.AskSize = utils.Amount(askSz)
 
-	tickDuration, timeErr := time.ParseDuration(record[worker.colCfg.tStamp] + worker.colCfg.timeUnit)
+	sim.port.UpdateTrade(*t)
 
-	if timeErr != nil {
-		loadErr = timeErr
-	}
-	tick.Timestamp = worker.colCfg.filedate.Add(tickDuration)
+	sim.index.UpdateTrade(*t)
 
-	if parseErr != nil {
-		return tick, parseErr
-	}
-	if loadErr != nil {
-		log.Fatal("record could not be loaded")
-	}
-	return tick, nil
+	return nil
 }
@@ -0,0 +1,632 @@
+package porttools
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/calendar"
+	"github.com/jakeschurch/porttools/collection"
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+func mockSimulation(t testing.TB) *Simulation {
+	sim, err := NewSimulationFromConfig(config.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sim
+}
+
+// mockBuyAlgorithm buys a share of each quote it is passed, never exiting.
+type mockBuyAlgorithm struct {
+	tickers []string
+}
+
+func (a *mockBuyAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	a.tickers = append(a.tickers, q.Ticker())
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), 1)
+	return order.New(true, q), nil
+}
+
+func (a *mockBuyAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+func TestSimulation_concurrent(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		tickers []string
+	}{
+		{"AAPL", []string{"AAPL", "AAPL"}},
+		{"GOOGL", []string{"GOOGL", "GOOGL", "GOOGL"}},
+		{"MSFT", []string{"MSFT", "MSFT"}},
+	}
+	sims := make([]*Simulation, len(tests))
+	algos := make([]*mockBuyAlgorithm, len(tests))
+
+	var wg sync.WaitGroup
+	for i, tt := range tests {
+		sims[i], algos[i] = mockSimulation(t), &mockBuyAlgorithm{}
+		sims[i].SetStrategy(NewStrategy(algos[i]))
+
+		ticks := mockTicks(tt.tickers...)
+		events := make(chan instrument.Event, len(ticks))
+		for j := range ticks {
+			ticks[j].Ask = utils.FloatAmount(10)
+			ticks[j].Timestamp = open.Add(time.Duration(j) * time.Second)
+			events <- ticks[j]
+		}
+		close(events)
+
+		wg.Add(1)
+		go func(sim *Simulation) {
+			defer wg.Done()
+			if err := sim.replay(context.Background(), mockEventSource(events), NewSimClock()); err != nil {
+				t.Errorf("Simulation.replay() error = %v", err)
+			}
+		}(sims[i])
+	}
+	wg.Wait()
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if len(algos[i].tickers) != len(tt.tickers) {
+				t.Errorf("Algorithm saw %v, want %v", algos[i].tickers, tt.tickers)
+			}
+			for _, ticker := range algos[i].tickers {
+				if ticker != tt.name {
+					t.Errorf("Algorithm saw a quote of %s, want only %s", ticker, tt.name)
+				}
+			}
+			// each order fills at the next quote, so the order placed on the last quote is left unfilled.
+			if want := -utils.FloatAmount(10) * utils.Amount(len(tt.tickers)-1); sims[i].OMS().Cash() != want {
+				t.Errorf("OMS.Cash() = %d, want %d", sims[i].OMS().Cash(), want)
+			}
+			for _, other := range tests {
+				_, err := sims[i].Portfolio().GetList(other.name)
+				if held := err == nil; held != (other.name == tt.name) {
+					t.Errorf("Portfolio holds %s = %v, want %v", other.name, held, !held)
+				}
+			}
+		})
+	}
+}
+
+// mockEndlessSource yields ticks of AAPL, step apart, without end.
+type mockEndlessSource struct {
+	ts   time.Time
+	step time.Duration
+}
+
+func (src *mockEndlessSource) Next() (*instrument.Tick, error) {
+	src.ts = src.ts.Add(src.step)
+	tick := mockTicks("AAPL")[0]
+	tick.Timestamp = src.ts
+	return tick, nil
+}
+
+// mockCancelAlgorithm cancels a simulation's context once it has seen n quotes.
+type mockCancelAlgorithm struct {
+	n      int
+	cancel context.CancelFunc
+}
+
+func (a *mockCancelAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	if a.n--; a.n == 0 {
+		a.cancel()
+	}
+	return nil, nil
+}
+
+func (a *mockCancelAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+// mockOutputDir returns a temporary directory for simulation results to be written to,
+// along with a function to remove it.
+func mockOutputDir(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "porttools")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir, func() { os.RemoveAll(dir) }
+}
+
+func TestSimulation_Run_parallel(t *testing.T) {
+	tickers := []string{"AAPL", "GOOGL"}
+	dirs := make([]string, len(tickers))
+	sims := make([]*Simulation, len(tickers))
+
+	for i, ticker := range tickers {
+		dir, cleanup := mockOutputDir(t)
+		defer cleanup()
+		dirs[i] = dir
+
+		// the last record has a zero bid, so is quarantined to the simulation's rejects file.
+		var data string
+		for j := 1; j <= 4; j++ {
+			data += fmt.Sprintf("%d,%s,50.00,10,50.10,10\n", j, ticker)
+		}
+		data += "5," + ticker + ",0,10,50.10,10\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, "quotes_20170814"), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		cfg := config.Config{}
+		cfg.Simulation.OutputDir = dir
+		cfg.Backtest.StartCashAmt = 1000
+		cfg.File.Glob = filepath.Join(dir, "quotes_*")
+		cfg.File.ExampleDate = "20060102"
+		cfg.File.TimestampUnit = "ns"
+		cfg.File.OnBadRecord = config.OnBadRecordQuarantine
+		cfg.File.Columns.Ticker = config.Column{Index: 1}
+		cfg.File.Columns.Bid = config.Column{Index: 2}
+		cfg.File.Columns.BidSize = config.Column{Index: 3}
+		cfg.File.Columns.Ask = config.Column{Index: 4}
+		cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+		sim, err := NewSimulationFromConfig(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sim.SetStrategy(NewStrategy(&mockRoundTripAlgorithm{exit: true}))
+		sims[i] = sim
+	}
+
+	var wg sync.WaitGroup
+	for i := range sims {
+		wg.Add(1)
+		go func(sim *Simulation) {
+			defer wg.Done()
+			if err := sim.Run(context.Background()); err != nil {
+				t.Errorf("Simulation.Run() error = %v", err)
+			}
+		}(sims[i])
+	}
+	wg.Wait()
+
+	for i, ticker := range tickers {
+		t.Run(ticker, func(t *testing.T) {
+			f, err := os.Open(filepath.Join(dirs[i], "simOutput.csv"))
+			if err != nil {
+				t.Fatalf("Simulation.Run() did not write results: %v", err)
+			}
+			rows, err := csv.NewReader(f).ReadAll()
+			f.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rows) < 2 {
+				t.Fatalf("Simulation.Run() wrote results %v, want closed positions of %s", rows, ticker)
+			}
+			for _, row := range rows[1:] {
+				if row[0] != ticker {
+					t.Errorf("Simulation.Run() wrote a closed position of %s, want only %s", row[0], ticker)
+				}
+			}
+
+			rejects, err := ioutil.ReadFile(filepath.Join(dirs[i], "rejects.csv"))
+			if err != nil {
+				t.Fatalf("Simulation.Run() did not write rejects: %v", err)
+			}
+			if want := "\"5," + ticker + ",0,10,50.10,10\""; !strings.Contains(string(rejects), want) || strings.Count(string(rejects), "\n") != 2 {
+				t.Errorf("rejects file = %q, want only %s", rejects, want)
+			}
+		})
+	}
+}
+
+func TestSimulation_Run_cancel(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		name    string
+		paced   bool
+		timeout time.Duration
+		wantErr error
+	}{
+		{"Cancelled", false, 0, context.Canceled},
+		{"Timed out while paced", true, 50 * time.Millisecond, context.DeadlineExceeded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			if tt.timeout > 0 {
+				ctx, cancel = context.WithTimeout(context.Background(), tt.timeout)
+			}
+			defer cancel()
+
+			sim := mockSimulation(t)
+			sim.SetStrategy(NewStrategy(&mockCancelAlgorithm{n: 100, cancel: cancel}))
+			sim.SetOutputDir(dir)
+			sim.SetSource(&mockEndlessSource{ts: time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC), step: time.Second})
+			if tt.paced {
+				clock, err := NewPacedClock(1, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sim.SetClock(clock)
+			}
+
+			if err := sim.Run(ctx); err != tt.wantErr {
+				t.Errorf("Simulation.Run() error = %v, want %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, "simOutput.csv")); err != nil {
+				t.Errorf("Simulation.Run() did not write results: %v", err)
+			}
+		})
+	}
+
+	if err := mockSimulation(t).Run(context.Background()); err != ErrNoAlgorithm {
+		t.Errorf("Simulation.Run() error = %v, want %v", err, ErrNoAlgorithm)
+	}
+	sim := mockSimulation(t)
+	sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+	if err := sim.Run(context.Background()); err != ErrNoOutputDir {
+		t.Errorf("Simulation.Run() error = %v, want %v", err, ErrNoOutputDir)
+	}
+}
+
+func TestSimulation_Run_blocked(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	tests := []struct {
+		name  string
+		ticks []*instrument.Tick
+	}{
+		{"Idle before first tick", nil},
+		{"Idle after a tick", mockTicks("AAPL")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			// the channel is never closed, so the source blocks once its ticks are received.
+			tickChan := make(chan *instrument.Tick, len(tt.ticks))
+			for i := range tt.ticks {
+				tickChan <- tt.ticks[i]
+			}
+			sim := mockSimulation(t)
+			sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+			sim.SetSource(NewChanSource(tickChan))
+			sim.SetOutputDir(dir)
+
+			errChan := make(chan error, 1)
+			go func() { errChan <- sim.Run(ctx) }()
+			select {
+			case err := <-errChan:
+				if err != context.DeadlineExceeded {
+					t.Errorf("Simulation.Run() error = %v, want %v", err, context.DeadlineExceeded)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Simulation.Run() did not return once its context was done")
+			}
+		})
+	}
+}
+
+func TestSimulation_Run_pipe(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	// the pipe is never closed, so the source blocks reading standard input once its ticks are read.
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	stdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = stdin }()
+	if _, err = io.WriteString(w, "1,AAPL,50.00,10,50.10,10\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Config{}
+	cfg.Simulation.OutputDir = dir
+	cfg.File.Glob = "-"
+	cfg.File.ExampleDate = "20060102"
+	cfg.Simulation.StartDate = "20170814"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sim.SetStrategy(NewStrategy(&mockBuyAlgorithm{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	errChan := make(chan error, 1)
+	go func() { errChan <- sim.Run(ctx) }()
+	select {
+	case err := <-errChan:
+		if err != context.DeadlineExceeded {
+			t.Errorf("Simulation.Run() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Simulation.Run() did not return while reading a pipe once its context was done")
+	}
+
+	// standard input is left open for the rest of the process.
+	if _, err = r.Stat(); err != nil {
+		t.Errorf("Simulation.Run() closed standard input: %v", err)
+	}
+	r.Close()
+}
+
+func TestSimulation_Run_fillError(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	sim := mockSimulation(t)
+	sim.SetStrategy(NewStrategy(&mockExitAlgorithm{exit: mockOrder(false, "AAPL", 60.00, 61.00, 10)}))
+	ticks := mockTicks("AAPL", "AAPL", "AAPL")
+	for i := range ticks {
+		ticks[i].Timestamp = time.Date(2017, 8, 14, 9, 30, i, 0, time.UTC)
+	}
+	sim.SetSource(NewSliceSource(ticks))
+	sim.SetOutputDir(dir)
+
+	// an open order without a holding in the portfolio cannot be sold out of it.
+	if err := sim.oms.open.Insert(mockOrder(true, "AAPL", 50.00, 51.00, 10)); err != nil {
+		t.Fatal(err)
+	}
+	if err := sim.Run(context.Background()); err != collection.ErrNoListExists {
+		t.Errorf("Simulation.Run() error = %v, want %v", err, collection.ErrNoListExists)
+	}
+}
+
+// mockHookAlgorithm records the lifecycle hooks and quotes it is passed,
+// scheduling a timer at 15:55 of each day.
+type mockHookAlgorithm struct {
+	calls []string
+}
+
+func (a *mockHookAlgorithm) record(call string, t time.Time) {
+	a.calls = append(a.calls, call+" "+t.Format("01-02 15:04"))
+}
+
+func (a *mockHookAlgorithm) OnStart(ctx *StrategyContext) {
+	a.calls = append(a.calls, "start")
+}
+
+func (a *mockHookAlgorithm) OnDayStart(ctx *StrategyContext, day time.Time) {
+	a.record("day start", day)
+	flatten := day.Add(15*time.Hour + 55*time.Minute)
+	ctx.Schedule(flatten, func() { a.record("timer", flatten) })
+}
+
+func (a *mockHookAlgorithm) OnDayEnd(ctx *StrategyContext, day time.Time) {
+	a.record("day end", day)
+}
+
+func (a *mockHookAlgorithm) OnEnd(ctx *StrategyContext) {
+	a.calls = append(a.calls, "end")
+}
+
+func (a *mockHookAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	a.record("quote", q.Timestamp)
+	return nil, nil
+}
+
+func (a *mockHookAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+func TestSimulation_Run_hooks(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	session := calendar.Session{Open: calendar.Clock(9*time.Hour + 30*time.Minute), Close: calendar.Clock(16 * time.Hour)}
+	exchange, err := calendar.NewExchange("UTC", map[string]calendar.Session{calendar.Regular: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+	times := []time.Time{
+		day.Add(10 * time.Hour), day.Add(15*time.Hour + 58*time.Minute),
+		day.Add(33 * time.Hour), day.Add(34 * time.Hour),
+	}
+
+	tests := []struct {
+		name     string
+		exchange *calendar.Exchange
+		want     []string
+	}{
+		{"Calendar days", nil, []string{
+			"start",
+			"day start 08-14 00:00", "quote 08-14 10:00", "timer 08-14 15:55", "quote 08-14 15:58", "day end 08-14 00:00",
+			"day start 08-15 00:00", "quote 08-15 09:00", "quote 08-15 10:00", "timer 08-15 15:55", "day end 08-15 00:00",
+			"end",
+		}},
+		{"Exchange sessions", exchange, []string{
+			"start",
+			"day start 08-14 00:00", "quote 08-14 10:00", "timer 08-14 15:55", "quote 08-14 15:58", "day end 08-14 00:00",
+			"day start 08-15 00:00", "quote 08-15 10:00", "timer 08-15 15:55", "day end 08-15 00:00",
+			"end",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mockSimulation(t)
+			sim.exchange, sim.session = tt.exchange, session
+			algo := &mockHookAlgorithm{}
+			sim.SetStrategy(NewStrategy(algo))
+
+			ticks := mockTicks("AAPL", "AAPL", "AAPL", "AAPL")
+			for i := range ticks {
+				ticks[i].Timestamp = times[i]
+			}
+			sim.SetSource(NewSliceSource(ticks))
+			sim.SetOutputDir(dir)
+
+			if err := sim.Run(context.Background()); err != nil {
+				t.Fatalf("Simulation.Run() error = %v", err)
+			}
+			if len(algo.calls) != len(tt.want) {
+				t.Fatalf("Algorithm calls = %v, want %v", algo.calls, tt.want)
+			}
+			for i := range tt.want {
+				if algo.calls[i] != tt.want[i] {
+					t.Errorf("Algorithm call %d = %q, want %q", i, algo.calls[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// mockTradeAlgorithm buys a share of the first quote it is passed,
+// recording the trades it is passed and the trade metrics of their tickers.
+type mockTradeAlgorithm struct {
+	mockBuyAlgorithm
+	trades  []string
+	metrics map[string]instrument.TradeMetrics
+}
+
+func (a *mockTradeAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	if len(a.tickers) > 0 {
+		return nil, nil
+	}
+	return a.mockBuyAlgorithm.EntryCheck(ctx, q)
+}
+
+func (a *mockTradeAlgorithm) OnTrade(ctx *StrategyContext, t instrument.Trade) (*order.Order, error) {
+	a.trades = append(a.trades, t.Ticker()+"@"+t.Price.String())
+	if metrics, ok := ctx.TradeMetrics(t.Ticker()); ok {
+		if a.metrics == nil {
+			a.metrics = make(map[string]instrument.TradeMetrics)
+		}
+		a.metrics[t.Ticker()] = metrics
+	}
+	return nil, nil
+}
+
+func TestSimulation_Run_trades(t *testing.T) {
+	dir, cleanup := mockOutputDir(t)
+	defer cleanup()
+
+	files := map[string]string{
+		"quotes_20170814": "1,AAPL,50.00,10,50.10,10\n3,AAPL,50.50,10,50.60,10\n",
+		"trades_20170814": "2,AAPL,50.05,100\n4,AAPL,50.55,300\n5,GOOGL,10.00,100\n6,GOOGL,11.00,300\n",
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Config{}
+	cfg.Simulation.OutputDir = dir
+	cfg.Backtest.StartCashAmt = 1000
+	cfg.File.Glob = filepath.Join(dir, "quotes_*")
+	cfg.File.ExampleDate = "20060102"
+	cfg.File.TimestampUnit = "ns"
+	cfg.File.Columns.Ticker = config.Column{Index: 1}
+	cfg.File.Columns.Bid = config.Column{Index: 2}
+	cfg.File.Columns.BidSize = config.Column{Index: 3}
+	cfg.File.Columns.Ask = config.Column{Index: 4}
+	cfg.File.Columns.AskSize = config.Column{Index: 5}
+
+	trades := cfg.File
+	trades.Glob = filepath.Join(dir, "trades_*")
+	trades.Columns.Price = config.Column{Index: 2}
+	trades.Columns.Size = config.Column{Index: 3}
+	cfg.Trades = &trades
+
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	algo := &mockTradeAlgorithm{}
+	sim.SetStrategy(NewStrategy(algo))
+	if err = sim.Run(context.Background()); err != nil {
+		t.Fatalf("Simulation.Run() error = %v", err)
+	}
+
+	// only trades printed once the position is held update its metrics.
+	list, err := sim.port.GetList("AAPL")
+	if err != nil {
+		t.Fatalf("Portfolio.GetList() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"Trades checked by the algorithm", strings.Join(algo.trades, ","), "AAPL@$50.05,AAPL@$50.55,GOOGL@$10.00,GOOGL@$11.00"},
+		{"Held traded volume read by the algorithm", algo.metrics["AAPL"].TradedVolume, utils.Amount(400)},
+		{"Unheld traded volume read by the algorithm", algo.metrics["GOOGL"].TradedVolume, utils.Amount(400)},
+		{"Unheld VWAP read by the algorithm", algo.metrics["GOOGL"].VWAP, utils.FloatAmount(10.75)},
+		{"Unheld last trade read by the algorithm", algo.metrics["GOOGL"].LastTrade.Amount, utils.FloatAmount(11.00)},
+		{"Portfolio traded volume", list.TradedVolume, utils.Amount(300)},
+		{"Portfolio VWAP", list.VWAP, utils.FloatAmount(50.55)},
+		{"Portfolio last trade", list.LastTrade.Amount, utils.FloatAmount(50.55)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSimulation_barInSession(t *testing.T) {
+	session := calendar.Session{Open: calendar.Clock(9*time.Hour + 30*time.Minute), Close: calendar.Clock(16 * time.Hour)}
+	exchange, err := calendar.NewExchange("UTC", map[string]calendar.Session{calendar.Regular: session})
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2017, 8, 14, 0, 0, 0, 0, time.UTC)
+	bar := func(start, end time.Time) *instrument.Bar {
+		return instrument.NewOHLCVBar("AAPL", start, end, instrument.NewOHLC(utils.FloatAmount(50.00)), 100)
+	}
+
+	tests := []struct {
+		name string
+		bar  *instrument.Bar
+		want bool
+	}{
+		{"Daily bar", bar(day, day.AddDate(0, 0, 1)), true},
+		{"Daily bar without a length", bar(day, day), true},
+		{"Minute bar in session", bar(day.Add(10*time.Hour), day.Add(10*time.Hour+time.Minute)), true},
+		{"Minute bar after close", bar(day.Add(16*time.Hour), day.Add(16*time.Hour+time.Minute)), false},
+		{"Pre-market bar without a length", bar(day.Add(8*time.Hour), day.Add(8*time.Hour)), false},
+		{"Daily bar on weekend", bar(day.AddDate(0, 0, -1), day), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := mockSimulation(t)
+			sim.exchange, sim.session, sim.loc = exchange, session, exchange.Location()
+
+			if got := sim.barInSession(tt.bar); got != tt.want {
+				t.Errorf("Simulation.barInSession() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
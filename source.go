@@ -0,0 +1,228 @@
+package porttools
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+// TickSource is an interface that yields ticks to a simulation in the order they should be processed.
+// Next should return io.EOF once there are no more ticks to yield.
+type TickSource interface {
+	Next() (*instrument.Tick, error)
+}
+
+// EventSource is an interface for sources of market data other than ticks, such as bars.
+// NextEvent should return io.EOF once there are no more events to yield.
+type EventSource interface {
+	NextEvent() (instrument.Event, error)
+}
+
+// eventSource returns src as an EventSource.
+func eventSource(src TickSource) EventSource {
+	if events, ok := src.(EventSource); ok {
+		return events
+	}
+	return tickEvents{src}
+}
+
+// tickEvents is an EventSource of the ticks yielded by a TickSource.
+type tickEvents struct {
+	TickSource
+}
+
+func (src tickEvents) NextEvent() (instrument.Event, error) {
+	tick, err := src.Next()
+	if err != nil {
+		return nil, err
+	}
+	return tick, nil
+}
+
+// ctxSource is an EventSource that stops waiting on a source that may block,
+// such as a ChanSource or a FileSource reading from a pipe, once its context is done.
+// Events are read from the source on another goroutine, one at a time as they are asked for,
+// so that the source is never read ahead of the events that have been yielded.
+type ctxSource struct {
+	EventSource
+	ctx context.Context
+
+	requests chan struct{}
+	results  chan eventResult
+	done     chan struct{}
+	start    sync.Once
+	stop     sync.Once
+}
+
+type eventResult struct {
+	event instrument.Event
+	err   error
+}
+
+// withContext returns an EventSource of the events of src that returns ctx.Err()
+// once ctx is done, even while src is blocked. Close must be called to release the source.
+func withContext(ctx context.Context, src EventSource) *ctxSource {
+	return &ctxSource{
+		EventSource: src,
+		ctx:         ctx,
+		requests:    make(chan struct{}),
+		results:     make(chan eventResult),
+		done:        make(chan struct{}),
+	}
+}
+
+// NextEvent returns the next event of the source, or ctx.Err() if ctx is done first.
+func (src *ctxSource) NextEvent() (instrument.Event, error) {
+	if err := src.ctx.Err(); err != nil {
+		src.Close()
+		return nil, err
+	}
+	src.start.Do(func() {
+		go src.pump()
+	})
+
+	select {
+	case src.requests <- struct{}{}:
+	case <-src.ctx.Done():
+		src.Close()
+		return nil, src.ctx.Err()
+	case <-src.done:
+		return nil, io.EOF
+	}
+	select {
+	case r := <-src.results:
+		return r.event, r.err
+	case <-src.ctx.Done():
+		src.Close()
+		return nil, src.ctx.Err()
+	}
+}
+
+// Close stops reading from the source. A read the source is blocked in is left to return on its own.
+func (src *ctxSource) Close() error {
+	src.stop.Do(func() {
+		close(src.done)
+	})
+	return nil
+}
+
+// pump reads an event from the source for each request, until the ctxSource is closed.
+func (src *ctxSource) pump() {
+	for {
+		select {
+		case <-src.requests:
+		case <-src.done:
+			return
+		}
+		event, err := src.EventSource.NextEvent()
+		select {
+		case src.results <- eventResult{event, err}:
+		case <-src.done:
+			return
+		}
+	}
+}
+
+// mergedSource is an EventSource that merges the events of several sources in time order.
+// Events with equal times are yielded in the order their sources were given.
+type mergedSource struct {
+	sources []EventSource
+	heads   []instrument.Event
+	started bool
+}
+
+// mergeEvents returns an EventSource of the events yielded by each of sources, in time order.
+func mergeEvents(sources ...EventSource) EventSource {
+	if len(sources) == 1 {
+		return sources[0]
+	}
+	return &mergedSource{
+		sources: sources,
+		heads:   make([]instrument.Event, len(sources)),
+	}
+}
+
+// NextEvent returns the earliest event of any of the merged sources.
+func (src *mergedSource) NextEvent() (instrument.Event, error) {
+	if !src.started {
+		for i := range src.sources {
+			if err := src.advance(i); err != nil {
+				return nil, err
+			}
+		}
+		src.started = true
+	}
+
+	next := -1
+	for i, head := range src.heads {
+		if head != nil && (next == -1 || head.Time().Before(src.heads[next].Time())) {
+			next = i
+		}
+	}
+	if next == -1 {
+		return nil, io.EOF
+	}
+
+	event := src.heads[next]
+	if err := src.advance(next); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// advance reads the next event of source i.
+func (src *mergedSource) advance(i int) error {
+	event, err := src.sources[i].NextEvent()
+	if err == io.EOF {
+		src.heads[i] = nil
+		return nil
+	}
+	src.heads[i] = event
+	return err
+}
+
+// ------------------------------------------------------------------
+
+// SliceSource is a TickSource of ticks held in memory.
+type SliceSource struct {
+	ticks []*instrument.Tick
+	next  int
+}
+
+// NewSliceSource returns a new SliceSource that yields ticks in the order given.
+func NewSliceSource(ticks []*instrument.Tick) *SliceSource {
+	return &SliceSource{ticks: ticks}
+}
+
+// Next returns the next tick in the source's slice.
+func (src *SliceSource) Next() (*instrument.Tick, error) {
+	if src.next >= len(src.ticks) {
+		return nil, io.EOF
+	}
+	tick := src.ticks[src.next]
+	src.next++
+	return tick, nil
+}
+
+// ------------------------------------------------------------------
+
+// ChanSource is a TickSource of ticks received from a channel.
+type ChanSource struct {
+	tickChan <-chan *instrument.Tick
+}
+
+// NewChanSource returns a new ChanSource that yields ticks from tickChan until it is closed.
+func NewChanSource(tickChan <-chan *instrument.Tick) *ChanSource {
+	return &ChanSource{tickChan: tickChan}
+}
+
+// Next blocks until a tick is received from the source's channel.
+func (src *ChanSource) Next() (*instrument.Tick, error) {
+	tick, ok := <-src.tickChan
+	if !ok {
+		return nil, io.EOF
+	}
+	return tick, nil
+}
@@ -0,0 +1,97 @@
+package porttools
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+func mockTicks(tickers ...string) []*instrument.Tick {
+	ticks := make([]*instrument.Tick, len(tickers))
+	for i := range tickers {
+		ticks[i] = instrument.NewTick(10, 10, new(instrument.Quote))
+		ticks[i].SetTicker(tickers[i])
+	}
+	return ticks
+}
+
+func TestTickSource_Next(t *testing.T) {
+	ticks := mockTicks("AAPL", "GOOGL", "AAPL")
+
+	tickChan := make(chan *instrument.Tick, len(ticks))
+	for i := range ticks {
+		tickChan <- ticks[i]
+	}
+	close(tickChan)
+
+	tests := []struct {
+		name string
+		src  TickSource
+	}{
+		{"SliceSource", NewSliceSource(ticks)},
+		{"ChanSource", NewChanSource(tickChan)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := range ticks {
+				got, err := tt.src.Next()
+				if err != nil {
+					t.Fatalf("Next() error = %v", err)
+				}
+				if got != ticks[i] {
+					t.Errorf("Next() = %v, want tick %d", got, i)
+				}
+			}
+			if _, err := tt.src.Next(); err != io.EOF {
+				t.Errorf("Next() error = %v, want %v", err, io.EOF)
+			}
+		})
+	}
+}
+
+func Test_mergeEvents(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+
+	quotes := mockTicks("AAPL", "AAPL", "AAPL")
+	for i := range quotes {
+		quotes[i].Timestamp = open.Add(time.Duration(2*i) * time.Second)
+	}
+	trades := []*instrument.Trade{
+		instrument.NewTrade("AAPL", 5000, 100, "", open.Add(time.Second)),
+		instrument.NewTrade("AAPL", 5000, 100, "", open.Add(2*time.Second)),
+		instrument.NewTrade("AAPL", 5000, 100, "", open.Add(10*time.Second)),
+	}
+	tradeChan := make(chan instrument.Event, len(trades))
+	for i := range trades {
+		tradeChan <- trades[i]
+	}
+	close(tradeChan)
+
+	src := mergeEvents(eventSource(NewSliceSource(quotes)), mockEventSource(tradeChan))
+
+	want := []instrument.Event{quotes[0], trades[0], quotes[1], trades[1], quotes[2], trades[2]}
+	for i := range want {
+		got, err := src.NextEvent()
+		if err != nil {
+			t.Fatalf("NextEvent() error = %v", err)
+		}
+		if got != want[i] {
+			t.Errorf("NextEvent() %d = %T at %v, want %T at %v", i, got, got.Time(), want[i], want[i].Time())
+		}
+	}
+	if _, err := src.NextEvent(); err != io.EOF {
+		t.Errorf("NextEvent() error = %v, want %v", err, io.EOF)
+	}
+}
+
+type mockEventSource <-chan instrument.Event
+
+func (src mockEventSource) NextEvent() (instrument.Event, error) {
+	event, ok := <-src
+	if !ok {
+		return nil, io.EOF
+	}
+	return event, nil
+}
@@ -0,0 +1,124 @@
+package porttools
+
+import (
+	"time"
+
+	"github.com/jakeschurch/porttools/collection/portfolio"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+// StrategyContext is passed to each of an algorithm's callbacks,
+// giving read-only access to the state of the simulation the algorithm trades in,
+// and submitting and cancelling orders and scheduling timers on its behalf.
+//
+// A StrategyContext is only safe to use from the callbacks of its simulation.
+type StrategyContext struct {
+	oms    *OMS
+	port   *portfolio.Portfolio
+	params map[string]interface{}
+}
+
+func newStrategyContext(oms *OMS, port *portfolio.Portfolio) *StrategyContext {
+	return &StrategyContext{oms: oms, port: port}
+}
+
+// Now returns the current time of the simulation.
+func (c *StrategyContext) Now() time.Time {
+	return c.oms.Now()
+}
+
+// Cash returns the simulation's cash balance, which starts at the backtest's startCashAmt.
+func (c *StrategyContext) Cash() utils.Amount {
+	return c.oms.Cash()
+}
+
+// Position returns copies of the holdings of a ticker in the simulation's portfolio,
+// in the order they were opened. No holdings are returned if the ticker is not held.
+func (c *StrategyContext) Position(ticker string) []instrument.Holding {
+	list, err := c.port.GetList(ticker)
+	if err != nil {
+		return nil
+	}
+
+	var holdings []instrument.Holding
+	for node := list.PeekFront(); node != nil; node = node.Next() {
+		switch h := node.Financial.(type) {
+		case *instrument.Holding:
+			holdings = append(holdings, *h)
+		case instrument.Holding:
+			holdings = append(holdings, h)
+		}
+	}
+	return holdings
+}
+
+// OpenOrders returns copies of the OMS's open orders of a ticker,
+// in the order they were filled. No orders are returned if the ticker has none open.
+func (c *StrategyContext) OpenOrders(ticker string) []order.Order {
+	list, err := c.oms.open.Get(ticker)
+	if err != nil {
+		return nil
+	}
+
+	var orders []order.Order
+	for node := list.PeekFront(); node != nil; node = node.Next() {
+		if o, ok := node.GetUnderlying().(order.Order); ok {
+			orders = append(orders, o)
+		}
+	}
+	return orders
+}
+
+// TradeMetrics returns the last sale, volume-weighted average price and traded volume of a security,
+// gathered from the trade prints replayed so far whether or not it is held,
+// and whether any trades have been printed for it.
+func (c *StrategyContext) TradeMetrics(ticker string) (instrument.TradeMetrics, bool) {
+	return c.oms.TradeMetrics(ticker)
+}
+
+// VenueQuotes returns the latest quote of each venue a security is quoted on.
+// Quotes are only kept when the simulation's data files have a venue column.
+func (c *StrategyContext) VenueQuotes(ticker string) []instrument.Tick {
+	return c.oms.VenueQuotes(ticker)
+}
+
+// Param returns the value of a strategy parameter given in the simulation's config,
+// as decoded from JSON, and whether it was given.
+func (c *StrategyContext) Param(name string) (interface{}, bool) {
+	value, ok := c.params[name]
+	return value, ok
+}
+
+// FloatParam returns the value of a numeric strategy parameter given in the simulation's config,
+// or def if it was not given or is not a number.
+func (c *StrategyContext) FloatParam(name string, def float64) float64 {
+	if value, ok := c.params[name].(float64); ok {
+		return value
+	}
+	return def
+}
+
+// Submit submits an order to the OMS, to be filled against the next quote of its ticker.
+// Sell orders sell shares held in the portfolio, and are rejected with ErrNegativeVolume
+// if they sell more shares than are held and not already being sold.
+func (c *StrategyContext) Submit(o *order.Order) error {
+	return c.oms.Submit(o)
+}
+
+// Cancel cancels a submitted order, if it has not yet been filled.
+func (c *StrategyContext) Cancel(o *order.Order) {
+	c.oms.Cancel(o)
+}
+
+// Schedule queues fn to be run at t, before any market data of the same time.
+func (c *StrategyContext) Schedule(at time.Time, fn func()) {
+	schedule(c.oms.events, at, fn)
+}
+
+// ScheduleEvery queues fn to be run at from, then every interval after it.
+// Non-positive intervals run fn once, at from.
+func (c *StrategyContext) ScheduleEvery(from time.Time, interval time.Duration, fn func()) {
+	scheduleEvery(c.oms.events, from, interval, fn)
+}
@@ -0,0 +1,211 @@
+package porttools
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+	"github.com/jakeschurch/porttools/order"
+	"github.com/jakeschurch/porttools/utils"
+)
+
+// contextSnapshot is the state of a simulation seen through its StrategyContext.
+type contextSnapshot struct {
+	now       time.Time
+	cash      utils.Amount
+	positions int
+	open      int
+}
+
+// mockContextAlgorithm submits an order of the size parameter on the first quote it is passed,
+// and a second order on the next quote that it cancels straight away,
+// taking a snapshot of its StrategyContext at each quote.
+type mockContextAlgorithm struct {
+	seen []contextSnapshot
+}
+
+func (a *mockContextAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	a.seen = append(a.seen, contextSnapshot{
+		now:       ctx.Now(),
+		cash:      ctx.Cash(),
+		positions: len(ctx.Position(q.Ticker())),
+		open:      len(ctx.OpenOrders(q.Ticker())),
+	})
+
+	if len(a.seen) <= 2 {
+		q.Instrument = *instrument.NewInstrument(q.Ticker(), utils.Amount(ctx.FloatParam("size", 1)))
+		o := order.New(true, q)
+		ctx.Submit(o)
+		if len(a.seen) == 2 {
+			ctx.Cancel(o)
+		}
+	}
+	return nil, nil
+}
+
+func (a *mockContextAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+// mockSellAlgorithm submits a sell of its ticker before any shares are held,
+// buys 3 shares, then submits sells of 5, 2 and 2 shares once the buy has been filled,
+// recording the error each order is submitted with.
+type mockSellAlgorithm struct {
+	quotes int
+	errs   []error
+}
+
+func (a *mockSellAlgorithm) submit(ctx *StrategyContext, buy bool, q instrument.Quote, volume utils.Amount) {
+	q.Instrument = *instrument.NewInstrument(q.Ticker(), volume)
+	a.errs = append(a.errs, ctx.Submit(order.New(buy, q)))
+}
+
+func (a *mockSellAlgorithm) EntryCheck(ctx *StrategyContext, q instrument.Quote) (*order.Order, error) {
+	switch a.quotes++; a.quotes {
+	case 1:
+		a.submit(ctx, false, q, 1)
+		a.submit(ctx, true, q, 3)
+	case 3:
+		a.submit(ctx, false, q, 5)
+		a.submit(ctx, false, q, 2)
+		a.submit(ctx, false, q, 2)
+	}
+	return nil, nil
+}
+
+func (a *mockSellAlgorithm) ExitCheck(ctx *StrategyContext, o order.Order, t instrument.Tick) (*order.Order, error) {
+	return nil, ErrOrderNotValid
+}
+
+func TestStrategyContext_Submit_sell(t *testing.T) {
+	var cfg config.Config
+	cfg.Backtest.StartCashAmt = 100
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	algo := &mockSellAlgorithm{}
+	sim.SetStrategy(NewStrategy(algo))
+
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	ticks := mockTicks("AAPL", "AAPL", "AAPL", "AAPL", "AAPL")
+	events := make(chan instrument.Event, len(ticks))
+	for i := range ticks {
+		ticks[i].Bid, ticks[i].Ask = utils.FloatAmount(9), utils.FloatAmount(10)
+		ticks[i].Timestamp = open.Add(time.Duration(i) * time.Second)
+		events <- ticks[i]
+	}
+	close(events)
+
+	if err := sim.replay(context.Background(), mockEventSource(events), NewSimClock()); err != nil {
+		t.Fatalf("Simulation.replay() error = %v", err)
+	}
+
+	wantErrs := []error{ErrNegativeVolume, nil, ErrNegativeVolume, nil, ErrNegativeVolume}
+	if len(algo.errs) != len(wantErrs) {
+		t.Fatalf("StrategyContext.Submit() errors = %v, want %v", algo.errs, wantErrs)
+	}
+	for i := range wantErrs {
+		if algo.errs[i] != wantErrs[i] {
+			t.Errorf("StrategyContext.Submit() of order %d error = %v, want %v", i, algo.errs[i], wantErrs[i])
+		}
+	}
+
+	// 3 shares are bought at the ask, and 2 of them sold at the bid of the next quote.
+	var openVolume utils.Amount
+	for _, o := range sim.oms.ctx.OpenOrders("AAPL") {
+		if !o.Buy {
+			t.Errorf("OMS holds an open sell order %+v", o)
+		}
+		openVolume += o.Volume(0)
+	}
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"Cash", sim.oms.Cash(), utils.FloatAmount(100) - utils.FloatAmount(10)*3 + utils.FloatAmount(9)*2},
+		{"Held volume", sim.oms.ctx.Position("AAPL")[0].Volume(0), utils.Amount(1)},
+		{"Open order volume", openVolume, utils.Amount(1)},
+		{"Closed positions", sim.positions.ClosedPositions.GetByIndex(0).Volume(0), utils.Amount(2)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStrategyContext(t *testing.T) {
+	var cfg config.Config
+	cfg.Backtest.StartCashAmt = 100
+	cfg.Strategy.Params = map[string]interface{}{"size": 2.0, "name": "momentum"}
+	sim, err := NewSimulationFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	algo := &mockContextAlgorithm{}
+	sim.SetStrategy(NewStrategy(algo))
+
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	ticks := mockTicks("AAPL", "AAPL", "AAPL", "AAPL")
+	events := make(chan instrument.Event, len(ticks))
+	for i := range ticks {
+		ticks[i].Ask = utils.FloatAmount(10)
+		ticks[i].Timestamp = open.Add(time.Duration(i) * time.Second)
+		events <- ticks[i]
+	}
+	close(events)
+
+	clock := NewSimClock()
+	sim.SetClock(clock)
+	if err := sim.replay(context.Background(), mockEventSource(events), clock); err != nil {
+		t.Fatalf("Simulation.replay() error = %v", err)
+	}
+
+	// the first order is filled at the second quote, before it is passed to the algorithm,
+	// and the second order is cancelled before it could be filled.
+	start := utils.FloatAmount(100)
+	cash := start - utils.FloatAmount(10)*2
+	want := []contextSnapshot{
+		{open, start, 0, 0},
+		{open.Add(time.Second), cash, 1, 1},
+		{open.Add(2 * time.Second), cash, 1, 1},
+		{open.Add(3 * time.Second), cash, 1, 1},
+	}
+	if len(algo.seen) != len(want) {
+		t.Fatalf("Algorithm saw %d quotes, want %d", len(algo.seen), len(want))
+	}
+	for i := range want {
+		if !algo.seen[i].now.Equal(want[i].now) || algo.seen[i].cash != want[i].cash ||
+			algo.seen[i].positions != want[i].positions || algo.seen[i].open != want[i].open {
+			t.Errorf("StrategyContext at quote %d = %+v, want %+v", i, algo.seen[i], want[i])
+		}
+	}
+
+	tests := []struct {
+		name   string
+		param  string
+		want   interface{}
+		wantOk bool
+	}{
+		{"Number", "size", 2.0, true},
+		{"String", "name", "momentum", true},
+		{"Missing", "window", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := sim.oms.ctx.Param(tt.param)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("StrategyContext.Param() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+	if got := sim.oms.ctx.FloatParam("name", 5); got != 5 {
+		t.Errorf("StrategyContext.FloatParam() of a string = %v, want default %v", got, 5)
+	}
+}
@@ -0,0 +1,117 @@
+package porttools
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+)
+
+var (
+	// ErrInvalidTimestampUnit indicates that a timestamp unit is not one of s, ms, us or ns.
+	ErrInvalidTimestampUnit = errors.New("Timestamp unit must be one of s, ms, us or ns")
+)
+
+// timestampParser converts the timestamp field of a record to a time in the exchange time zone.
+type timestampParser func(field string) (time.Time, error)
+
+// newTimestampParser returns a timestampParser for the given format and unit.
+// fileDate is the date of the file being parsed, at midnight in loc.
+func newTimestampParser(format, unit string, fileDate time.Time, loc *time.Location) (timestampParser, error) {
+	switch format {
+	case "", config.TimestampDuration:
+		return func(field string) (time.Time, error) {
+			d, err := time.ParseDuration(field + unit)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return fileDate.Add(d), nil
+		}, nil
+
+	case config.TimestampEpoch:
+		scale, err := epochScale(unit)
+		if err != nil {
+			return nil, err
+		}
+		return func(field string) (time.Time, error) {
+			return parseEpoch(field, scale, loc)
+		}, nil
+
+	case config.TimestampRFC3339:
+		return func(field string) (time.Time, error) {
+			t, err := time.Parse(time.RFC3339Nano, field)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return t.In(loc), nil
+		}, nil
+
+	case config.TimestampClock:
+		year, month, day := fileDate.Date()
+		return func(field string) (time.Time, error) {
+			clock, err := time.Parse("15:04:05.999999999", field)
+			if err != nil {
+				return time.Time{}, err
+			}
+			hour, min, sec := clock.Clock()
+			return time.Date(year, month, day, hour, min, sec, clock.Nanosecond(), loc), nil
+		}, nil
+	}
+
+	// Any other format is used as a reference layout.
+	return func(field string) (time.Time, error) {
+		return time.ParseInLocation(format, field, loc)
+	}, nil
+}
+
+// epochScale returns the duration of one timestamp unit.
+func epochScale(unit string) (time.Duration, error) {
+	switch unit {
+	case "s":
+		return time.Second, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "us", "µs":
+		return time.Microsecond, nil
+	case "", "ns":
+		return time.Nanosecond, nil
+	}
+	return 0, ErrInvalidTimestampUnit
+}
+
+// parseEpoch parses a count of scale units since the Unix epoch.
+// Fractional units are kept down to the nanosecond.
+func parseEpoch(field string, scale time.Duration, loc *time.Location) (time.Time, error) {
+	var whole, frac int64
+	var err error
+
+	intPart, fracPart := field, ""
+	if dot := strings.IndexByte(field, '.'); dot != -1 {
+		intPart, fracPart = field[:dot], field[dot+1:]
+	}
+	if whole, err = strconv.ParseInt(intPart, 10, 64); err != nil {
+		return time.Time{}, err
+	}
+	if fracPart != "" && scale > time.Nanosecond {
+		// scale the fraction up to nanoseconds, dropping any further digits.
+		digits := len(strconv.FormatInt(int64(scale), 10)) - 1
+		if len(fracPart) > digits {
+			fracPart = fracPart[:digits]
+		}
+		if frac, err = strconv.ParseInt(fracPart, 10, 64); err != nil {
+			return time.Time{}, err
+		}
+		for i := len(fracPart); i < digits; i++ {
+			frac *= 10
+		}
+		if strings.HasPrefix(field, "-") {
+			frac = -frac
+		}
+	}
+
+	secs := whole / int64(time.Second/scale)
+	nsecs := (whole%int64(time.Second/scale))*int64(scale) + frac
+	return time.Unix(secs, nsecs).In(loc), nil
+}
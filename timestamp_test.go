@@ -0,0 +1,54 @@
+package porttools
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+)
+
+func Test_newTimestampParser(t *testing.T) {
+	nyc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database unavailable: ", err)
+	}
+	fileDate := time.Date(2017, 8, 14, 0, 0, 0, 0, nyc)
+	want := time.Date(2017, 8, 14, 9, 30, 0, 1000, nyc)
+
+	tests := []struct {
+		name    string
+		format  string
+		unit    string
+		field   string
+		wantErr bool
+	}{
+		{"Duration since file date", config.TimestampDuration, "ns", "34200000001000", false},
+		{"Epoch seconds", config.TimestampEpoch, "s", "1502717400.000001", false},
+		{"Epoch milliseconds", config.TimestampEpoch, "ms", "1502717400000.001", false},
+		{"Epoch microseconds", config.TimestampEpoch, "us", "1502717400000001", false},
+		{"Epoch nanoseconds", config.TimestampEpoch, "ns", "1502717400000001000", false},
+		{"RFC3339", config.TimestampRFC3339, "", "2017-08-14T13:30:00.000001Z", false},
+		{"Clock", config.TimestampClock, "", "09:30:00.000001", false},
+		{"Reference layout", "2006-01-02 15:04:05.000000", "", "2017-08-14 09:30:00.000001", false},
+		{"Invalid clock", config.TimestampClock, "", "9h30m", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parseTime, err := newTimestampParser(tt.format, tt.unit, fileDate, nyc)
+			if err != nil {
+				t.Fatalf("newTimestampParser() error = %v", err)
+			}
+			got, err := parseTime(tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTime() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (!got.Equal(want) || got.Location() != nyc) {
+				t.Errorf("parseTime() = %v, want %v", got, want)
+			}
+		})
+	}
+
+	if _, err = newTimestampParser(config.TimestampEpoch, "min", fileDate, nyc); err != ErrInvalidTimestampUnit {
+		t.Errorf("newTimestampParser() error = %v, want %v", err, ErrInvalidTimestampUnit)
+	}
+}
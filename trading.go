@@ -2,6 +2,7 @@ package porttools
 
 import (
 	"errors"
+	"time"
 
 	"github.com/jakeschurch/porttools/instrument"
 	"github.com/jakeschurch/porttools/order"
@@ -13,9 +14,41 @@ var (
 )
 
 // Algorithm is an interface that needs to be implemented in the pipeline by a user to fill orders based on the conditions that they specify.
+// Each of an algorithm's callbacks is passed the StrategyContext of the simulation it trades in.
 type Algorithm interface {
-	EntryCheck(instrument.Quote) (*order.Order, error)
-	ExitCheck(order.Order, instrument.Tick) (*order.Order, error)
+	EntryCheck(*StrategyContext, instrument.Quote) (*order.Order, error)
+	ExitCheck(*StrategyContext, order.Order, instrument.Tick) (*order.Order, error)
+}
+
+// BarAlgorithm is an optional interface for Algorithms that act on bars of tick data,
+// aggregated at the simulation's bar rate.
+type BarAlgorithm interface {
+	OnBar(*StrategyContext, instrument.Bar) (*order.Order, error)
+}
+
+// TradeAlgorithm is an optional interface for Algorithms that act on trade prints.
+type TradeAlgorithm interface {
+	OnTrade(*StrategyContext, instrument.Trade) (*order.Order, error)
+}
+
+// StartAlgorithm is an optional interface for Algorithms that act before a simulation's first event,
+// e.g. to schedule timers.
+type StartAlgorithm interface {
+	OnStart(*StrategyContext)
+}
+
+// DayAlgorithm is an optional interface for Algorithms that act at the start and end of each day.
+// Days run from the open to the close of the simulation's trading session if it trades on an exchange,
+// or otherwise from midnight to midnight. day is the midnight the day starts after.
+type DayAlgorithm interface {
+	OnDayStart(ctx *StrategyContext, day time.Time)
+	OnDayEnd(ctx *StrategyContext, day time.Time)
+}
+
+// EndAlgorithm is an optional interface for Algorithms that act once a simulation's replay has stopped,
+// before its results are written out.
+type EndAlgorithm interface {
+	OnEnd(*StrategyContext)
 }
 
 // ------------------------------------------------------------------
@@ -33,17 +66,69 @@ func NewStrategy(a Algorithm) Strategy {
 }
 
 // CheckEntryLogic ...TODO
-func (s Strategy) CheckEntryLogic(q instrument.Quote) (entryOrder *order.Order, err error) {
-	if entryOrder, err = s.Algorithm.EntryCheck(q); err != nil {
+func (s Strategy) CheckEntryLogic(ctx *StrategyContext, q instrument.Quote) (entryOrder *order.Order, err error) {
+	if entryOrder, err = s.Algorithm.EntryCheck(ctx, q); err != nil {
 		return nil, ErrOrderNotValid
 	}
 	return entryOrder, nil
 }
 
 // CheckExitLogic ...TODO
-func (s Strategy) CheckExitLogic(o order.Order, t instrument.Tick) (exitOrder *order.Order, err error) {
-	if exitOrder, err = s.Algorithm.ExitCheck(o, t); err != nil {
+func (s Strategy) CheckExitLogic(ctx *StrategyContext, o order.Order, t instrument.Tick) (exitOrder *order.Order, err error) {
+	if exitOrder, err = s.Algorithm.ExitCheck(ctx, o, t); err != nil {
 		return nil, ErrOrderNotValid
 	}
 	return exitOrder, nil
 }
+
+// CheckBarLogic passes a completed bar to the strategy's algorithm, if it implements BarAlgorithm.
+func (s Strategy) CheckBarLogic(ctx *StrategyContext, b instrument.Bar) (entryOrder *order.Order, err error) {
+	barAlgo, ok := s.Algorithm.(BarAlgorithm)
+	if !ok {
+		return nil, nil
+	}
+	if entryOrder, err = barAlgo.OnBar(ctx, b); err != nil {
+		return nil, ErrOrderNotValid
+	}
+	return entryOrder, nil
+}
+
+// CheckTradeLogic passes a trade print to the strategy's algorithm, if it implements TradeAlgorithm.
+func (s Strategy) CheckTradeLogic(ctx *StrategyContext, t instrument.Trade) (entryOrder *order.Order, err error) {
+	tradeAlgo, ok := s.Algorithm.(TradeAlgorithm)
+	if !ok {
+		return nil, nil
+	}
+	if entryOrder, err = tradeAlgo.OnTrade(ctx, t); err != nil {
+		return nil, ErrOrderNotValid
+	}
+	return entryOrder, nil
+}
+
+// Start notifies the strategy's algorithm of the start of a simulation, if it implements StartAlgorithm.
+func (s Strategy) Start(ctx *StrategyContext) {
+	if startAlgo, ok := s.Algorithm.(StartAlgorithm); ok {
+		startAlgo.OnStart(ctx)
+	}
+}
+
+// StartDay notifies the strategy's algorithm of the start of a day, if it implements DayAlgorithm.
+func (s Strategy) StartDay(ctx *StrategyContext, day time.Time) {
+	if dayAlgo, ok := s.Algorithm.(DayAlgorithm); ok {
+		dayAlgo.OnDayStart(ctx, day)
+	}
+}
+
+// EndDay notifies the strategy's algorithm of the end of a day, if it implements DayAlgorithm.
+func (s Strategy) EndDay(ctx *StrategyContext, day time.Time) {
+	if dayAlgo, ok := s.Algorithm.(DayAlgorithm); ok {
+		dayAlgo.OnDayEnd(ctx, day)
+	}
+}
+
+// End notifies the strategy's algorithm that the replay has stopped, if it implements EndAlgorithm.
+func (s Strategy) End(ctx *StrategyContext) {
+	if endAlgo, ok := s.Algorithm.(EndAlgorithm); ok {
+		endAlgo.OnEnd(ctx)
+	}
+}
@@ -0,0 +1,132 @@
+package porttools
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+// universe is the set of tickers a simulation trades.
+// Ticks of securities outside the universe are dropped before being processed.
+type universe struct {
+	// mu guards the tickers of the universe, which are renamed on changes of symbol
+	// while they may be being read by a source on another goroutine.
+	mu    sync.RWMutex
+	allow map[string]struct{}
+	deny  map[string]struct{}
+}
+
+// newUniverse builds a universe from the allowlist and denylist given in cfg,
+// either inline or from symbol files.
+// Returns nil if every security is to be processed.
+func newUniverse(cfg *config.Config) (*universe, error) {
+	allow, err := symbolSet(cfg.Backtest.Securities, cfg.Backtest.SecuritiesFile)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := symbolSet(cfg.Backtest.IgnoreSecurities, cfg.Backtest.IgnoreSecuritiesFile)
+	if err != nil {
+		return nil, err
+	}
+	if allow == nil && deny == nil {
+		return nil, nil
+	}
+	return &universe{allow: allow, deny: deny}, nil
+}
+
+// contains checks to see if a ticker is within the universe.
+func (u *universe) contains(ticker string) bool {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	if _, denied := u.deny[ticker]; denied {
+		return false
+	}
+	if u.allow == nil {
+		return true
+	}
+	_, allowed := u.allow[ticker]
+	return allowed
+}
+
+// rename carries a security's place in the universe over to its new ticker on a change of symbol.
+func (u *universe) rename(old, new string) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, allowed := u.allow[old]; allowed {
+		u.allow[new] = struct{}{}
+	}
+	if _, denied := u.deny[old]; denied {
+		u.deny[new] = struct{}{}
+	}
+}
+
+// symbolSet returns the set of tickers listed inline and in the symbols file name.
+// Returns nil if no tickers are listed.
+func symbolSet(inline []string, name string) (map[string]struct{}, error) {
+	symbols := append(make([]string, 0, len(inline)), inline...)
+
+	if name != "" {
+		fromFile, err := loadSymbols(name)
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, fromFile...)
+	}
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+
+	set := make(map[string]struct{}, len(symbols))
+	for _, symbol := range symbols {
+		set[strings.TrimSpace(symbol)] = struct{}{}
+	}
+	return set, nil
+}
+
+// loadSymbols reads one ticker per line from a symbols file.
+// Blank lines and lines starting with `#` are skipped,
+// as is anything following the ticker on a line, e.g. a company name.
+func loadSymbols(name string) ([]string, error) {
+	var symbols []string
+
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if end := strings.IndexAny(line, ",|\t "); end != -1 {
+			line = line[:end]
+		}
+		symbols = append(symbols, line)
+	}
+	return symbols, scanner.Err()
+}
+
+// ------------------------------------------------------------------
+
+// universeSource is an EventSource that only yields events of securities within a universe.
+type universeSource struct {
+	EventSource
+	universe *universe
+}
+
+// NextEvent returns the next event of a security within the universe.
+func (src *universeSource) NextEvent() (instrument.Event, error) {
+	for {
+		event, err := src.EventSource.NextEvent()
+		if err != nil || src.universe.contains(event.Ticker()) {
+			return event, err
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package porttools
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jakeschurch/porttools/config"
+)
+
+func Test_universeSource_NextEvent(t *testing.T) {
+	dir := mockDataFiles(t)
+	defer os.RemoveAll(dir)
+
+	symbolsFile := filepath.Join(dir, "sp500.txt")
+	symbols := "# S&P 500\nAAPL,Apple Inc.\n\nMSFT\tMicrosoft Corp.\nGOOGL\n"
+	if err := ioutil.WriteFile(symbolsFile, []byte(symbols), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name                 string
+		securities           []string
+		securitiesFile       string
+		ignoreSecurities     []string
+		ignoreSecuritiesFile string
+		want                 string
+	}{
+		{"No lists", nil, "", nil, "", "AAPL,IBM,GOOGL,MSFT"},
+		{"Inline denylist", nil, "", []string{"IBM"}, "", "AAPL,GOOGL,MSFT"},
+		{"Inline allowlist", []string{"IBM", "MSFT"}, "", nil, "", "IBM,MSFT"},
+		{"Allowlist file", nil, symbolsFile, nil, "", "AAPL,GOOGL,MSFT"},
+		{"Denylist file", nil, "", nil, symbolsFile, "IBM"},
+		{"Denylist overrides allowlist", nil, symbolsFile, []string{"GOOGL"}, "", "AAPL,MSFT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := new(config.Config)
+			cfg.Backtest.Securities = tt.securities
+			cfg.Backtest.SecuritiesFile = tt.securitiesFile
+			cfg.Backtest.IgnoreSecurities = tt.ignoreSecurities
+			cfg.Backtest.IgnoreSecuritiesFile = tt.ignoreSecuritiesFile
+
+			universe, err := newUniverse(cfg)
+			if err != nil {
+				t.Fatalf("newUniverse() error = %v", err)
+			}
+			src := eventSource(NewSliceSource(mockTicks("AAPL", "IBM", "GOOGL", "MSFT")))
+			if universe != nil {
+				src = &universeSource{EventSource: src, universe: universe}
+			}
+
+			var got []string
+			for {
+				event, err := src.NextEvent()
+				if err == io.EOF {
+					break
+				}
+				got = append(got, event.Ticker())
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("universeSource.NextEvent() tickers = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,46 +1,100 @@
 package porttools
 
-// Queue is an implementation of a FIFO container type.
+import (
+	"container/heap"
+	"time"
+)
+
+// Queue is a priority queue of nodes, ordered by time.
+// Nodes of equal times are dequeued in the order they were enqueued,
+// so that events are always handled in the same order.
 type Queue struct {
-	len    int
-	values []*Node
+	nodes nodeHeap
+	seq   uint64
 }
 
-// Enqueue stores value in the queue.
+// NewQueue instantiates a new Queue.
+func NewQueue() *Queue {
+	return &Queue{}
+}
+
+// Enqueue stores a node in the queue.
 func (queue *Queue) Enqueue(node *Node) {
-	queue.len++
-	if queue.len-1 == 0 {
-		queue.values[0] = node
-	} else {
-		queue.values[queue.len] = node
+	node.seq = queue.seq
+	queue.seq++
+	heap.Push(&queue.nodes, node)
+}
+
+// Dequeue removes and returns the earliest node of the queue,
+// or nil if the queue is empty.
+func (queue *Queue) Dequeue() *Node {
+	if len(queue.nodes) == 0 {
+		return nil
 	}
+	return heap.Pop(&queue.nodes).(*Node)
 }
 
-// Dequeue removes and returns value from the queue.
-func (queue *Queue) Dequeue() (node *Node) {
-	queue.len--
-	if queue.len+1 == 0 {
+// Peek returns the earliest node of the queue without removing it,
+// or nil if the queue is empty.
+func (queue *Queue) Peek() *Node {
+	if len(queue.nodes) == 0 {
 		return nil
 	}
-	node = queue.values[0]
-	queue.values = queue.values[0:]
-	return node
+	return queue.nodes[0]
 }
 
-// NewQueue instantiates a new Queue.
-func NewQueue() *Queue {
-	q := Queue{
-		len: 0,
+// Each calls fn with the data of each node in the queue, in no particular order.
+func (queue *Queue) Each(fn func(data interface{})) {
+	for _, node := range queue.nodes {
+		fn(node.data)
 	}
-	return &q
 }
 
-// Node represents data stored in a container.
+// Len returns the number of nodes in the queue.
+func (queue *Queue) Len() int {
+	return len(queue.nodes)
+}
+
+// Node represents data stored in a container, at the time it is due.
 type Node struct {
 	data interface{}
+	time time.Time
+	seq  uint64
 }
 
-// NewNode instantiates a new Node.
-func NewNode(data interface{}) *Node {
-	return &Node{data: data}
+// NewNode instantiates a new Node, due at t.
+func NewNode(data interface{}, t time.Time) *Node {
+	return &Node{data: data, time: t}
+}
+
+// Time returns the time a node is due.
+func (node *Node) Time() time.Time {
+	return node.time
+}
+
+// nodeHeap implements heap.Interface for a Queue.
+type nodeHeap []*Node
+
+func (h nodeHeap) Len() int { return len(h) }
+
+func (h nodeHeap) Less(i, j int) bool {
+	if !h[i].time.Equal(h[j].time) {
+		return h[i].time.Before(h[j].time)
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h nodeHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *nodeHeap) Push(x interface{}) {
+	*h = append(*h, x.(*Node))
+}
+
+func (h *nodeHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	node := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return node
 }
@@ -3,6 +3,7 @@ package utils
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strconv"
 	"time"
 )
@@ -32,7 +33,11 @@ func FloatAmount(float float64) Amount {
 }
 
 // DivideAmt allows Division by integers(Amounts).
+// The quotient is given in hundredths, rounded half away from zero.
 func DivideAmt(top, bottom Amount) Amount {
+	if (top < 0) != (bottom < 0) {
+		return (top*200 - bottom) / (bottom * 2)
+	}
 	return (top*200 + bottom) / (bottom * 2)
 }
 
@@ -60,55 +65,34 @@ func (amt Amount) ToCurrency() string {
 }
 
 func (amt Amount) String() string {
-	return string(amt)
+	return amt.ToCurrency()
 }
 
-// ToVolume returns a string representation of a quantity or volume.
+// ToVolume returns a string representation of a quantity or volume of whole units, grouped in thousands.
 func (amt Amount) ToVolume() string {
-	str := strconv.Itoa(int(amt))
-
-	b := bytes.NewBufferString(str)
-	numCommas := (b.Len() - 2) / 3
+	var out bytes.Buffer
 
-	j := 0
-	out := make([]byte, b.Len()+numCommas+1) // 1 extra placeholders for a `.`
-	for i, v := range b.Bytes() {
-		if i == (b.Len() - 2) {
-			out[j], _ = bytes.NewBufferString(".").ReadByte()
-			j++
-		} else if (i-1)%3 == 0 && b.Len() > 4 {
-			out[j], _ = bytes.NewBufferString(",").ReadByte()
-			j++
+	if amt < 0 {
+		out.WriteByte('-')
+		amt = -amt
+	}
+	str := strconv.FormatInt(int64(amt), 10)
+	for i := range str {
+		if i > 0 && (len(str)-i)%3 == 0 {
+			out.WriteByte(',')
 		}
-		out[j] = v
-		j++
+		out.WriteByte(str[i])
 	}
-	return string(out)
+	return out.String()
 }
 
-// ToPercent returns a string representation of a percent.
+// ToPercent returns a string representation of a percent given in hundredths of a percent.
 func (amt Amount) ToPercent() string {
-	str := strconv.Itoa(int(amt))
-
-	b := bytes.NewBufferString(str)
-	numCommas := (b.Len() - 2) / 3
-
-	j := 0
-	out := make([]byte, b.Len()+numCommas+2) // 1 extra placeholders for a `.`
-	for i, v := range b.Bytes() {
-		if i == (b.Len() - 2) {
-			out[j], _ = bytes.NewBufferString(".").ReadByte()
-			j++
-		} else if (i-1)%3 == 0 {
-			out[j], _ = bytes.NewBufferString(",").ReadByte()
-			j++
-		}
-		out[j] = v
-		j++
+	sign := ""
+	if amt < 0 {
+		sign, amt = "-", -amt
 	}
-	percentSign, _ := bytes.NewBufferString("%").ReadByte()
-	out = append(out, percentSign)
-	return string(out)
+	return fmt.Sprintf("%s%s.%02d%%", sign, (amt / 100).ToVolume(), int64(amt%100))
 }
 
 // DatedMetric ...TODO
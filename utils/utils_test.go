@@ -2,6 +2,64 @@ package utils
 
 import "testing"
 
+func TestAmount_ToVolume(t *testing.T) {
+	tests := []struct {
+		name string
+		amt  Amount
+		want string
+	}{
+		{"Single unit", 1, "1"},
+		{"Hundreds", 500, "500"},
+		{"Thousands", 1234567, "1,234,567"},
+		{"Negative", -1000, "-1,000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amt.ToVolume(); got != tt.want {
+				t.Errorf("Amount.ToVolume() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAmount_ToPercent(t *testing.T) {
+	tests := []struct {
+		name string
+		amt  Amount
+		want string
+	}{
+		{"Whole percent", 1000, "10.00%"},
+		{"Fraction of a percent", 5, "0.05%"},
+		{"Negative", -1550, "-15.50%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.amt.ToPercent(); got != tt.want {
+				t.Errorf("Amount.ToPercent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDivideAmt(t *testing.T) {
+	tests := []struct {
+		name        string
+		top, bottom Amount
+		want        Amount
+	}{
+		{"Exact", 50, 100, 50},
+		{"Rounded up", 2, 3, 67},
+		{"Negative rounded away from zero", -2, 3, -67},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DivideAmt(tt.top, tt.bottom); got != tt.want {
+				t.Errorf("DivideAmt() = %v, want %v", int64(got), int64(tt.want))
+			}
+		})
+	}
+}
+
 func TestAmount_ToCurrency(t *testing.T) {
 	tests := []struct {
 		name string
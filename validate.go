@@ -0,0 +1,261 @@
+package porttools
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+const (
+	// defaultJumpSigma is the number of standard deviations a change in a ticker's mid price
+	// must move by to be flagged as a price jump, if not configured.
+	defaultJumpSigma = 5
+
+	// minJumpSamples is the number of mid price changes a ticker must have
+	// before its price jumps are flagged.
+	minJumpSamples = 30
+)
+
+// SymbolReport counts the data-quality problems found in the ticks of a single ticker.
+type SymbolReport struct {
+	Ticker      string
+	Ticks       int
+	First, Last time.Time
+
+	// Crossed and Locked count quotes whose bid is above, or equal to, their ask.
+	Crossed, Locked int
+	// BadSize counts quotes with a zero or negative bid or ask size.
+	BadSize int
+	// OutOfOrder counts ticks timestamped before the ticker's previous tick.
+	OutOfOrder int
+	// Duplicate counts ticks that repeat the ticker's previous tick.
+	Duplicate int
+	// Jumps counts changes in mid price beyond the configured number of standard deviations.
+	Jumps int
+	// Stale counts quotes left unchanged for longer than the configured stale interval.
+	Stale int
+}
+
+// Flagged returns the number of the ticker's ticks that were flagged as problems.
+func (r SymbolReport) Flagged() int {
+	return r.Crossed + r.Locked + r.BadSize + r.OutOfOrder + r.Duplicate + r.Jumps + r.Stale
+}
+
+// ValidationReport holds the results of a data-quality validation pass over a TickSource.
+type ValidationReport struct {
+	Symbols []SymbolReport
+	Ingest  IngestSummary
+}
+
+// Exclude returns the tickers whose proportion of flagged ticks is above maxRate,
+// for use as a config's ignoreSecurities.
+func (r *ValidationReport) Exclude(maxRate float64) []string {
+	var tickers []string
+	for _, s := range r.Symbols {
+		if s.Ticks > 0 && float64(s.Flagged())/float64(s.Ticks) > maxRate {
+			tickers = append(tickers, s.Ticker)
+		}
+	}
+	return tickers
+}
+
+// WriteCSV writes the report to w, with a row for each ticker.
+func (r *ValidationReport) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"ticker", "ticks", "first", "last", "crossed", "locked", "badSize",
+		"outOfOrder", "duplicate", "jumps", "stale", "flagged"})
+
+	for _, s := range r.Symbols {
+		cw.Write([]string{
+			s.Ticker, strconv.Itoa(s.Ticks),
+			s.First.Format(time.RFC3339Nano), s.Last.Format(time.RFC3339Nano),
+			strconv.Itoa(s.Crossed), strconv.Itoa(s.Locked), strconv.Itoa(s.BadSize),
+			strconv.Itoa(s.OutOfOrder), strconv.Itoa(s.Duplicate),
+			strconv.Itoa(s.Jumps), strconv.Itoa(s.Stale), strconv.Itoa(s.Flagged()),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// ------------------------------------------------------------------
+
+// Validate runs a data-quality validation pass over the ticks of src.
+func Validate(src TickSource, cfg *config.Config) (*ValidationReport, error) {
+	v := newValidator(cfg)
+	for {
+		tick, err := src.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		v.Add(tick)
+	}
+
+	report := v.Report()
+	if s, ok := src.(summarizer); ok {
+		report.Ingest = s.Summary()
+	}
+	return report, nil
+}
+
+// Validate runs a data-quality validation pass over the simulation's ticks,
+// writing a per-symbol report to w.
+func (sim *Simulation) Validate(w io.Writer) (*ValidationReport, error) {
+	sim.mu.RLock()
+	src := sim.source
+	sim.mu.RUnlock()
+
+	if src == nil {
+		dataSrc, err := newDataSource(&sim.config)
+		if err != nil {
+			return nil, err
+		}
+		if c, ok := dataSrc.(io.Closer); ok {
+			defer c.Close()
+		}
+		src = dataSrc
+	}
+
+	report, err := Validate(src, &sim.config)
+	if err != nil {
+		return nil, err
+	}
+	return report, report.WriteCSV(w)
+}
+
+// validator checks the quality of a stream of ticks, ticker by ticker.
+type validator struct {
+	jumpSigma  float64
+	staleAfter time.Duration
+	symbols    map[string]*symbolState
+}
+
+// symbolState holds a ticker's report along with what the validator knows of its previous tick.
+type symbolState struct {
+	report      SymbolReport
+	last        instrument.Tick
+	lastChange  time.Time
+	changes     int
+	mean, sumSq float64
+}
+
+func newValidator(cfg *config.Config) *validator {
+	v := &validator{
+		jumpSigma:  cfg.Validation.JumpSigma,
+		staleAfter: time.Duration(cfg.Validation.StaleAfter),
+		symbols:    make(map[string]*symbolState),
+	}
+	if v.jumpSigma <= 0 {
+		v.jumpSigma = defaultJumpSigma
+	}
+	return v
+}
+
+// Add checks tick t against the previous ticks of its ticker.
+func (v *validator) Add(t *instrument.Tick) {
+	s, ok := v.symbols[t.Ticker()]
+	if !ok {
+		s = &symbolState{report: SymbolReport{Ticker: t.Ticker(), First: t.Timestamp}}
+		v.symbols[t.Ticker()] = s
+	}
+	s.report.Ticks++
+
+	switch {
+	case t.Bid > t.Ask:
+		s.report.Crossed++
+	case t.Bid == t.Ask:
+		s.report.Locked++
+	}
+	if t.BidSize <= 0 || t.AskSize <= 0 {
+		s.report.BadSize++
+	}
+
+	if !ok {
+		s.setLast(t)
+		s.lastChange = t.Timestamp
+		return
+	}
+	if t.Timestamp.Before(s.last.Timestamp) {
+		s.report.OutOfOrder++
+		return
+	}
+	// a quote left unchanged until the close is flagged at the ticker's first tick of the next day.
+	if !sameDay(t.Timestamp, s.last.Timestamp) {
+		if v.stale(s, s.last.Timestamp) {
+			s.report.Stale++
+		}
+		s.lastChange = t.Timestamp
+	}
+	if t.Timestamp.Equal(s.last.Timestamp) && t.Bid == s.last.Bid && t.Ask == s.last.Ask &&
+		t.BidSize == s.last.BidSize && t.AskSize == s.last.AskSize {
+		s.report.Duplicate++
+		return
+	}
+
+	if t.Bid != s.last.Bid || t.Ask != s.last.Ask {
+		if v.stale(s, t.Timestamp) {
+			s.report.Stale++
+		}
+		v.checkJump(s, float64(t.Mid()-s.last.Mid()))
+		s.lastChange = t.Timestamp
+	}
+	s.setLast(t)
+}
+
+// stale returns whether a ticker's quote was left unchanged for longer than staleAfter until t.
+func (v *validator) stale(s *symbolState, until time.Time) bool {
+	return v.staleAfter > 0 && until.Sub(s.lastChange) > v.staleAfter && sameDay(until, s.lastChange)
+}
+
+// checkJump flags a change in mid price beyond jumpSigma standard deviations of
+// the ticker's previous changes. Flagged changes are left out of later checks.
+func (v *validator) checkJump(s *symbolState, change float64) {
+	if s.changes >= minJumpSamples {
+		sigma := math.Sqrt(s.sumSq / float64(s.changes-1))
+		if sigma > 0 && math.Abs(change-s.mean) > v.jumpSigma*sigma {
+			s.report.Jumps++
+			return
+		}
+	}
+	s.changes++
+	delta := change - s.mean
+	s.mean += delta / float64(s.changes)
+	s.sumSq += delta * (change - s.mean)
+}
+
+// Report returns the validator's reports, sorted by ticker.
+// Quotes left unchanged until each ticker's last tick are flagged as stale.
+func (v *validator) Report() *ValidationReport {
+	report := &ValidationReport{Symbols: make([]SymbolReport, 0, len(v.symbols))}
+	for _, s := range v.symbols {
+		r := s.report
+		if v.stale(s, s.last.Timestamp) {
+			r.Stale++
+		}
+		report.Symbols = append(report.Symbols, r)
+	}
+	sort.Slice(report.Symbols, func(i, j int) bool {
+		return report.Symbols[i].Ticker < report.Symbols[j].Ticker
+	})
+	return report
+}
+
+func (s *symbolState) setLast(t *instrument.Tick) {
+	s.last = *t
+	s.report.Last = t.Timestamp
+}
+
+func sameDay(a, b time.Time) bool {
+	ay, am, ad := a.Date()
+	by, bm, bd := b.Date()
+	return ay == by && am == bm && ad == bd
+}
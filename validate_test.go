@@ -0,0 +1,124 @@
+package porttools
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jakeschurch/porttools/config"
+	"github.com/jakeschurch/porttools/instrument"
+)
+
+func TestValidate(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	at := func(d time.Duration) time.Time { return open.Add(d) }
+
+	// a steadily oscillating mid price, so that price jumps stand out.
+	steady := func(ticker string, n int) []instrument.Tick {
+		ticks := make([]instrument.Tick, n)
+		for i := range ticks {
+			bid := 50.00 + float64(i%2)/100
+			ticks[i] = mockQuoteTick(ticker, bid, bid+0.10, at(time.Duration(i)*time.Second))
+		}
+		return ticks
+	}
+	badSize := mockQuoteTick("AAPL", 50.00, 50.10, at(time.Second))
+	badSize.AskSize = 0
+
+	tests := []struct {
+		name  string
+		ticks []instrument.Tick
+		want  SymbolReport
+	}{
+		{"Clean", steady("AAPL", 40), SymbolReport{Ticks: 40}},
+		{"Crossed and locked", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.20, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.10, 50.10, at(time.Second)),
+		}, SymbolReport{Ticks: 2, Crossed: 1, Locked: 1}},
+		{"Bad size", []instrument.Tick{badSize}, SymbolReport{Ticks: 1, BadSize: 1}},
+		{"Out of order", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(2*time.Second)),
+			mockQuoteTick("AAPL", 50.01, 50.11, at(time.Second)),
+		}, SymbolReport{Ticks: 2, OutOfOrder: 1}},
+		{"Duplicate", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+		}, SymbolReport{Ticks: 2, Duplicate: 1}},
+		{"Price jump", append(steady("AAPL", 40),
+			mockQuoteTick("AAPL", 55.00, 55.10, at(time.Minute)),
+		), SymbolReport{Ticks: 41, Jumps: 1}},
+		{"Stale", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.00, 50.10, at(time.Minute)),
+			mockQuoteTick("AAPL", 50.01, 50.11, at(10*time.Minute)),
+		}, SymbolReport{Ticks: 3, Stale: 1}},
+		{"Stale at end of data", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.00, 50.10, at(10*time.Minute)),
+		}, SymbolReport{Ticks: 2, Stale: 1}},
+		{"Stale at end of day", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.00, 50.10, at(10*time.Minute)),
+			mockQuoteTick("AAPL", 50.01, 50.11, at(24*time.Hour)),
+		}, SymbolReport{Ticks: 3, Stale: 1}},
+		{"Changed within stale interval", []instrument.Tick{
+			mockQuoteTick("AAPL", 50.00, 50.10, at(0)),
+			mockQuoteTick("AAPL", 50.01, 50.11, at(time.Minute)),
+			mockQuoteTick("AAPL", 50.01, 50.11, at(2*time.Minute)),
+		}, SymbolReport{Ticks: 3}},
+	}
+	cfg := new(config.Config)
+	cfg.Validation.StaleAfter = config.BarDuration(5 * time.Minute)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := Validate(NewSliceSource(mockTickPtrs(tt.ticks)), cfg)
+			if err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			if len(report.Symbols) != 1 {
+				t.Fatalf("Validate() symbols = %d, want 1", len(report.Symbols))
+			}
+			got := report.Symbols[0]
+			tt.want.Ticker, tt.want.First, tt.want.Last = got.Ticker, got.First, got.Last
+			if got != tt.want {
+				t.Errorf("Validate() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidationReport(t *testing.T) {
+	open := time.Date(2017, 8, 14, 9, 30, 0, 0, time.UTC)
+	ticks := []instrument.Tick{
+		mockQuoteTick("GOOGL", 10.00, 10.10, open),
+		mockQuoteTick("AAPL", 50.20, 50.10, open),
+		mockQuoteTick("AAPL", 50.00, 50.10, open.Add(time.Second)),
+	}
+	report, err := Validate(NewSliceSource(mockTickPtrs(ticks)), new(config.Config))
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if got := report.Exclude(0.25); len(got) != 1 || got[0] != "AAPL" {
+		t.Errorf("ValidationReport.Exclude() = %v, want [AAPL]", got)
+	}
+
+	var buf bytes.Buffer
+	if err = report.WriteCSV(&buf); err != nil {
+		t.Fatalf("ValidationReport.WriteCSV() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "AAPL,2,") || !strings.HasSuffix(lines[1], ",1,0,0,0,0,0,0,1") {
+		t.Errorf("ValidationReport.WriteCSV() = %q", buf.String())
+	}
+}
+
+func mockTickPtrs(ticks []instrument.Tick) []*instrument.Tick {
+	ptrs := make([]*instrument.Tick, len(ticks))
+	for i := range ticks {
+		ptrs[i] = &ticks[i]
+	}
+	return ptrs
+}